@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"ssh-noty/internal/logging"
+	"ssh-noty/internal/notify"
+	"ssh-noty/internal/sources"
+)
+
+// healthMonitor logs source state transitions, alerts when a source goes
+// down or recovers, and serves /healthz from the supervised sources and
+// notifier delivery results.
+type healthMonitor struct {
+	ctx   context.Context
+	alert func(ctx context.Context, text string)
+	src   sources.Source
+	sinks *notify.Fanout
+
+	mu   sync.Mutex
+	down map[string]bool
+	// alerts are sent in order by one goroutine so that a slow notifier
+	// does not stall the supervisor delivering records.
+	alerts chan string
+}
+
+func newHealthMonitor(ctx context.Context, alert func(context.Context, string)) *healthMonitor {
+	h := &healthMonitor{ctx: ctx, alert: alert, down: make(map[string]bool), alerts: make(chan string, 16)}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case text := <-h.alerts:
+				h.alert(ctx, text)
+			}
+		}
+	}()
+	return h
+}
+
+func (h *healthMonitor) onChange(st sources.Status) {
+	log := logging.L()
+	log.Info("source state changed", "source", st.Name, "state", st.State, "restarts", st.Restarts, "error", st.LastError)
+	h.mu.Lock()
+	wasDown := h.down[st.Name]
+	var text string
+	switch st.State {
+	case sources.StateFailed, sources.StateRestarting:
+		if !wasDown {
+			h.down[st.Name] = true
+			text = fmt.Sprintf("⚠️ ssh-noti source %s is down (%s): %s", st.Name, st.State, st.LastError)
+		}
+	case sources.StateRunning:
+		if wasDown {
+			h.down[st.Name] = false
+			text = fmt.Sprintf("✅ ssh-noti source %s recovered after %d restart(s)", st.Name, st.Restarts)
+		}
+	}
+	h.mu.Unlock()
+	if text == "" {
+		return
+	}
+	select {
+	case h.alerts <- text:
+	default:
+		log.Warn("health alert dropped; notifiers are backed up", "text", text)
+	}
+}
+
+// ServeHTTP reports source health as JSON; 503 unless every source runs.
+func (h *healthMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	statuses := sources.Health(h.src)
+	code := http.StatusOK
+	for _, st := range statuses {
+		if st.State != sources.StateRunning {
+			code = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	notifiers := map[string]notify.Health{}
+	if h.sinks != nil {
+		for _, n := range h.sinks.Sinks() {
+			notifiers[n.Name()] = n.Health()
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"sources": statuses, "notifiers": notifiers})
+}
+
+func (h *healthMonitor) listen(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", h)
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-h.ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logging.L().Warn("health endpoint failed", "addr", addr, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/logging"
+	"ssh-noty/internal/notify"
+	"ssh-noty/internal/silence"
+)
+
+// listenInteractive serves Slack button actions on /slack/actions, over
+// HTTPS unless plain HTTP is configured for a TLS proxy. threads are the
+// Slack notifiers whose alerts carry the buttons.
+func listenInteractive(ctx context.Context, ic config.Interactive, store *silence.Store, threads []*notify.Slack) {
+	mux := http.NewServeMux()
+	mux.Handle("/slack/actions", &notify.SlackActions{
+		Secret:   []byte(ic.SigningSecret),
+		Silences: store,
+		Firewall: ic.FirewallCommand,
+		Threads:  threads,
+	})
+	srv := &http.Server{Addr: ic.Addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		var err error
+		if ic.PlainHTTP && ic.TLSCert == "" {
+			logging.L().Info("interactive endpoint served over plain HTTP for a TLS proxy", "addr", ic.Addr)
+			err = srv.ListenAndServe()
+		} else {
+			err = srv.ListenAndServeTLS(ic.TLSCert, ic.TLSKey)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logging.L().Warn("interactive endpoint failed", "addr", ic.Addr, "error", err)
+		}
+	}()
+}
+
+// slackNotifiers are the unwrapped Slack sinks.
+func slackNotifiers(sinks []notify.Notifier) []*notify.Slack {
+	var out []*notify.Slack
+	for _, n := range sinks {
+		if s, ok := n.(*notify.Slack); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -3,7 +3,17 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/netip"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/schedule"
+	"ssh-noty/internal/tmpl"
 )
 
 type Config struct {
@@ -16,12 +26,191 @@ type Config struct {
 	GeoIP        GeoIP   `json:"geoip"`
 	Formatting   Format  `json:"formatting"`
 	Telemetry    Tele    `json:"telemetry"`
+	// Notifiers lists the destinations events are sent to. When empty,
+	// slack_webhook is used as a single Slack notifier.
+	Notifiers []Notifier `json:"notifiers"`
+	Queue     Queue      `json:"queue"`
+	Dispatch  Dispatch   `json:"dispatch"`
+	// Interactive serves the endpoint for Slack alert buttons.
+	Interactive Interactive `json:"interactive"`
+	Silence     Silence     `json:"silence"`
+	// Routes select the notifiers an event is sent to. Events matching no
+	// route go to every notifier.
+	Routes  []Route `json:"routes"`
+	Scoring Scoring `json:"scoring"`
+	// Policies restrict when users may log in.
+	Policies []Policy `json:"policies"`
+}
+
+// Policy applies to the users matching its glob patterns (every user when
+// empty); the first matching policy wins. A successful login outside all
+// of its Allowed windows is tagged "outside_schedule" and
+// "policy:<name>" and raised to Severity (default high).
+type Policy struct {
+	Name     string   `json:"name"`
+	Users    []string `json:"users"`
+	Allowed  []Hours  `json:"allowed"`
+	Severity string   `json:"severity"`
+	// Alert also sends a message naming the policy.
+	Alert bool `json:"alert"`
+}
+
+// Route sends the events it matches to the named notifiers; an empty list
+// drops them. Routes are tried in order and the first match wins unless it
+// sets Continue.
+type Route struct {
+	Name      string     `json:"name"`
+	Match     RouteMatch `json:"match"`
+	Notifiers []string   `json:"notifiers"`
+	Continue  bool       `json:"continue"`
+}
+
+// RouteMatch is true when every non-empty condition holds. Users and Hosts
+// are glob patterns; an event must carry all of Tags.
+type RouteMatch struct {
+	Types       []string `json:"types"`
+	Users       []string `json:"users"`
+	CIDRs       []string `json:"cidrs"`
+	Hosts       []string `json:"hosts"`
+	MinSeverity string   `json:"min_severity"`
+	Tags        []string `json:"tags"`
+}
+
+// TemplatedTypes are the notifier types that render user templates.
+var TemplatedTypes = []string{"discord", "gotify", "matrix", "ntfy", "slack", "smtp", "teams", "telegram"}
+
+// Notifier configures one notification sink. Type selects the
+// implementation; fields not used by that type are ignored.
+type Notifier struct {
+	Type           string `json:"type"`
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	// Templates maps an event type (or "default") to a Go text/template
+	// file overriding the message layout; .json files must render JSON.
+	Templates map[string]string `json:"templates"`
+
+	// Token authenticates against the service API (e.g. Telegram or Slack
+	// bot token).
+	Token string `json:"token"`
+	// Slack bot mode: Channel receives realtime alerts and DigestChannel
+	// (default Channel) summaries. Events from one IP within ThreadSeconds
+	// are threaded under the first alert.
+	Channel       string `json:"channel"`
+	DigestChannel string `json:"digest_channel"`
+	ThreadSeconds int    `json:"thread_seconds"`
+	// ChatIDs are Telegram chats to deliver to.
+	ChatIDs []string `json:"chat_ids"`
+	// SilentBelow delivers events under this severity without a sound.
+	SilentBelow string `json:"silent_below"`
+
+	// Email (type "smtp"). URL is smtp://host:port (STARTTLS) or
+	// smtps://host:port (implicit TLS); TLS "none" allows plaintext relays.
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	Auth         string   `json:"auth"`
+	TLS          string   `json:"tls"`
+	From         string   `json:"from"`
+	To           []string `json:"to"`
+	BatchSeconds int      `json:"batch_seconds"`
+
+	// Paging (types "pagerduty", "opsgenie"). Token is the routing key or
+	// API key. Only events at or above MinSeverity page. BruteForce* define
+	// when failures from one IP open an incident, resolved automatically
+	// after ResolveAfterSeconds without further failures. A successful login
+	// pages as critical after SuccessAfterFailures failures from its IP
+	// within the brute-force window.
+	MinSeverity             string `json:"min_severity"`
+	BruteForceThreshold     int    `json:"brute_force_threshold"`
+	BruteForceWindowSeconds int    `json:"brute_force_window_seconds"`
+	ResolveAfterSeconds     int    `json:"resolve_after_seconds"`
+	SuccessAfterFailures    int    `json:"success_after_failures"`
+
+	// Generic webhook (type "webhook"). URL, Headers values and Body are
+	// Go text/templates; Secret enables HMAC-SHA256 request signing.
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+	Secret  string            `json:"secret"`
+
+	// Push services: ntfy topic and extra tags, Matrix room ID.
+	Topic string   `json:"topic"`
+	Tags  []string `json:"tags"`
+	Room  string   `json:"room"`
+
+	// Local outputs: JSON Lines file with size rotation, syslog facility
+	// (the address is url) and exec hook command with a concurrency limit.
+	Path        string   `json:"path"`
+	MaxSizeMB   int      `json:"max_size_mb"`
+	MaxBackups  int      `json:"max_backups"`
+	Facility    string   `json:"facility"`
+	Command     []string `json:"command"`
+	Concurrency int      `json:"concurrency"`
+}
+
+// Sinks returns the notifiers to build. Without a notifiers array the
+// legacy slack_webhook is used, and with neither, events are only logged.
+func (c *Config) Sinks() []Notifier {
+	if len(c.Notifiers) > 0 {
+		return c.Notifiers
+	}
+	if c.SlackWebhook != "" {
+		return []Notifier{{Type: "slack", Name: "slack", URL: c.SlackWebhook}}
+	}
+	return []Notifier{{Type: "log", Name: "log"}}
+}
+
+// Timeout returns the configured per-request timeout or def.
+func (n Notifier) Timeout(def time.Duration) time.Duration {
+	if n.TimeoutSeconds > 0 {
+		return time.Duration(n.TimeoutSeconds) * time.Second
+	}
+	return def
+}
+
+// Queue configures the on-disk spool in front of each notifier that keeps
+// and retries notifications which could not be delivered.
+type Queue struct {
+	Dir               string `json:"dir"`
+	MaxAgeSeconds     int    `json:"max_age_seconds"`
+	MaxBackoffSeconds int    `json:"max_backoff_seconds"`
+}
+
+// Dispatch configures the bounded in-memory queue and worker that decouple
+// each notifier from the event loop.
+type Dispatch struct {
+	QueueSize int `json:"queue_size"`
+	// Overflow is block (default), drop_oldest or drop_newest.
+	Overflow            string `json:"overflow"`
+	DrainTimeoutSeconds int    `json:"drain_timeout_seconds"`
+}
+
+// Interactive configures the HTTPS endpoint receiving Slack interactive
+// actions. Requests must be signed with SigningSecret. FirewallCommand is
+// run by "Block IP" with "{ip}" replaced by the address.
+type Interactive struct {
+	Addr    string `json:"addr"`
+	TLSCert string `json:"tls_cert"`
+	TLSKey  string `json:"tls_key"`
+	// PlainHTTP serves without TLS, for a reverse proxy that terminates it.
+	PlainHTTP       bool     `json:"plain_http"`
+	SigningSecret   string   `json:"signing_secret"`
+	FirewallCommand []string `json:"firewall_command"`
+}
+
+// Silence configures the persisted silence list.
+type Silence struct {
+	Path string `json:"path"`
+	// Socket is the unix socket the daemon serves the silence CLI on.
+	Socket string `json:"socket"`
 }
 
 type Sources struct {
 	Prefer       string   `json:"prefer"`
 	FilePaths    []string `json:"file_paths"`
 	SystemdUnits []string `json:"systemd_units"`
+	UtmpPaths    []string `json:"utmp_paths"`
+	AuditLog     string   `json:"audit_log"`
 }
 
 type Rules struct {
@@ -32,6 +221,8 @@ type Rules struct {
 	ExcludeUsers      []string `json:"exclude_users"`
 	ExcludeIPs        []string `json:"exclude_ips"`
 	IncludeIPs        []string `json:"include_ips"`
+	// File is a JSON array of custom detection rules.
+	File string `json:"file"`
 }
 
 type Rate struct {
@@ -48,17 +239,96 @@ type Batch struct {
 type GeoIP struct {
 	Enabled bool   `json:"enabled"`
 	DBPath  string `json:"db_path"`
+	// CountryCSV maps networks to ISO country codes, one "start,end,CC"
+	// range (e.g. the DB-IP country lite CSV) or "cidr,CC" per line.
+	CountryCSV string `json:"country_csv"`
+}
+
+// Scoring computes event severity: the Base score of the event type plus
+// every Bumps entry that applies, mapped to the highest severity whose
+// Thresholds score is reached. Keys left out take the defaults; a bump set
+// to 0 is disabled.
+type Scoring struct {
+	Base       map[string]int `json:"base"`
+	Bumps      map[string]int `json:"bumps"`
+	Thresholds map[string]int `json:"thresholds"`
+	// KnownKeys are the expected public key fingerprints; other keys get
+	// the unknown_key bump. Empty disables it.
+	KnownKeys []string `json:"known_keys"`
+	// Blocklist and BlocklistFile (one entry per line, # comments) list
+	// addresses and CIDRs for the blocklisted_ip bump.
+	Blocklist     []string `json:"blocklist"`
+	BlocklistFile string   `json:"blocklist_file"`
+	// HomeCountries are ISO codes; events from elsewhere get the
+	// foreign_country bump when GeoIP knows the country.
+	HomeCountries []string `json:"home_countries"`
+	// BusinessHours enables the off_hours bump for events outside it.
+	BusinessHours Hours `json:"business_hours"`
+	// FailureWindowSeconds is how far back failures from the same IP count
+	// towards success_after_failures (default 600).
+	FailureWindowSeconds int `json:"failure_window_seconds"`
+}
+
+// Hours is a weekly window, e.g. days ["mon-fri"] 08:00-19:00 in
+// Europe/Madrid. It is unset while Start is empty.
+type Hours struct {
+	Timezone string   `json:"timezone"`
+	Days     []string `json:"days"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	// Holidays are ICS or date files listing days the window is closed.
+	Holidays []string `json:"holidays"`
+}
+
+// Window parses h and loads its holidays; it returns nil for unset hours.
+func (h Hours) Window() (*schedule.Window, error) {
+	if h.Start == "" && h.End == "" {
+		return nil, nil
+	}
+	w, err := schedule.Parse(h.Timezone, h.Days, h.Start, h.End)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.Holidays) > 0 {
+		cal, err := schedule.LoadCalendar(h.Holidays...)
+		if err != nil {
+			return nil, err
+		}
+		w.SetHolidays(cal)
+	}
+	return w, nil
+}
+
+var (
+	defaultBase       = map[string]int{"login_success": 40, "login_failure": 20, "invalid_user": 20}
+	defaultBumps      = map[string]int{"root_user": 30, "unknown_key": 20, "blocklisted_ip": 40, "foreign_country": 20, "off_hours": 20, "success_after_failures": 40}
+	defaultThresholds = map[string]int{"low": 20, "medium": 40, "high": 60, "critical": 80}
+)
+
+// withDefaults returns m with the entries of def it does not set.
+func withDefaults(m, def map[string]int) map[string]int {
+	out := make(map[string]int, len(def))
+	for k, v := range def {
+		out[k] = v
+	}
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
 }
 
 type Format struct {
 	Concise            bool `json:"concise"`
 	ShowKeyFingerprint bool `json:"show_key_fingerprint"`
-	ShowHostname       bool `json:"show_hostname"`
+	// ShowHostname defaults to true.
+	ShowHostname bool `json:"show_hostname"`
 }
 
 type Tele struct {
 	LogLevel string `json:"log_level"`
 	LogFile  string `json:"log_file"`
+	// HealthAddr, if set, serves source health as JSON on /healthz.
+	HealthAddr string `json:"health_addr"`
 }
 
 func Load(path string) (*Config, error) {
@@ -66,11 +336,36 @@ func Load(path string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	var c Config
+	// Defaults for booleans are set before decoding so false can be given.
+	c := Config{Formatting: Format{ShowHostname: true}}
 	if err := json.Unmarshal(b, &c); err != nil {
 		return nil, err
 	}
 	c.setDefaults()
+	// Template, rule, blocklist and holiday file paths are relative to the config file.
+	rel := func(p *string) {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(filepath.Dir(path), *p)
+		}
+	}
+	for _, n := range c.Notifiers {
+		for typ, p := range n.Templates {
+			rel(&p)
+			n.Templates[typ] = p
+		}
+	}
+	rel(&c.Rules.File)
+	rel(&c.Scoring.BlocklistFile)
+	for i := range c.Scoring.BusinessHours.Holidays {
+		rel(&c.Scoring.BusinessHours.Holidays[i])
+	}
+	for _, p := range c.Policies {
+		for _, h := range p.Allowed {
+			for i := range h.Holidays {
+				rel(&h.Holidays[i])
+			}
+		}
+	}
 	if err := c.validate(); err != nil {
 		return nil, err
 	}
@@ -87,6 +382,12 @@ func (c *Config) setDefaults() {
 	if len(c.Sources.SystemdUnits) == 0 {
 		c.Sources.SystemdUnits = []string{"sshd.service", "ssh.service"}
 	}
+	if len(c.Sources.UtmpPaths) == 0 {
+		c.Sources.UtmpPaths = []string{"/var/log/wtmp", "/var/log/btmp"}
+	}
+	if c.Sources.AuditLog == "" {
+		c.Sources.AuditLog = "/var/log/audit/audit.log"
+	}
 	if c.RateLimit.WindowSeconds == 0 {
 		c.RateLimit.WindowSeconds = 60
 	}
@@ -96,14 +397,182 @@ func (c *Config) setDefaults() {
 	if c.RateLimit.DedupWindowSeconds == 0 {
 		c.RateLimit.DedupWindowSeconds = 30
 	}
+	if c.Batch.WindowSeconds == 0 {
+		c.Batch.WindowSeconds = 3600
+	}
+	for i := range c.Notifiers {
+		if c.Notifiers[i].Name == "" {
+			c.Notifiers[i].Name = c.Notifiers[i].Type
+		}
+	}
+	if c.Queue.Dir == "" {
+		c.Queue.Dir = "/var/lib/ssh-noti/spool"
+	}
+	if c.Queue.MaxAgeSeconds == 0 {
+		c.Queue.MaxAgeSeconds = 6 * 3600
+	}
+	if c.Queue.MaxBackoffSeconds == 0 {
+		c.Queue.MaxBackoffSeconds = 300
+	}
+	if c.Dispatch.QueueSize == 0 {
+		c.Dispatch.QueueSize = 1000
+	}
+	if c.Dispatch.Overflow == "" {
+		c.Dispatch.Overflow = "block"
+	}
+	if c.Dispatch.DrainTimeoutSeconds == 0 {
+		c.Dispatch.DrainTimeoutSeconds = 10
+	}
+	if c.Silence.Path == "" {
+		c.Silence.Path = "/var/lib/ssh-noti/silences.json"
+	}
+	if c.Silence.Socket == "" {
+		c.Silence.Socket = "/run/ssh-noti/silence.sock"
+	}
 	if c.Telemetry.LogLevel == "" {
 		c.Telemetry.LogLevel = "INFO"
 	}
+	c.Scoring.Base = withDefaults(c.Scoring.Base, defaultBase)
+	c.Scoring.Bumps = withDefaults(c.Scoring.Bumps, defaultBumps)
+	c.Scoring.Thresholds = withDefaults(c.Scoring.Thresholds, defaultThresholds)
+	if c.Scoring.FailureWindowSeconds == 0 {
+		c.Scoring.FailureWindowSeconds = 600
+	}
 }
 
 func (c *Config) validate() error {
 	if c.Mode != "realtime" && c.Mode != "batch" && c.Mode != "both" && c.Mode != "" {
 		return errors.New("invalid mode")
 	}
+	switch c.Dispatch.Overflow {
+	case "drop_oldest", "drop_newest", "block":
+	default:
+		return fmt.Errorf("dispatch.overflow: invalid policy %q", c.Dispatch.Overflow)
+	}
+	if c.Interactive.Addr != "" && c.Interactive.SigningSecret == "" {
+		return errors.New("interactive.signing_secret is required with interactive.addr")
+	}
+	if (c.Interactive.TLSCert == "") != (c.Interactive.TLSKey == "") {
+		return errors.New("interactive.tls_cert and interactive.tls_key must be set together")
+	}
+	if c.Interactive.Addr != "" && c.Interactive.TLSCert == "" && !c.Interactive.PlainHTTP {
+		return errors.New("interactive.tls_cert and interactive.tls_key are required with interactive.addr (or set interactive.plain_http behind a TLS proxy)")
+	}
+	names := make(map[string]bool)
+	for i, n := range c.Notifiers {
+		if n.Type == "" {
+			return fmt.Errorf("notifiers[%d]: type is required", i)
+		}
+		if names[n.Name] {
+			return fmt.Errorf("notifiers[%d]: duplicate name %q", i, n.Name)
+		}
+		names[n.Name] = true
+		if len(n.Templates) > 0 && !slices.Contains(TemplatedTypes, n.Type) {
+			return fmt.Errorf("notifiers[%d] (%s): type %q does not support templates", i, n.Name, n.Type)
+		}
+		if _, err := tmpl.LoadSet(n.Templates); err != nil {
+			return fmt.Errorf("notifiers[%d] (%s): %w", i, n.Name, err)
+		}
+	}
+	sinks := make(map[string]bool)
+	for _, n := range c.Sinks() {
+		sinks[n.Name] = true
+	}
+	if err := c.Scoring.validate(); err != nil {
+		return fmt.Errorf("scoring: %w", err)
+	}
+	for i, r := range c.Routes {
+		if err := r.validate(sinks); err != nil {
+			return fmt.Errorf("routes[%d]: %w", i, err)
+		}
+	}
+	policies := make(map[string]bool)
+	for i, p := range c.Policies {
+		if p.Name == "" || policies[p.Name] {
+			return fmt.Errorf("policies[%d]: a unique name is required", i)
+		}
+		policies[p.Name] = true
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("policies[%d] (%s): %w", i, p.Name, err)
+		}
+	}
+	return nil
+}
+
+func (p Policy) validate() error {
+	for _, u := range p.Users {
+		if _, err := path.Match(u, ""); err != nil {
+			return fmt.Errorf("pattern %q: %w", u, err)
+		}
+	}
+	if len(p.Allowed) == 0 {
+		return errors.New("allowed needs at least one window")
+	}
+	for i, h := range p.Allowed {
+		if h.Start == "" || h.End == "" {
+			return fmt.Errorf("allowed[%d]: start and end are required", i)
+		}
+		if _, err := h.Window(); err != nil {
+			return fmt.Errorf("allowed[%d]: %w", i, err)
+		}
+	}
+	if p.Severity != "" {
+		if _, err := model.ParseSeverity(p.Severity); err != nil {
+			return fmt.Errorf("severity: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r Route) validate(sinks map[string]bool) error {
+	for _, n := range r.Notifiers {
+		if !sinks[n] {
+			return fmt.Errorf("unknown notifier %q", n)
+		}
+	}
+	for _, p := range append(append([]string(nil), r.Match.Users...), r.Match.Hosts...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("pattern %q: %w", p, err)
+		}
+	}
+	if err := checkPrefixes("cidrs", r.Match.CIDRs); err != nil {
+		return err
+	}
+	if r.Match.MinSeverity != "" {
+		if _, err := model.ParseSeverity(r.Match.MinSeverity); err != nil {
+			return fmt.Errorf("min_severity: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s Scoring) validate() error {
+	for k := range s.Bumps {
+		if _, ok := defaultBumps[k]; !ok {
+			return fmt.Errorf("unknown bump %q", k)
+		}
+	}
+	for k := range s.Thresholds {
+		if _, err := model.ParseSeverity(k); err != nil {
+			return fmt.Errorf("thresholds: %w", err)
+		}
+	}
+	if err := checkPrefixes("blocklist", s.Blocklist); err != nil {
+		return err
+	}
+	if _, err := s.BusinessHours.Window(); err != nil {
+		return fmt.Errorf("business_hours: %w", err)
+	}
+	return nil
+}
+
+func checkPrefixes(field string, list []string) error {
+	for _, p := range list {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			if _, err := netip.ParseAddr(p); err != nil {
+				return fmt.Errorf("%s: %q is not an address or CIDR", field, p)
+			}
+		}
+	}
 	return nil
 }
@@ -1,14 +1,33 @@
 package enrich
 
 import (
+	"bufio"
+	"fmt"
+	"net/netip"
 	"os"
+	"sort"
+	"strings"
+
 	"ssh-noty/internal/config"
+	"ssh-noty/internal/logging"
 	"ssh-noty/internal/model"
 )
 
-type Enricher struct{ cfg *config.Config }
+type Enricher struct {
+	cfg       *config.Config
+	countries []countryRange
+}
 
-func NewEnricher(cfg *config.Config) *Enricher { return &Enricher{cfg: cfg} }
+func NewEnricher(cfg *config.Config) *Enricher {
+	e := &Enricher{cfg: cfg}
+	if path := cfg.GeoIP.CountryCSV; path != "" {
+		var err error
+		if e.countries, err = loadCountries(path); err != nil {
+			logging.L().Warn("country lookup disabled", "path", path, "error", err)
+		}
+	}
+	return e
+}
 
 func (e *Enricher) Enrich(ev *model.Event) {
 	if ev.Hostname == "" {
@@ -16,4 +35,82 @@ func (e *Enricher) Enrich(ev *model.Event) {
 			ev.Hostname = h
 		}
 	}
+	if ev.Country == "" && len(e.countries) > 0 {
+		ev.Country = e.country(ev.SourceIP)
+	}
+}
+
+// countryRange is an inclusive address range of one country.
+type countryRange struct {
+	start, end netip.Addr
+	code       string
+}
+
+// loadCountries reads "start,end,CC" or "cidr,CC" lines, sorted by start.
+func loadCountries(path string) ([]countryRange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []countryRange
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		cols := strings.Split(strings.ReplaceAll(line, `"`, ""), ",")
+		var r countryRange
+		switch len(cols) {
+		case 2:
+			p, err := netip.ParsePrefix(cols[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			p = p.Masked()
+			r.start, r.end = p.Addr(), lastAddr(p)
+		case 3:
+			if r.start, err = netip.ParseAddr(cols[0]); err == nil {
+				r.end, err = netip.ParseAddr(cols[1])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: want start,end,country or cidr,country", n)
+		}
+		r.code = strings.ToUpper(strings.TrimSpace(cols[len(cols)-1]))
+		out = append(out, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].start.Less(out[j].start) })
+	return out, nil
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+func (e *Enricher) country(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	i := sort.Search(len(e.countries), func(i int) bool { return addr.Less(e.countries[i].start) })
+	if i == 0 {
+		return ""
+	}
+	if r := e.countries[i-1]; addr.Compare(r.end) <= 0 && addr.BitLen() == r.start.BitLen() {
+		return r.code
+	}
+	return ""
 }
@@ -0,0 +1,34 @@
+package enrich
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func TestEnrich_Country(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "countries.csv")
+	os.WriteFile(path, []byte(`"1.0.0.0","1.0.0.255","AU"
+203.0.113.0/25,es
+2001:db8::/32,de
+`), 0o644)
+	e := NewEnricher(&config.Config{GeoIP: config.GeoIP{CountryCSV: path}})
+	for ip, want := range map[string]string{
+		"1.0.0.7":            "AU",
+		"203.0.113.5":        "ES",
+		"::ffff:203.0.113.9": "ES",
+		"203.0.113.200":      "",
+		"2001:db8::1":        "DE",
+		"0.0.0.1":            "",
+		"not-an-ip":          "",
+	} {
+		ev := model.Event{SourceIP: ip, Hostname: "h"}
+		e.Enrich(&ev)
+		if ev.Country != want {
+			t.Fatalf("%s: got %q, want %q", ip, ev.Country, want)
+		}
+	}
+}
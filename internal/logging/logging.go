@@ -36,4 +36,10 @@ func parseLevel(l string) slog.Level {
 	}
 }
 
-func L() *slog.Logger { return logger }
+// L returns the configured logger, or slog's default before Setup runs.
+func L() *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
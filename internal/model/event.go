@@ -11,4 +11,11 @@ type Event struct {
 	KeyFingerprint string
 	Timestamp      time.Time
 	Hostname       string
+	// Country is the ISO code of SourceIP when GeoIP data is available.
+	Country  string
+	Severity Severity
+	// Fields carries source-specific details, e.g. audit auid and ses.
+	Fields map[string]string
+	// Tags are labels attached while processing, matched by routes.
+	Tags []string
 }
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity ranks how urgent an event is. The zero value means "not scored";
+// Event.Level then falls back to DefaultSeverity.
+type Severity int
+
+const (
+	SeverityUnset Severity = iota
+	SeverityInfo
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"unset", "info", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity accepts the lower-case names returned by String.
+func ParseSeverity(s string) (Severity, error) {
+	for i, n := range severityNames[1:] {
+		if strings.EqualFold(s, n) {
+			return Severity(i + 1), nil
+		}
+	}
+	return SeverityUnset, fmt.Errorf("unknown severity %q", s)
+}
+
+// DefaultSeverity is the severity of an event judged by its type alone.
+func DefaultSeverity(ev *Event) Severity {
+	switch ev.Type {
+	case "login_success":
+		if ev.Username == "root" {
+			return SeverityHigh
+		}
+		return SeverityMedium
+	case "invalid_user", "login_failure":
+		return SeverityLow
+	default:
+		return SeverityInfo
+	}
+}
+
+// Level returns the scored severity, or DefaultSeverity when unscored.
+func (e *Event) Level() Severity {
+	if e.Severity != SeverityUnset {
+		return e.Severity
+	}
+	return DefaultSeverity(e)
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Summary aggregates events over a window for batch digests.
+type Summary struct {
+	Hostname   string
+	Start      time.Time
+	End        time.Time
+	Counts     map[string]int
+	TopSources []Count
+	TopUsers   []Count
+	// Silenced counts the events each silence suppressed, so muted
+	// activity still shows up in digests.
+	Silenced []Count
+	// Violations counts policy violations and rule alerts, keyed
+	// "policy:<name>" and "rule:<name>".
+	Violations []Count
+}
+
+type Count struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+func (s *Summary) Total() int {
+	n := 0
+	for _, c := range s.Counts {
+		n += c
+	}
+	return n
+}
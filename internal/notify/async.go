@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"ssh-noty/internal/model"
+)
+
+// Overflow policies of an Async queue.
+const (
+	DropOldest = "drop_oldest"
+	DropNewest = "drop_newest"
+	Block      = "block"
+)
+
+// ErrQueueFull is returned by Async under DropNewest when the queue is full.
+var ErrQueueFull = errors.New("dispatch queue full")
+
+// Async decouples callers from a slow notifier: sends are queued in a
+// bounded FIFO and delivered in order by a single worker. When the queue is
+// full the overflow policy drops the oldest or the new notification, or
+// blocks the caller.
+type Async struct {
+	Notifier
+	size   int
+	policy string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    []func(context.Context) error
+	busy    bool
+	dropped int
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func NewAsync(n Notifier, size int, policy string) (*Async, error) {
+	switch policy {
+	case "":
+		policy = Block
+	case DropOldest, DropNewest, Block:
+	default:
+		return nil, fmt.Errorf("unknown overflow policy %q", policy)
+	}
+	if size <= 0 {
+		size = 1000
+	}
+	a := &Async{Notifier: n, size: size, policy: policy}
+	a.cond = sync.NewCond(&a.mu)
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	go a.work()
+	return a, nil
+}
+
+func (a *Async) SendEvent(ctx context.Context, ev *model.Event) error {
+	return a.enqueue(ctx, func(ctx context.Context) error { return a.Notifier.SendEvent(ctx, ev) })
+}
+
+func (a *Async) SendSummary(ctx context.Context, sum *model.Summary) error {
+	return a.enqueue(ctx, func(ctx context.Context) error { return a.Notifier.SendSummary(ctx, sum) })
+}
+
+func (a *Async) SendText(ctx context.Context, text string) error {
+	ts, ok := a.Notifier.(TextSender)
+	if !ok {
+		return nil
+	}
+	return a.enqueue(ctx, func(ctx context.Context) error { return ts.SendText(ctx, text) })
+}
+
+func (a *Async) CountsFailures() bool { return CountsFailures(a.Notifier) }
+
+func (a *Async) CountSilenced(ev *model.Event, label string) {
+	if sc, ok := a.Notifier.(SilenceCounter); ok {
+		sc.CountSilenced(ev, label)
+	}
+}
+
+func (a *Async) enqueue(ctx context.Context, job func(context.Context) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.jobs) >= a.size {
+		switch a.policy {
+		case DropNewest:
+			a.dropped++
+			return ErrQueueFull
+		case DropOldest:
+			a.jobs = a.jobs[1:]
+			a.dropped++
+			log().Warn("dispatch queue full, dropped oldest notification", "notifier", a.Name(), "dropped", a.dropped)
+		case Block:
+			stop := context.AfterFunc(ctx, a.broadcast)
+			defer stop()
+			for len(a.jobs) >= a.size && ctx.Err() == nil {
+				a.cond.Wait()
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	a.jobs = append(a.jobs, job)
+	a.cond.Broadcast()
+	return nil
+}
+
+func (a *Async) broadcast() {
+	a.mu.Lock()
+	a.cond.Broadcast()
+	a.mu.Unlock()
+}
+
+func (a *Async) work() {
+	for {
+		a.mu.Lock()
+		for len(a.jobs) == 0 {
+			a.cond.Wait()
+		}
+		job := a.jobs[0]
+		a.jobs = a.jobs[1:]
+		a.busy = true
+		ctx := a.ctx
+		a.mu.Unlock()
+
+		if err := job(ctx); err != nil {
+			log().Warn("failed to deliver notification", "notifier", a.Name(), "error", err)
+		}
+
+		a.mu.Lock()
+		a.busy = false
+		a.cond.Broadcast()
+		a.mu.Unlock()
+	}
+}
+
+// Flush waits until everything queued has been delivered, then flushes the
+// wrapped notifier. When ctx expires first, in-flight and queued deliveries
+// are cancelled (a spooled notifier keeps them on disk) and ctx.Err() is
+// returned.
+func (a *Async) Flush(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, a.broadcast)
+	defer stop()
+	a.mu.Lock()
+	for (len(a.jobs) > 0 || a.busy) && ctx.Err() == nil {
+		a.cond.Wait()
+	}
+	err := ctx.Err()
+	if err != nil {
+		log().Warn("dispatch drain deadline exceeded", "notifier", a.Name(), "pending", len(a.jobs))
+		a.cancel()
+		for len(a.jobs) > 0 || a.busy {
+			a.cond.Wait()
+		}
+		a.ctx, a.cancel = context.WithCancel(context.Background())
+	}
+	a.mu.Unlock()
+	if fl, ok := a.Notifier.(Flusher); ok {
+		err = errors.Join(err, fl.Flush(ctx))
+	}
+	return err
+}
+
+// Health adds the queue length and drop count to the notifier's health.
+func (a *Async) Health() Health {
+	h := a.Notifier.Health()
+	a.mu.Lock()
+	h.Queued += len(a.jobs)
+	h.Dropped = a.dropped
+	a.mu.Unlock()
+	return h
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+// gated blocks each delivery until release is closed or ctx ends.
+type gated struct {
+	healthTracker
+	release chan struct{}
+	mu      sync.Mutex
+	got     []string
+}
+
+func (g *gated) Name() string { return "gated" }
+
+func (g *gated) SendEvent(ctx context.Context, ev *model.Event) error {
+	select {
+	case <-g.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	g.mu.Lock()
+	g.got = append(g.got, ev.Username)
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *gated) SendSummary(context.Context, *model.Summary) error { return nil }
+
+func (g *gated) delivered() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return strings.Join(g.got, "")
+}
+
+func TestAsync_OrderAndDropOldest(t *testing.T) {
+	g := &gated{release: make(chan struct{})}
+	a, _ := NewAsync(g, 2, DropOldest)
+	ctx := context.Background()
+	start := time.Now()
+	for _, u := range []string{"a", "b", "c", "d", "e"} {
+		if err := a.SendEvent(ctx, &model.Event{Username: u}); err != nil {
+			t.Fatal(err)
+		}
+		if u == "a" {
+			time.Sleep(20 * time.Millisecond) // let the worker pick up "a"
+		}
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("sends should not wait for the notifier")
+	}
+	close(g.release)
+	if err := a.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// "a" was in flight; b and c were dropped to make room for d and e.
+	if got := g.delivered(); got != "ade" || a.Health().Dropped != 2 {
+		t.Fatalf("delivered %q, dropped %d", got, a.Health().Dropped)
+	}
+}
+
+func TestAsync_DropNewestAndBlock(t *testing.T) {
+	g := &gated{release: make(chan struct{})}
+	a, _ := NewAsync(g, 1, DropNewest)
+	ctx := context.Background()
+	a.SendEvent(ctx, &model.Event{Username: "a"})
+	time.Sleep(20 * time.Millisecond) // let the worker pick up "a"
+	a.SendEvent(ctx, &model.Event{Username: "b"})
+	if err := a.SendEvent(ctx, &model.Event{Username: "c"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	b, _ := NewAsync(g, 1, Block)
+	b.SendEvent(ctx, &model.Event{Username: "x"})
+	time.Sleep(20 * time.Millisecond)
+	b.SendEvent(ctx, &model.Event{Username: "y"})
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := b.SendEvent(short, &model.Event{Username: "z"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("block policy should wait for room until ctx ends, got %v", err)
+	}
+	close(g.release)
+	a.Flush(ctx)
+	b.Flush(ctx)
+}
+
+func TestAsync_FlushDeadlineCancelsDeliveries(t *testing.T) {
+	g := &gated{release: make(chan struct{})}
+	a, _ := NewAsync(g, 10, DropOldest)
+	for _, u := range []string{"a", "b", "c"} {
+		a.SendEvent(context.Background(), &model.Event{Username: u})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := a.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if h := a.Health(); h.Queued != 0 || g.delivered() != "" {
+		t.Fatalf("pending deliveries should have been cancelled: %+v %q", h, g.delivered())
+	}
+	// The queue keeps working after a timed-out drain.
+	close(g.release)
+	a.SendEvent(context.Background(), &model.Event{Username: "d"})
+	a.Flush(context.Background())
+	if g.delivered() != "d" {
+		t.Fatalf("delivered %q after drain", g.delivered())
+	}
+}
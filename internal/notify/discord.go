@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("discord", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		if nc.URL == "" {
+			return nil, errors.New("discord: url (webhook) is required")
+		}
+		return &Discord{name: nc.Name, url: nc.URL, client: &http.Client{Timeout: nc.Timeout(10 * time.Second)}}, nil
+	})
+}
+
+// Discord embed limits, see https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	discordMaxEmbeds      = 10
+	discordMaxMessageText = 6000
+	discordMaxDescription = 4096
+	discordMaxFieldValue  = 1024
+	// Retry a rate-limited request at most this often, waiting no longer
+	// than discordMaxRetryWait each time.
+	discordMaxRetries   = 3
+	discordMaxRetryWait = 30 * time.Second
+)
+
+// Discord posts embeds to a Discord channel webhook.
+type Discord struct {
+	healthTracker
+	templates
+	name   string
+	url    string
+	client *http.Client
+}
+
+func (d *Discord) Name() string { return d.name }
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color,omitempty"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+func (e discordEmbed) size() int {
+	n := len(e.Title) + len(e.Description)
+	for _, f := range e.Fields {
+		n += len(f.Name) + len(f.Value)
+	}
+	if e.Footer != nil {
+		n += len(e.Footer.Text)
+	}
+	return n
+}
+
+func (d *Discord) SendEvent(ctx context.Context, ev *model.Event) error {
+	// A text template is the message content, a JSON one the whole payload.
+	if out, err := d.tmpl.Render(ev); err != nil {
+		return d.track(err)
+	} else if out != nil {
+		var payload any = map[string]any{"content": out.Text}
+		if out.JSON {
+			payload = json.RawMessage(out.Text)
+		}
+		return d.track(d.post(ctx, payload))
+	}
+	embed := discordEmbed{
+		Title:     headline(ev),
+		Color:     severityColor(ev.Level()),
+		Timestamp: ev.Timestamp.UTC().Format(time.RFC3339),
+		Footer:    &discordFooter{Text: safe(ev.Hostname)},
+	}
+	for _, f := range eventFacts(ev) {
+		if f.Title == "Time" || f.Title == "Host" {
+			continue // shown as embed timestamp and footer
+		}
+		embed.Fields = append(embed.Fields, discordField{Name: f.Title, Value: "`" + truncate(f.Value, discordMaxFieldValue-2) + "`", Inline: true})
+	}
+	embed.Fields = append(embed.Fields, discordField{Name: "Severity", Value: ev.Level().String(), Inline: true})
+	return d.track(d.post(ctx, map[string]any{"embeds": []discordEmbed{embed}}))
+}
+
+// SendSummary renders the digest as description lines. Large digests are
+// split across embeds and, past ten embeds or 6000 characters, messages.
+func (d *Discord) SendSummary(ctx context.Context, sum *model.Summary) error {
+	types := sortedKeys(sum.Counts)
+	lines := []string{fmt.Sprintf("%s → %s", sum.Start.Format(time.RFC3339), sum.End.Format(time.RFC3339)), ""}
+	for _, k := range types {
+		lines = append(lines, fmt.Sprintf("**%s**: %d", k, sum.Counts[k]))
+	}
+	for _, sec := range summarySections(sum) {
+		lines = append(lines, "", "**"+sec.Title+"**")
+		for _, c := range sec.List {
+			lines = append(lines, fmt.Sprintf("`%s` %d", c.Key, c.Count))
+		}
+	}
+	title := fmt.Sprintf("📊 SSH SUMMARY %s", safe(sum.Hostname))
+	footer := &discordFooter{Text: safe(sum.Hostname)}
+	embeds := splitEmbeds(title, lines, footer, sum.End)
+	for _, msg := range batchEmbeds(embeds) {
+		if err := d.post(ctx, map[string]any{"embeds": msg}); err != nil {
+			return d.track(err)
+		}
+	}
+	return d.track(nil)
+}
+
+func (d *Discord) SendText(ctx context.Context, text string) error {
+	return d.track(d.post(ctx, map[string]any{"content": text}))
+}
+
+// splitEmbeds packs lines into embed descriptions of at most
+// discordMaxDescription characters; continuation embeds get a "(cont.)" title.
+func splitEmbeds(title string, lines []string, footer *discordFooter, ts time.Time) []discordEmbed {
+	var out []discordEmbed
+	cur := discordEmbed{Title: title, Color: 0x3498DB}
+	for _, l := range lines {
+		l = truncate(l, discordMaxDescription)
+		if len(cur.Description)+len(l)+1 > discordMaxDescription {
+			out = append(out, cur)
+			cur = discordEmbed{Title: title + " (cont.)", Color: 0x3498DB}
+		}
+		if cur.Description != "" {
+			cur.Description += "\n"
+		}
+		cur.Description += l
+	}
+	out = append(out, cur)
+	last := &out[len(out)-1]
+	last.Footer, last.Timestamp = footer, ts.UTC().Format(time.RFC3339)
+	return out
+}
+
+// batchEmbeds groups embeds into messages within Discord's per-message limits.
+func batchEmbeds(embeds []discordEmbed) [][]discordEmbed {
+	var out [][]discordEmbed
+	var cur []discordEmbed
+	size := 0
+	for _, e := range embeds {
+		if len(cur) == discordMaxEmbeds || (len(cur) > 0 && size+e.size() > discordMaxMessageText) {
+			out = append(out, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, e)
+		size += e.size()
+	}
+	if len(cur) > 0 {
+		out = append(out, cur)
+	}
+	return out
+}
+
+// post sends a webhook payload, waiting out 429 responses as instructed by
+// retry_after (seconds, in the JSON body) or the Retry-After header.
+func (d *Discord) post(ctx context.Context, payload any) error {
+	for attempt := 0; ; attempt++ {
+		err := doJSON(ctx, d.client, "discord", http.MethodPost, d.url, payload, nil, nil)
+		var se *StatusError
+		if !errors.As(err, &se) || se.Code != http.StatusTooManyRequests || attempt >= discordMaxRetries {
+			return err
+		}
+		var body struct {
+			RetryAfter float64 `json:"retry_after"`
+		}
+		wait := se.RetryAfter
+		if json.Unmarshal([]byte(se.Body), &body) == nil && body.RetryAfter > 0 {
+			wait = time.Duration(body.RetryAfter * float64(time.Second))
+			se.RetryAfter = wait
+		}
+		if wait <= 0 {
+			wait = time.Second
+		}
+		if wait > discordMaxRetryWait {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"ssh-noty/internal/model"
+)
+
+func TestDiscord_RetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"message":"You are being rate limited.","retry_after":0.05,"global":false}`)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := &Discord{name: "discord", url: srv.URL, client: srv.Client()}
+	ev := &model.Event{Type: "login_failure", Username: "root", SourceIP: "198.51.100.1", Port: 22, Method: "password", Hostname: "web1", Timestamp: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Severity: model.SeverityHigh}
+	start := time.Now()
+	if err := d.SendEvent(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 || time.Since(start) < 50*time.Millisecond {
+		t.Fatalf("expected one retry after 50ms, calls=%d", calls.Load())
+	}
+	embed := got["embeds"].([]any)[0].(map[string]any)
+	if int(embed["color"].(float64)) != 0xE74C3C || embed["timestamp"] != "2024-01-01T10:00:00Z" || embed["footer"].(map[string]any)["text"] != "web1" {
+		t.Fatalf("unexpected embed: %v", embed)
+	}
+	if fields := embed["fields"].([]any); len(fields) != 4 || !fields[0].(map[string]any)["inline"].(bool) {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+}
+
+func TestDiscord_SplitLargeSummary(t *testing.T) {
+	var messages []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p struct {
+			Embeds []discordEmbed `json:"embeds"`
+		}
+		json.NewDecoder(r.Body).Decode(&p)
+		total := 0
+		for _, e := range p.Embeds {
+			if len(e.Description) > discordMaxDescription {
+				t.Errorf("description too long: %d", len(e.Description))
+			}
+			total += e.size()
+		}
+		if len(p.Embeds) > discordMaxEmbeds || total > discordMaxMessageText {
+			t.Errorf("message over limits: %d embeds, %d chars", len(p.Embeds), total)
+		}
+		messages = append(messages, len(p.Embeds))
+	}))
+	defer srv.Close()
+
+	sum := &model.Summary{Hostname: "web1", Counts: map[string]int{"login_failure": 5000}}
+	for i := 0; i < 2000; i++ {
+		sum.TopSources = append(sum.TopSources, model.Count{Key: fmt.Sprintf("198.51.%d.%d", i/256, i%256), Count: 2000 - i})
+	}
+	d := &Discord{name: "discord", url: srv.URL, client: srv.Client()}
+	if err := d.SendSummary(context.Background(), sum); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) < 2 {
+		t.Fatalf("expected the digest to span several messages, got %v", messages)
+	}
+}
+
+func TestDiscord_TruncatesOnRuneBoundary(t *testing.T) {
+	long := strings.Repeat("é", discordMaxDescription) // two bytes each
+	embeds := splitEmbeds("t", []string{long}, nil, time.Now())
+	if d := embeds[0].Description; len(d) > discordMaxDescription || !utf8.ValidString(d) {
+		t.Fatalf("description of %d bytes, valid UTF-8 %v", len(d), utf8.ValidString(d))
+	}
+	if got := truncate("añb", 2); got != "a" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,495 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("smtp", newEmail)
+}
+
+func newEmail(nc config.Notifier, _ *config.Config) (Notifier, error) {
+	u, err := neturl.Parse(nc.URL)
+	if err != nil || (u.Scheme != "smtp" && u.Scheme != "smtps") || u.Hostname() == "" {
+		return nil, errors.New("smtp: url must be smtp://host:port or smtps://host:port")
+	}
+	if nc.From == "" || len(nc.To) == 0 {
+		return nil, errors.New("smtp: from and to are required")
+	}
+	e := &Email{
+		name:     nc.Name,
+		addr:     u.Host,
+		host:     u.Hostname(),
+		implicit: u.Scheme == "smtps",
+		user:     nc.Username,
+		pass:     nc.Password,
+		auth:     strings.ToLower(nc.Auth),
+		from:     nc.From,
+		to:       nc.To,
+		batch:    time.Duration(nc.BatchSeconds) * time.Second,
+		timeout:  nc.Timeout(30 * time.Second),
+	}
+	if u.Port() == "" {
+		port := "587"
+		if e.implicit {
+			port = "465"
+		}
+		e.addr = net.JoinHostPort(e.host, port)
+	}
+	switch nc.TLS {
+	case "", "starttls":
+	case "none":
+		if e.implicit {
+			return nil, errors.New("smtp: tls none conflicts with smtps://")
+		}
+		e.plaintext = true
+	default:
+		return nil, fmt.Errorf("smtp: unknown tls mode %q", nc.TLS)
+	}
+	if e.auth != "" && e.auth != "plain" && e.auth != "login" {
+		return nil, fmt.Errorf("smtp: unknown auth %q (plain, login)", nc.Auth)
+	}
+	return e, nil
+}
+
+// Email delivers multipart/alternative (text + HTML) mail over SMTP. With a
+// batch window, realtime events arriving within it are combined into one
+// message; call Flush on shutdown to send what is pending.
+type Email struct {
+	healthTracker
+	templates
+	name      string
+	addr      string
+	host      string
+	implicit  bool
+	plaintext bool
+	tlsConfig *tls.Config
+	user      string
+	pass      string
+	auth      string
+	from      string
+	to        []string
+	batch     time.Duration
+	timeout   time.Duration
+
+	mu       sync.Mutex
+	pending  []model.Event
+	silenced map[string]int
+	timer    *time.Timer
+	batchErr func(*Batch, error)
+}
+
+func (e *Email) Name() string { return e.name }
+
+func (e *Email) SendEvent(ctx context.Context, ev *model.Event) error {
+	if e.batch <= 0 {
+		return e.track(e.sendEvents(ctx, []model.Event{*ev}, nil))
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending = append(e.pending, *ev)
+	e.armLocked()
+	return nil
+}
+
+func (e *Email) armLocked() {
+	if e.timer != nil {
+		return
+	}
+	e.timer = time.AfterFunc(e.batch, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+		defer cancel()
+		if err := e.Flush(ctx); err != nil {
+			log().Warn("failed to send email batch, retrying with the next one", "notifier", e.name, "error", err)
+		}
+	})
+}
+
+// CountSilenced counts ev for the next batch, which lists how many events
+// each silence suppressed meanwhile. Counts alone also start a batch so
+// that they are reported on a quiet host.
+func (e *Email) CountSilenced(_ *model.Event, label string) {
+	if e.batch <= 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.silenced == nil {
+		e.silenced = make(map[string]int)
+	}
+	e.silenced[label]++
+	e.armLocked()
+}
+
+// SendBatch sends b as one message right away.
+func (e *Email) SendBatch(ctx context.Context, b *Batch) error {
+	return e.track(e.sendEvents(ctx, b.Events, b.Silenced))
+}
+
+// OnBatchError sets the handler for batches that failed to send.
+func (e *Email) OnBatchError(h func(*Batch, error)) {
+	e.mu.Lock()
+	e.batchErr = h
+	e.mu.Unlock()
+}
+
+// Flush sends the events buffered by the batch window and the silenced
+// counts, if any. A failed batch goes to the OnBatchError handler, or back
+// into the buffer.
+func (e *Email) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	if len(e.pending) == 0 && len(e.silenced) == 0 {
+		e.mu.Unlock()
+		return nil
+	}
+	b := &Batch{Events: e.pending}
+	for _, k := range sortedKeys(e.silenced) {
+		b.Silenced = append(b.Silenced, model.Count{Key: k, Count: e.silenced[k]})
+	}
+	e.pending, e.silenced = nil, nil
+	e.mu.Unlock()
+	err := e.SendBatch(ctx, b)
+	if err == nil {
+		return nil
+	}
+	e.mu.Lock()
+	h := e.batchErr
+	if h == nil {
+		e.pending = append(b.Events, e.pending...)
+		for _, c := range b.Silenced {
+			if e.silenced == nil {
+				e.silenced = make(map[string]int)
+			}
+			e.silenced[c.Key] += c.Count
+		}
+		e.armLocked()
+	}
+	e.mu.Unlock()
+	if h != nil {
+		h(b, err)
+		return nil
+	}
+	return err
+}
+
+func (e *Email) SendSummary(ctx context.Context, sum *model.Summary) error {
+	subject := fmt.Sprintf("[ssh-noti] SSH summary for %s: %d events", safe(sum.Hostname), sum.Total())
+	var text strings.Builder
+	fmt.Fprintf(&text, "SSH summary for %s\n%s - %s\n\n", safe(sum.Hostname), sum.Start.Format(time.RFC3339), sum.End.Format(time.RFC3339))
+	types := sortedKeys(sum.Counts)
+	for _, k := range types {
+		fmt.Fprintf(&text, "%-16s %d\n", k, sum.Counts[k])
+	}
+	for _, sec := range summarySections(sum) {
+		fmt.Fprintf(&text, "\n%s:\n", sec.Title)
+		for _, c := range sec.List {
+			fmt.Fprintf(&text, "  %-40s %d\n", c.Key, c.Count)
+		}
+	}
+	var html bytes.Buffer
+	if err := summaryHTML.Execute(&html, map[string]any{"S": sum, "Types": types}); err != nil {
+		return err
+	}
+	return e.track(e.deliver(ctx, subject, text.String(), html.String()))
+}
+
+func (e *Email) SendText(ctx context.Context, text string) error {
+	var html bytes.Buffer
+	textHTML.Execute(&html, text)
+	return e.track(e.deliver(ctx, "[ssh-noti] "+firstLine(text), text, html.String()))
+}
+
+func (e *Email) sendEvents(ctx context.Context, evs []model.Event, silenced []model.Count) error {
+	if len(evs) == 0 {
+		return e.sendSilenced(ctx, silenced)
+	}
+	subject := fmt.Sprintf("[ssh-noti] %s: %s from %s", headline(&evs[0]), safe(evs[0].Username), safe(evs[0].SourceIP))
+	if len(evs) > 1 {
+		subject = fmt.Sprintf("[ssh-noti] %d SSH events on %s", len(evs), safe(evs[0].Hostname))
+	}
+	if text, ok, err := e.renderEvents(evs); err != nil || ok {
+		if err != nil {
+			return err
+		}
+		text += silencedText(silenced)
+		var html bytes.Buffer
+		textHTML.Execute(&html, text)
+		return e.deliver(ctx, subject, text, html.String())
+	}
+	var text strings.Builder
+	for i := range evs {
+		ev := &evs[i]
+		fmt.Fprintf(&text, "%s\n", headline(ev))
+		for _, f := range eventFacts(ev) {
+			fmt.Fprintf(&text, "  %-7s %s\n", f.Title+":", f.Value)
+		}
+		fmt.Fprintf(&text, "  %-7s %s\n\n", "Level:", ev.Level())
+	}
+	text.WriteString(silencedText(silenced))
+	rows := make([]map[string]string, 0, len(evs))
+	for i := range evs {
+		ev := &evs[i]
+		row := map[string]string{"Headline": headline(ev), "Severity": ev.Level().String()}
+		for _, f := range eventFacts(ev) {
+			row[f.Title] = f.Value
+		}
+		rows = append(rows, row)
+	}
+	var html bytes.Buffer
+	if err := eventsHTML.Execute(&html, map[string]any{"Rows": rows, "Silenced": silenced}); err != nil {
+		return err
+	}
+	return e.deliver(ctx, subject, text.String(), html.String())
+}
+
+// sendSilenced sends a batch that only has silenced counts.
+func (e *Email) sendSilenced(ctx context.Context, silenced []model.Count) error {
+	total := 0
+	for _, c := range silenced {
+		total += c.Count
+	}
+	var html bytes.Buffer
+	if err := eventsHTML.Execute(&html, map[string]any{"Silenced": silenced}); err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("[ssh-noti] %d SSH events silenced", total)
+	return e.deliver(ctx, subject, strings.TrimPrefix(silencedText(silenced), "\n"), html.String())
+}
+
+// silencedText lists the silenced counts of a batch.
+func silencedText(silenced []model.Count) string {
+	if len(silenced) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\nSilenced meanwhile:\n")
+	for _, c := range silenced {
+		fmt.Fprintf(&b, "  %-40s %d\n", c.Key, c.Count)
+	}
+	return b.String()
+}
+
+// renderEvents joins the templated text of evs; ok is false unless every
+// event has a template.
+func (e *Email) renderEvents(evs []model.Event) (string, bool, error) {
+	parts := make([]string, 0, len(evs))
+	for i := range evs {
+		text, ok, err := e.render(&evs[i])
+		if err != nil || !ok {
+			return "", false, err
+		}
+		parts = append(parts, strings.TrimRight(text, "\n"))
+	}
+	return strings.Join(parts, "\n\n") + "\n", true, nil
+}
+
+var eventsHTML = template.Must(template.New("events").Parse(`<html><body>
+{{if .Rows}}<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse;font-family:sans-serif;font-size:13px">
+<tr><th>Event</th><th>User</th><th>Source</th><th>Method</th><th>Host</th><th>Time</th><th>Severity</th></tr>
+{{range .Rows}}<tr><td>{{.Headline}}</td><td>{{.User}}</td><td>{{.Source}}</td><td>{{.Method}}</td><td>{{.Host}}</td><td>{{.Time}}</td><td>{{.Severity}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Silenced}}<h4>Silenced meanwhile</h4>
+<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse;font-family:sans-serif;font-size:13px">
+<tr><th>Silence</th><th>Events</th></tr>
+{{range .Silenced}}<tr><td>{{.Key}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+</body></html>
+`))
+
+var summaryHTML = template.Must(template.New("summary").Parse(`<html><body style="font-family:sans-serif;font-size:13px">
+<h3>SSH summary for {{.S.Hostname}}</h3>
+<p>{{.S.Start.Format "2006-01-02T15:04:05Z07:00"}} &ndash; {{.S.End.Format "2006-01-02T15:04:05Z07:00"}}</p>
+<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse">
+<tr><th>Event type</th><th>Count</th></tr>
+{{range .Types}}<tr><td>{{.}}</td><td>{{index $.S.Counts .}}</td></tr>
+{{end}}</table>
+{{if .S.TopSources}}<h4>Top sources</h4>
+<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse">
+<tr><th>Source</th><th>Count</th></tr>
+{{range .S.TopSources}}<tr><td>{{.Key}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+{{if .S.TopUsers}}<h4>Top users</h4>
+<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse">
+<tr><th>User</th><th>Count</th></tr>
+{{range .S.TopUsers}}<tr><td>{{.Key}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+{{if .S.Violations}}<h4>Violations</h4>
+<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse">
+<tr><th>Policy or rule</th><th>Events</th></tr>
+{{range .S.Violations}}<tr><td>{{.Key}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+{{if .S.Silenced}}<h4>Silenced</h4>
+<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse">
+<tr><th>Silence</th><th>Events</th></tr>
+{{range .S.Silenced}}<tr><td>{{.Key}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+</body></html>
+`))
+
+var textHTML = template.Must(template.New("text").Parse(`<html><body><pre>{{.}}</pre></body></html>`))
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// buildMessage renders RFC 5322 headers and a multipart/alternative body.
+func (e *Email) buildMessage(subject, text, html string) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ ctype, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.ctype},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		qp.Write([]byte(part.content))
+		qp.Close()
+	}
+	mw.Close()
+
+	id := make([]byte, 12)
+	rand.Read(id)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%x@ssh-noti>\r\n", id)
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+func (e *Email) deliver(ctx context.Context, subject, text, html string) error {
+	msg, err := e.buildMessage(subject, text, html)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	tlsConfig := e.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: e.host}
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	if e.implicit {
+		tc := tls.Client(conn, tlsConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tc
+	}
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if !e.implicit && !e.plaintext {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp: server does not offer STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if e.user != "" {
+		var a smtp.Auth = smtp.PlainAuth("", e.user, e.pass, e.host)
+		if e.auth == "login" {
+			a = &loginAuth{user: e.user, pass: e.pass, host: e.host}
+		}
+		if err := c.Auth(a); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	for _, rcpt := range e.to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("rcpt %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// loginAuth implements the non-standard but widespread AUTH LOGIN mechanism.
+type loginAuth struct {
+	user, pass, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like smtp.PlainAuth, refuse to send credentials in the clear.
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, errors.New("smtp: unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("smtp: wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:", "user name", "username":
+		return []byte(a.user), nil
+	case "password:", "password":
+		return []byte(a.pass), nil
+	}
+	return nil, fmt.Errorf("smtp: unexpected LOGIN challenge %q", fromServer)
+}
@@ -0,0 +1,344 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+// smtpServer is a minimal in-process SMTP server supporting STARTTLS and
+// AUTH PLAIN/LOGIN. It records the envelope and data of each message.
+type smtpServer struct {
+	ln       net.Listener
+	tls      *tls.Config
+	implicit bool
+
+	mu    sync.Mutex
+	auths []string
+	rcpts [][]string
+	msgs  []string
+}
+
+func newSMTPServer(t *testing.T, implicit bool) *smtpServer {
+	t.Helper()
+	cert := selfSignedCert(t)
+	s := &smtpServer{tls: &tls.Config{Certificates: []tls.Certificate{cert}}, implicit: implicit}
+	var err error
+	if implicit {
+		s.ln, err = tls.Listen("tcp", "127.0.0.1:0", s.tls)
+	} else {
+		s.ln, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.ln.Close() })
+	go func() {
+		for {
+			c, err := s.ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) clientTLS() *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.tls.Certificates[0].Leaf)
+	return &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+}
+
+func (s *smtpServer) serve(c net.Conn) {
+	defer c.Close()
+	tp := textproto.NewConn(c)
+	secure := s.implicit
+	var rcpts []string
+	tp.PrintfLine("220 test ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			ext := []string{"250-test", "250-AUTH PLAIN LOGIN"}
+			if !secure {
+				ext = append(ext, "250-STARTTLS")
+			}
+			ext = append(ext, "250 8BITMIME")
+			for _, l := range ext {
+				tp.PrintfLine("%s", l)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 go ahead")
+			tc := tls.Server(c, s.tls)
+			if tc.Handshake() != nil {
+				return
+			}
+			c, tp, secure = tc, textproto.NewConn(tc), true
+		case "AUTH":
+			f := strings.Fields(line)
+			var creds string
+			switch strings.ToUpper(f[1]) {
+			case "PLAIN":
+				b, _ := base64.StdEncoding.DecodeString(f[2])
+				creds = "PLAIN " + strings.ReplaceAll(string(b), "\x00", "|")
+			case "LOGIN":
+				tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+				u, _ := tp.ReadLine()
+				tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+				p, _ := tp.ReadLine()
+				ub, _ := base64.StdEncoding.DecodeString(u)
+				pb, _ := base64.StdEncoding.DecodeString(p)
+				creds = "LOGIN " + string(ub) + "|" + string(pb)
+			}
+			s.mu.Lock()
+			s.auths = append(s.auths, creds)
+			s.mu.Unlock()
+			tp.PrintfLine("235 ok")
+		case "MAIL":
+			rcpts = nil
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			rcpts = append(rcpts, strings.Trim(strings.SplitN(line, ":", 2)[1], "<> "))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 send data")
+			b, _ := io.ReadAll(tp.DotReader())
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, rcpts)
+			s.msgs = append(s.msgs, string(b))
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 unknown")
+		}
+	}
+}
+
+func (s *smtpServer) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.msgs...)
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// parts decodes the text and HTML alternatives of a message.
+func parts(t *testing.T, raw string) (*mail.Message, map[string]string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/alternative" {
+		t.Fatalf("unexpected content type %q", msg.Header.Get("Content-Type"))
+	}
+	out := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if err != nil {
+			break
+		}
+		b, _ := io.ReadAll(quotedprintable.NewReader(p))
+		ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		out[ct] = string(b)
+	}
+	return msg, out
+}
+
+func testEvent(user string) *model.Event {
+	return &model.Event{Type: "login_success", Username: user, SourceIP: "203.0.113.5", Port: 5000, Method: "publickey", Hostname: "web1", Timestamp: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+}
+
+func TestEmail_StartTLSAuthPlain(t *testing.T) {
+	srv := newSMTPServer(t, false)
+	e := &Email{
+		name: "mail", addr: srv.ln.Addr().String(), host: "127.0.0.1", tlsConfig: srv.clientTLS(),
+		user: "bot", pass: "s3cret", from: "ssh-noti@example.com", to: []string{"a@example.com", "b@example.com"},
+		timeout: 5 * time.Second,
+	}
+	if err := e.SendEvent(context.Background(), testEvent("alice")); err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.messages()
+	if len(msgs) != 1 || len(srv.rcpts[0]) != 2 || srv.auths[0] != "PLAIN |bot|s3cret" {
+		t.Fatalf("unexpected delivery: msgs=%d rcpts=%v auths=%v", len(msgs), srv.rcpts, srv.auths)
+	}
+	msg, body := parts(t, msgs[0])
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if !strings.Contains(subject, "SSH LOGIN SUCCESS: alice from 203.0.113.5") {
+		t.Fatalf("unexpected subject %q", subject)
+	}
+	if !strings.Contains(body["text/plain"], "User:   alice") || !strings.Contains(body["text/html"], "<td>alice</td>") {
+		t.Fatalf("unexpected bodies: %v", body)
+	}
+}
+
+func TestEmail_ImplicitTLSAuthLoginBatch(t *testing.T) {
+	srv := newSMTPServer(t, true)
+	e := &Email{
+		name: "mail", addr: srv.ln.Addr().String(), host: "127.0.0.1", implicit: true, tlsConfig: srv.clientTLS(),
+		user: "bot", pass: "pw", auth: "login", from: "ssh-noti@example.com", to: []string{"ops@example.com"},
+		batch: time.Hour, timeout: 5 * time.Second,
+	}
+	for _, u := range []string{"alice", "<script>"} {
+		if err := e.SendEvent(context.Background(), testEvent(u)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.CountSilenced(testEvent("bob"), "a1b2c3 pentest")
+	if len(srv.messages()) != 0 {
+		t.Fatal("batched events should wait for the window")
+	}
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.messages()
+	if len(msgs) != 1 || srv.auths[0] != "LOGIN bot|pw" {
+		t.Fatalf("expected one combined message via AUTH LOGIN: %d %v", len(msgs), srv.auths)
+	}
+	msg, body := parts(t, msgs[0])
+	if msg.Header.Get("Subject") != "[ssh-noti] 2 SSH events on web1" {
+		t.Fatalf("unexpected subject %q", msg.Header.Get("Subject"))
+	}
+	if strings.Count(body["text/html"], "<tr><td>🔐") != 2 || !strings.Contains(body["text/html"], "&lt;script&gt;") {
+		t.Fatalf("html should list both events escaped:\n%s", body["text/html"])
+	}
+	if !strings.Contains(body["text/html"], "<h4>Silenced meanwhile</h4>") || !strings.Contains(body["text/plain"], "Silenced meanwhile:\n  a1b2c3 pentest") {
+		t.Fatalf("batch should report silenced events:\n%s", body["text/plain"])
+	}
+}
+
+func TestEmail_FlushSendsSilencedCounts(t *testing.T) {
+	srv := newSMTPServer(t, false)
+	e := &Email{name: "mail", addr: srv.ln.Addr().String(), host: "127.0.0.1", tlsConfig: srv.clientTLS(), from: "a@example.com", to: []string{"b@example.com"}, batch: time.Hour, timeout: 5 * time.Second}
+	e.CountSilenced(testEvent("bob"), "a1b2c3 pentest")
+	e.CountSilenced(testEvent("bob"), "a1b2c3 pentest")
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.messages()
+	if len(msgs) != 1 {
+		t.Fatalf("silenced counts should be sent on flush, got %d messages", len(msgs))
+	}
+	msg, body := parts(t, msgs[0])
+	if msg.Header.Get("Subject") != "[ssh-noti] 2 SSH events silenced" || !strings.Contains(body["text/plain"], "a1b2c3 pentest") || strings.Contains(body["text/html"], "<th>Event</th>") {
+		t.Fatalf("unexpected message %q:\n%v", msg.Header.Get("Subject"), body)
+	}
+}
+
+func TestEmail_SummaryTable(t *testing.T) {
+	srv := newSMTPServer(t, false)
+	e := &Email{name: "mail", addr: srv.ln.Addr().String(), host: "127.0.0.1", tlsConfig: srv.clientTLS(), from: "a@example.com", to: []string{"b@example.com"}, timeout: 5 * time.Second}
+	sum := &model.Summary{Hostname: "web1", Counts: map[string]int{"login_failure": 7}, TopSources: []model.Count{{Key: "198.51.100.1", Count: 7}}, Silenced: []model.Count{{Key: "a1b2c3 pentest", Count: 3}}}
+	if err := e.SendSummary(context.Background(), sum); err != nil {
+		t.Fatal(err)
+	}
+	_, body := parts(t, srv.messages()[0])
+	if !strings.Contains(body["text/html"], "<tr><td>login_failure</td><td>7</td></tr>") || !strings.Contains(body["text/html"], "<td>198.51.100.1</td>") {
+		t.Fatalf("unexpected summary html:\n%s", body["text/html"])
+	}
+	if !strings.Contains(body["text/html"], "<h4>Silenced</h4>") || !strings.Contains(body["text/plain"], "Silenced:\n  a1b2c3 pentest") {
+		t.Fatalf("summary should report silenced events:\n%s", body["text/plain"])
+	}
+	if !strings.Contains(body["text/plain"], "login_failure    7") {
+		t.Fatalf("unexpected summary text:\n%s", body["text/plain"])
+	}
+}
+
+func TestEmail_RequiresStartTLS(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		w := bufio.NewWriter(c)
+		r := bufio.NewReader(c)
+		w.WriteString("220 plain\r\n")
+		w.Flush()
+		r.ReadString('\n')
+		w.WriteString("250 plain\r\n")
+		w.Flush()
+		r.ReadString('\n')
+	}()
+	e := &Email{name: "mail", addr: ln.Addr().String(), host: "127.0.0.1", from: "a@example.com", to: []string{"b@example.com"}, timeout: 2 * time.Second}
+	if err := e.SendText(context.Background(), "hi"); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("expected STARTTLS error, got %v", err)
+	}
+}
+
+func TestEmail_FailedBatchIsKept(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close() // connections are refused
+	e := &Email{name: "mail", addr: addr, host: "127.0.0.1", from: "a@example.com", to: []string{"b@example.com"}, batch: time.Hour, timeout: time.Second}
+	e.SendEvent(context.Background(), testEvent("alice"))
+	if err := e.Flush(context.Background()); err == nil {
+		t.Fatal("expected the flush to fail")
+	}
+	e.mu.Lock()
+	kept := len(e.pending)
+	e.mu.Unlock()
+	if kept != 1 {
+		t.Fatalf("failed batch should stay buffered, have %d events", kept)
+	}
+	var handed *Batch
+	e.OnBatchError(func(b *Batch, err error) { handed = b })
+	if err := e.Flush(context.Background()); err != nil || handed == nil || len(handed.Events) != 1 || handed.Events[0].Username != "alice" {
+		t.Fatalf("failed batch should go to the handler: %v %v", err, handed)
+	}
+}
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("exec", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		if len(nc.Command) == 0 {
+			return nil, errors.New("exec: command is required")
+		}
+		n := nc.Concurrency
+		if n <= 0 {
+			n = 4
+		}
+		return &Exec{name: nc.Name, command: nc.Command, timeout: nc.Timeout(30 * time.Second), slots: make(chan struct{}, n)}, nil
+	})
+}
+
+// Exec runs a command for each event with the event as JSON on stdin and
+// its fields in SSH_NOTI_* environment variables. At most cap(slots)
+// commands run at once; each is killed after timeout.
+type Exec struct {
+	healthTracker
+	name    string
+	command []string
+	timeout time.Duration
+	slots   chan struct{}
+}
+
+func (e *Exec) Name() string { return e.name }
+
+func (e *Exec) SendEvent(ctx context.Context, ev *model.Event) error {
+	env := []string{
+		"SSH_NOTI_TYPE=" + ev.Type,
+		"SSH_NOTI_USER=" + ev.Username,
+		"SSH_NOTI_SOURCE_IP=" + ev.SourceIP,
+		fmt.Sprintf("SSH_NOTI_PORT=%d", ev.Port),
+		"SSH_NOTI_METHOD=" + ev.Method,
+		"SSH_NOTI_KEY_FINGERPRINT=" + ev.KeyFingerprint,
+		"SSH_NOTI_HOST=" + ev.Hostname,
+		"SSH_NOTI_TIME=" + ev.Timestamp.Format(time.RFC3339),
+		"SSH_NOTI_SEVERITY=" + ev.Level().String(),
+	}
+	return e.track(e.run(ctx, eventRecord(ev), env))
+}
+
+func (e *Exec) SendSummary(ctx context.Context, sum *model.Summary) error {
+	return e.track(e.run(ctx, summaryRecord(sum), []string{"SSH_NOTI_HOST=" + sum.Hostname}))
+}
+
+func (e *Exec) SendText(ctx context.Context, text string) error {
+	return e.track(e.run(ctx, textRecord(text), []string{"SSH_NOTI_TEXT=" + text}))
+}
+
+func (e *Exec) run(ctx context.Context, rec *record, env []string) error {
+	stdin, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	select {
+	case e.slots <- struct{}{}:
+		defer func() { <-e.slots }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, e.command[0], e.command[1:]...)
+	cmd.Env = append(os.Environ(), append(env, "SSH_NOTI_KIND="+rec.Kind)...)
+	cmd.Stdin = bytes.NewReader(append(stdin, '\n'))
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	// Don't wait for children that inherited the output pipes.
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", e.timeout)
+		}
+		if msg := strings.TrimSpace(out.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, truncate(msg, 512))
+		}
+		return fmt.Errorf("exec %s: %w", e.command[0], err)
+	}
+	return nil
+}
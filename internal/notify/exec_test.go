@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExec_EnvAndStdin(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	e := &Exec{name: "exec", timeout: 5 * time.Second, slots: make(chan struct{}, 1),
+		command: []string{"/bin/sh", "-c", `{ echo "$SSH_NOTI_KIND $SSH_NOTI_USER $SSH_NOTI_SOURCE_IP $SSH_NOTI_SEVERITY"; cat; } > "$0"`, out}}
+	if err := e.SendEvent(context.Background(), testEvent("alice")); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(out)
+	lines := strings.SplitN(string(b), "\n", 2)
+	if lines[0] != "event alice 203.0.113.5 medium" || !strings.Contains(lines[1], `"user":"alice"`) {
+		t.Fatalf("unexpected output %q", b)
+	}
+}
+
+func TestExec_TimeoutAndFailure(t *testing.T) {
+	e := &Exec{name: "exec", timeout: 100 * time.Millisecond, slots: make(chan struct{}, 1), command: []string{"/bin/sh", "-c", "sleep 5"}}
+	start := time.Now()
+	if err := e.SendText(context.Background(), "x"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Fatal("command was not killed on timeout")
+	}
+	e = &Exec{name: "exec", timeout: time.Second, slots: make(chan struct{}, 1), command: []string{"/bin/sh", "-c", "echo boom >&2; exit 3"}}
+	if err := e.SendText(context.Background(), "x"); err == nil || !strings.Contains(err.Error(), "boom") || e.Health().OK() {
+		t.Fatalf("expected failure with stderr, got %v", err)
+	}
+}
+
+func TestExec_ConcurrencyLimit(t *testing.T) {
+	e := &Exec{name: "exec", timeout: 5 * time.Second, slots: make(chan struct{}, 2), command: []string{"/bin/sh", "-c", "sleep 0.2"}}
+	start := time.Now()
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if e.SendText(context.Background(), "x") != nil {
+				failed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	// Six runs two at a time take at least three rounds.
+	if failed.Load() != 0 || time.Since(start) < 600*time.Millisecond {
+		t.Fatalf("failed=%d elapsed=%s", failed.Load(), time.Since(start))
+	}
+}
@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("file", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		if nc.Path == "" {
+			return nil, errors.New("file: path is required")
+		}
+		f := &File{name: nc.Name, path: nc.Path, maxSize: int64(nc.MaxSizeMB) << 20, maxBackups: nc.MaxBackups}
+		if f.maxSize <= 0 {
+			f.maxSize = 100 << 20
+		}
+		if f.maxBackups <= 0 {
+			f.maxBackups = 5
+		}
+		return f, nil
+	})
+}
+
+// File appends one JSON object per line. Once the file would grow past
+// maxSize it is renamed to path.1 (shifting older backups up to
+// path.<maxBackups>) and a new file is started.
+type File struct {
+	healthTracker
+	name       string
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func (f *File) Name() string { return f.name }
+
+func (f *File) SendEvent(_ context.Context, ev *model.Event) error {
+	return f.track(f.write(eventRecord(ev)))
+}
+
+func (f *File) SendSummary(_ context.Context, sum *model.Summary) error {
+	return f.track(f.write(summaryRecord(sum)))
+}
+
+func (f *File) SendText(_ context.Context, text string) error {
+	return f.track(f.write(textRecord(text)))
+}
+
+func (f *File) write(rec *record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	if f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	n, err := f.f.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+func (f *File) open() error {
+	fh, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return err
+	}
+	st, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return err
+	}
+	f.f, f.size = fh, st.Size()
+	return nil
+}
+
+func (f *File) rotate() error {
+	f.f.Close()
+	f.f = nil
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	return os.Rename(f.path, f.path+".1")
+}
+
+// Flush syncs the current file to disk.
+func (f *File) Flush(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	return f.f.Sync()
+}
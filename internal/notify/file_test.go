@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFile_AppendAndRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f := &File{name: "file", path: path, maxSize: 400, maxBackups: 2}
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		if err := f.SendEvent(ctx, testEvent("alice")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["kind"] != "event" || rec["user"] != "alice" || rec["severity"] != "medium" || rec["time"] != "2024-01-01T10:00:00Z" {
+		t.Fatalf("unexpected record %v", rec)
+	}
+	if len(b) > 400 {
+		t.Fatalf("current file exceeds max size: %d bytes", len(b))
+	}
+	for _, name := range []string{path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Fatalf("missing backup: %v", err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatal("backups beyond max_backups should be removed")
+	}
+}
@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"ssh-noty/internal/model"
+)
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// title names the kind of event in plain text.
+func title(ev *model.Event) string {
+	switch ev.Type {
+	case "login_success":
+		return "SSH LOGIN SUCCESS"
+	case "session_opened":
+		return "SSH SESSION OPENED"
+	case "session_closed":
+		return "SSH SESSION CLOSED"
+	case "alert":
+		return "SSH ALERT"
+	default:
+		return "SSH FAILED/INVALID LOGIN"
+	}
+}
+
+// headline is the title shown for an event by every chat notifier.
+func headline(ev *model.Event) string {
+	switch ev.Type {
+	case "login_success":
+		return "🔐 " + title(ev)
+	case "session_opened":
+		return "🟢 " + title(ev)
+	case "session_closed":
+		return "⚪ " + title(ev)
+	case "alert":
+		// The alert text starts with its own emoji.
+		return truncate(ev.Fields["alert"], 150)
+	default:
+		return "🚨 " + title(ev)
+	}
+}
+
+// severityColor is the RGB colour used for a severity.
+func severityColor(s model.Severity) int {
+	switch s {
+	case model.SeverityCritical:
+		return 0x8E44AD
+	case model.SeverityHigh:
+		return 0xE74C3C
+	case model.SeverityMedium:
+		return 0xE67E22
+	case model.SeverityLow:
+		return 0xF1C40F
+	default:
+		return 0x95A5A6
+	}
+}
+
+type fact struct {
+	Title string
+	Value string
+}
+
+// eventFacts are the fields rendered for an event, in display order.
+func eventFacts(ev *model.Event) []fact {
+	return []fact{
+		{"User", safe(ev.Username)},
+		{"Source", fmt.Sprintf("%s:%d", safe(ev.SourceIP), ev.Port)},
+		{"Method", safe(ev.Method)},
+		{"Host", safe(ev.Hostname)},
+		{"Time", ev.Timestamp.Format(time.RFC3339)},
+	}
+}
+
+// eventText is the plain-text body for an event: one "Title: value" line
+// per fact followed by the severity.
+func eventText(ev *model.Event) string {
+	var b strings.Builder
+	for _, f := range eventFacts(ev) {
+		fmt.Fprintf(&b, "%s: %s\n", f.Title, f.Value)
+	}
+	fmt.Fprintf(&b, "Severity: %s", ev.Level())
+	return b.String()
+}
+
+// summaryText is the plain-text body for a digest.
+func summaryText(sum *model.Summary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s → %s\n", sum.Start.Format(time.RFC3339), sum.End.Format(time.RFC3339))
+	for _, k := range sortedKeys(sum.Counts) {
+		fmt.Fprintf(&b, "%s: %d\n", k, sum.Counts[k])
+	}
+	for _, sec := range summarySections(sum) {
+		fmt.Fprintf(&b, "\n%s:\n", sec.Title)
+		for _, c := range sec.List {
+			fmt.Fprintf(&b, "  %s %d\n", c.Key, c.Count)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// silencedTotal is the number of events silences suppressed in a digest.
+func silencedTotal(sum *model.Summary) int {
+	n := 0
+	for _, c := range sum.Silenced {
+		n += c.Count
+	}
+	return n
+}
+
+func sortedKeys(m map[string]int) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+type summarySection struct {
+	Title string
+	List  []model.Count
+}
+
+// summarySections are the non-empty top-N lists of a digest.
+func summarySections(sum *model.Summary) []summarySection {
+	var out []summarySection
+	if len(sum.TopSources) > 0 {
+		out = append(out, summarySection{"Top sources", sum.TopSources})
+	}
+	if len(sum.TopUsers) > 0 {
+		out = append(out, summarySection{"Top users", sum.TopUsers})
+	}
+	if len(sum.Violations) > 0 {
+		out = append(out, summarySection{"Violations", sum.Violations})
+	}
+	if len(sum.Silenced) > 0 {
+		out = append(out, summarySection{"Silenced", sum.Silenced})
+	}
+	return out
+}
+
+// record is the JSON form of an event, digest or message written by the
+// local outputs.
+type record struct {
+	Kind           string            `json:"kind"`
+	Time           time.Time         `json:"time"`
+	Host           string            `json:"host,omitempty"`
+	Type           string            `json:"type,omitempty"`
+	User           string            `json:"user,omitempty"`
+	SourceIP       string            `json:"source_ip,omitempty"`
+	Port           int               `json:"port,omitempty"`
+	Method         string            `json:"method,omitempty"`
+	KeyFingerprint string            `json:"key_fingerprint,omitempty"`
+	Country        string            `json:"country,omitempty"`
+	Severity       string            `json:"severity,omitempty"`
+	Fields         map[string]string `json:"fields,omitempty"`
+	Tags           []string          `json:"tags,omitempty"`
+	Text           string            `json:"text,omitempty"`
+
+	Start      *time.Time     `json:"start,omitempty"`
+	Counts     map[string]int `json:"counts,omitempty"`
+	TopSources []model.Count  `json:"top_sources,omitempty"`
+	TopUsers   []model.Count  `json:"top_users,omitempty"`
+	Silenced   []model.Count  `json:"silenced,omitempty"`
+	Violations []model.Count  `json:"violations,omitempty"`
+}
+
+func eventRecord(ev *model.Event) *record {
+	return &record{
+		Kind: "event", Time: ev.Timestamp, Host: ev.Hostname, Type: ev.Type, User: ev.Username,
+		SourceIP: ev.SourceIP, Port: ev.Port, Method: ev.Method, KeyFingerprint: ev.KeyFingerprint, Country: ev.Country,
+		Severity: ev.Level().String(), Fields: ev.Fields, Tags: ev.Tags,
+	}
+}
+
+// summaryRecord uses Time for the end of the digest window.
+func summaryRecord(sum *model.Summary) *record {
+	start := sum.Start
+	return &record{
+		Kind: "summary", Time: sum.End, Host: sum.Hostname, Start: &start,
+		Counts: sum.Counts, TopSources: sum.TopSources, TopUsers: sum.TopUsers, Silenced: sum.Silenced,
+		Violations: sum.Violations,
+	}
+}
+
+func textRecord(text string) *record {
+	return &record{Kind: "text", Time: time.Now(), Text: text}
+}
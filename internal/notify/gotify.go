@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("gotify", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		if nc.URL == "" || nc.Token == "" {
+			return nil, errors.New("gotify: url and token (application token) are required")
+		}
+		return &Gotify{
+			name:   nc.Name,
+			url:    strings.TrimRight(nc.URL, "/"),
+			token:  nc.Token,
+			client: &http.Client{Timeout: nc.Timeout(10 * time.Second)},
+		}, nil
+	})
+}
+
+// Gotify posts messages to a self-hosted Gotify server as an application.
+type Gotify struct {
+	healthTracker
+	templates
+	name   string
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (g *Gotify) Name() string { return g.name }
+
+// gotifyPriority maps severity to Gotify's 0-10 scale; clients typically
+// alert from 4 and show high priority from 8.
+func gotifyPriority(s model.Severity) int {
+	switch s {
+	case model.SeverityCritical:
+		return 10
+	case model.SeverityHigh:
+		return 8
+	case model.SeverityMedium:
+		return 5
+	case model.SeverityLow:
+		return 3
+	default:
+		return 2
+	}
+}
+
+func (g *Gotify) SendEvent(ctx context.Context, ev *model.Event) error {
+	text, ok, err := g.render(ev)
+	if err != nil {
+		return g.track(err)
+	}
+	if !ok {
+		text = eventText(ev)
+	}
+	return g.track(g.post(ctx, headline(ev), text, gotifyPriority(ev.Level())))
+}
+
+func (g *Gotify) SendSummary(ctx context.Context, sum *model.Summary) error {
+	return g.track(g.post(ctx, "📊 SSH SUMMARY "+safe(sum.Hostname), summaryText(sum), 2))
+}
+
+func (g *Gotify) SendText(ctx context.Context, text string) error {
+	return g.track(g.post(ctx, "ssh-noti", text, 8))
+}
+
+func (g *Gotify) post(ctx context.Context, title, message string, priority int) error {
+	header := map[string]string{"X-Gotify-Key": g.token}
+	return doJSON(ctx, g.client, "gotify", http.MethodPost, g.url+"/message", map[string]any{
+		"title":    title,
+		"message":  message,
+		"priority": priority,
+	}, header, nil)
+}
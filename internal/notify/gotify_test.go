@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func TestGotify_PriorityFromSeverity(t *testing.T) {
+	var got []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/message" || r.Header.Get("X-Gotify-Key") != "app" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		var m map[string]any
+		json.NewDecoder(r.Body).Decode(&m)
+		got = append(got, m)
+	}))
+	defer srv.Close()
+
+	n, err := registry["gotify"](config.Notifier{Name: "gotify", URL: srv.URL, Token: "app"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := testEvent("alice")
+	ev.Type = "login_failure"
+	crit := testEvent("root")
+	crit.Severity = model.SeverityCritical
+	for _, e := range []*model.Event{ev, crit} {
+		if err := n.SendEvent(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got[0]["priority"] != float64(3) || got[1]["priority"] != float64(10) {
+		t.Fatalf("unexpected priorities: %v / %v", got[0]["priority"], got[1]["priority"])
+	}
+	if msg, _ := got[0]["message"].(string); !strings.Contains(msg, "User: alice") || !strings.HasSuffix(msg, "Severity: low") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"context"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("log", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		return &Log{name: nc.Name}, nil
+	})
+}
+
+// Log writes events to the application log instead of sending them. It is
+// the default when no notifier is configured and is used by --replay.
+type Log struct {
+	healthTracker
+	name string
+}
+
+func NewLog() *Log { return &Log{name: "log"} }
+
+func (l *Log) Name() string { return l.name }
+
+func (l *Log) SendEvent(_ context.Context, ev *model.Event) error {
+	log().Info("event", "type", ev.Type, "user", ev.Username, "ip", ev.SourceIP, "method", ev.Method, "event_time", ev.Timestamp)
+	return l.track(nil)
+}
+
+func (l *Log) SendSummary(_ context.Context, sum *model.Summary) error {
+	log().Info("summary", "host", sum.Hostname, "start", sum.Start, "end", sum.End, "counts", sum.Counts, "silenced", sum.Silenced, "violations", sum.Violations)
+	return l.track(nil)
+}
+
+func (l *Log) SendText(_ context.Context, text string) error {
+	log().Info("message", "text", text)
+	return l.track(nil)
+}
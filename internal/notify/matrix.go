@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("matrix", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		if nc.URL == "" || nc.Room == "" || nc.Token == "" {
+			return nil, errors.New("matrix: url (homeserver), room and token (access token) are required")
+		}
+		return &Matrix{
+			name:   nc.Name,
+			url:    strings.TrimRight(nc.URL, "/"),
+			room:   nc.Room,
+			token:  nc.Token,
+			txn:    fmt.Sprintf("ssh-noti-%d", time.Now().UnixNano()),
+			client: &http.Client{Timeout: nc.Timeout(10 * time.Second)},
+		}, nil
+	})
+}
+
+// Matrix sends m.notice messages with an HTML body to a room through the
+// client-server API.
+type Matrix struct {
+	healthTracker
+	templates
+	name   string
+	url    string
+	room   string
+	token  string
+	client *http.Client
+	// txn prefixes transaction IDs so they stay unique across restarts.
+	txn string
+	seq atomic.Uint64
+}
+
+func (m *Matrix) Name() string { return m.name }
+
+// matrixColor is the severity colour of an event's headline.
+func matrixColor(s model.Severity) string {
+	return fmt.Sprintf("#%06x", severityColor(s))
+}
+
+func (m *Matrix) SendEvent(ctx context.Context, ev *model.Event) error {
+	if text, ok, err := m.render(ev); err != nil {
+		return m.track(err)
+	} else if ok {
+		return m.track(m.send(ctx, text, "<pre>"+html.EscapeString(text)+"</pre>"))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<b><font data-mx-color="%s">%s</font></b><br>`, matrixColor(ev.Level()), html.EscapeString(headline(ev)))
+	for _, f := range eventFacts(ev) {
+		fmt.Fprintf(&b, "<b>%s</b>: <code>%s</code><br>", f.Title, html.EscapeString(f.Value))
+	}
+	fmt.Fprintf(&b, "<b>Severity</b>: %s", ev.Level())
+	return m.track(m.send(ctx, headline(ev)+"\n"+eventText(ev), b.String()))
+}
+
+func (m *Matrix) SendSummary(ctx context.Context, sum *model.Summary) error {
+	heading := "📊 SSH SUMMARY " + safe(sum.Hostname)
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>%s</b><br><i>%s → %s</i><ul>", html.EscapeString(heading), sum.Start.Format(time.RFC3339), sum.End.Format(time.RFC3339))
+	for _, k := range sortedKeys(sum.Counts) {
+		fmt.Fprintf(&b, "<li><b>%s</b>: %d</li>", html.EscapeString(k), sum.Counts[k])
+	}
+	b.WriteString("</ul>")
+	for _, sec := range summarySections(sum) {
+		fmt.Fprintf(&b, "<b>%s</b><ul>", sec.Title)
+		for _, c := range sec.List {
+			fmt.Fprintf(&b, "<li><code>%s</code> %d</li>", html.EscapeString(c.Key), c.Count)
+		}
+		b.WriteString("</ul>")
+	}
+	return m.track(m.send(ctx, heading+"\n"+summaryText(sum), b.String()))
+}
+
+func (m *Matrix) SendText(ctx context.Context, text string) error {
+	return m.track(m.send(ctx, text, strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")))
+}
+
+func (m *Matrix) send(ctx context.Context, text, formatted string) error {
+	url := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s-%d",
+		m.url, neturl.PathEscape(m.room), m.txn, m.seq.Add(1))
+	header := map[string]string{"Authorization": "Bearer " + m.token}
+	err := doJSON(ctx, m.client, "matrix", http.MethodPut, url, map[string]any{
+		"msgtype":        "m.notice",
+		"body":           text,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": formatted,
+	}, header, nil)
+	var se *StatusError
+	if errors.As(err, &se) {
+		// M_LIMIT_EXCEEDED carries the wait in retry_after_ms.
+		var body struct {
+			RetryAfterMS int64 `json:"retry_after_ms"`
+		}
+		if json.Unmarshal([]byte(se.Body), &body) == nil && body.RetryAfterMS > 0 {
+			se.RetryAfter = time.Duration(body.RetryAfterMS) * time.Millisecond
+		}
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/config"
+)
+
+func TestMatrix_SendNotice(t *testing.T) {
+	var paths []string
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer syt" {
+			t.Errorf("unexpected request %s %v", r.Method, r.Header)
+		}
+		paths = append(paths, r.URL.EscapedPath())
+		json.NewDecoder(r.Body).Decode(&got)
+		if len(paths) == 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"errcode":"M_LIMIT_EXCEEDED","retry_after_ms":1500}`))
+			return
+		}
+		w.Write([]byte(`{"event_id":"$1"}`))
+	}))
+	defer srv.Close()
+
+	n, err := registry["matrix"](config.Notifier{Name: "matrix", URL: srv.URL, Room: "!abc:example.org", Token: "syt"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := n.SendEvent(ctx, testEvent("<b>eve</b>")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(paths[0], "/_matrix/client/v3/rooms/%21abc:example.org/send/m.room.message/ssh-noti-") {
+		t.Fatalf("unexpected path %s", paths[0])
+	}
+	if got["msgtype"] != "m.notice" || got["format"] != "org.matrix.custom.html" ||
+		!strings.Contains(got["formatted_body"], "<code>&lt;b&gt;eve&lt;/b&gt;</code>") || !strings.Contains(got["body"], "User: <b>eve</b>") {
+		t.Fatalf("unexpected message %v", got)
+	}
+
+	err = n.(*Matrix).SendText(ctx, "down")
+	var se *StatusError
+	if !errors.As(err, &se) || se.RetryAfter != 1500*time.Millisecond {
+		t.Fatalf("expected rate limit error with retry_after_ms, got %v", err)
+	}
+	if paths[0] == paths[1] {
+		t.Fatal("transaction IDs must be unique")
+	}
+}
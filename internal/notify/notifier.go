@@ -0,0 +1,295 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/tmpl"
+)
+
+// Notifier delivers events and batch digests to one destination.
+type Notifier interface {
+	Name() string
+	SendEvent(ctx context.Context, ev *model.Event) error
+	SendSummary(ctx context.Context, sum *model.Summary) error
+	Health() Health
+}
+
+// TextSender is implemented by notifiers that can post free-form
+// operational messages such as "source down" alerts.
+type TextSender interface {
+	SendText(ctx context.Context, text string) error
+}
+
+// BatchSender is implemented by notifiers that hold events back and send
+// them together in the background (email batching). A batch that fails
+// there is passed to the OnBatchError handler, e.g. a spool that retries
+// it with SendBatch; without a handler the notifier keeps it for its next
+// batch.
+type BatchSender interface {
+	SendBatch(ctx context.Context, b *Batch) error
+	OnBatchError(func(b *Batch, err error))
+}
+
+// Batch is a set of events sent as one message.
+type Batch struct {
+	Events []model.Event
+	// Silenced counts the events silences suppressed since the previous
+	// batch, per silence.
+	Silenced []model.Count
+}
+
+// SilenceCounter is implemented by notifiers that report in their digests
+// how many events silences suppressed.
+type SilenceCounter interface {
+	CountSilenced(ev *model.Event, label string)
+}
+
+// FailureCounter is implemented by notifiers that count failed logins
+// themselves, e.g. to detect brute force. They are sent every failure,
+// including those deduplication suppresses for the other sinks.
+type FailureCounter interface {
+	CountsFailures() bool
+}
+
+// CountsFailures reports whether n, or the notifier it wraps, counts
+// failures.
+func CountsFailures(n Notifier) bool {
+	fc, ok := n.(FailureCounter)
+	return ok && fc.CountsFailures()
+}
+
+// IsFailure reports whether ev is a failed login attempt.
+func IsFailure(ev *model.Event) bool {
+	return ev.Type == "login_failure" || ev.Type == "invalid_user"
+}
+
+// Flusher is implemented by notifiers that buffer events (e.g. email
+// batching); Flush delivers anything pending and is called on shutdown.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Health describes recent delivery results of a notifier.
+type Health struct {
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastErrorAt         time.Time `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	// Queued is the number of notifications waiting to be retried.
+	Queued int `json:"queued,omitempty"`
+	// Dropped counts notifications discarded by a full dispatch queue.
+	Dropped int `json:"dropped,omitempty"`
+}
+
+func (h Health) OK() bool { return h.ConsecutiveFailures == 0 }
+
+// healthTracker is embedded by notifiers to implement Health.
+type healthTracker struct {
+	mu sync.Mutex
+	h  Health
+}
+
+func (t *healthTracker) Health() Health {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.h
+}
+
+// track records the outcome of a delivery and returns err unchanged.
+func (t *healthTracker) track(err error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.h.LastError, t.h.LastErrorAt = err.Error(), time.Now()
+		t.h.ConsecutiveFailures++
+		return err
+	}
+	t.h.LastSuccess, t.h.ConsecutiveFailures = time.Now(), 0
+	return nil
+}
+
+// templates is embedded by notifiers that support user-defined message
+// templates (the notifier's "templates" config).
+type templates struct {
+	tmpl *tmpl.Set
+}
+
+func (t *templates) setTemplates(s *tmpl.Set) { t.tmpl = s }
+
+// render returns the templated text for ev; ok is false when no template
+// applies to its type.
+func (t *templates) render(ev *model.Event) (text string, ok bool, err error) {
+	out, err := t.tmpl.Render(ev)
+	if err != nil || out == nil {
+		return "", false, err
+	}
+	return out.Text, true, nil
+}
+
+// Factory builds a notifier from one entry of the notifiers config array.
+type Factory func(nc config.Notifier, cfg *config.Config) (Notifier, error)
+
+var registry = map[string]Factory{}
+
+// Register makes a notifier type available to Build. It is called from the
+// init functions of the notifier implementations.
+func Register(kind string, f Factory) {
+	if _, dup := registry[kind]; dup {
+		panic("notify: duplicate notifier type " + kind)
+	}
+	registry[kind] = f
+}
+
+// Types lists the registered notifier types.
+func Types() []string {
+	out := make([]string, 0, len(registry))
+	for k := range registry {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Build creates the notifiers returned by cfg.Sinks.
+func Build(cfg *config.Config) ([]Notifier, error) {
+	entries := cfg.Sinks()
+	out := make([]Notifier, 0, len(entries))
+	for _, nc := range entries {
+		f, ok := registry[nc.Type]
+		if !ok {
+			return nil, fmt.Errorf("notifier %q: unknown type %q", nc.Name, nc.Type)
+		}
+		n, err := f(nc, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %q: %w", nc.Name, err)
+		}
+		if len(nc.Templates) > 0 {
+			tn, ok := n.(interface{ setTemplates(*tmpl.Set) })
+			if !ok {
+				return nil, fmt.Errorf("notifier %q: type %q does not support templates", nc.Name, nc.Type)
+			}
+			set, err := tmpl.LoadSet(nc.Templates)
+			if err != nil {
+				return nil, fmt.Errorf("notifier %q: %w", nc.Name, err)
+			}
+			tn.setTemplates(set)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// Fanout sends to every sink concurrently. A failing or panicking sink does
+// not prevent delivery to the others; their errors are joined.
+type Fanout struct {
+	sinks []Notifier
+}
+
+func NewFanout(sinks ...Notifier) *Fanout { return &Fanout{sinks: sinks} }
+
+func (f *Fanout) Name() string { return "fanout" }
+
+func (f *Fanout) Sinks() []Notifier { return f.sinks }
+
+// Only returns a Fanout over the named sinks.
+func (f *Fanout) Only(names ...string) *Fanout {
+	out := &Fanout{}
+	for _, n := range f.sinks {
+		if slices.Contains(names, n.Name()) {
+			out.sinks = append(out.sinks, n)
+		}
+	}
+	return out
+}
+
+// CountSilenced passes a silenced event to the sinks that count them.
+func (f *Fanout) CountSilenced(ev *model.Event, label string) {
+	for _, n := range f.sinks {
+		if sc, ok := n.(SilenceCounter); ok {
+			sc.CountSilenced(ev, label)
+		}
+	}
+}
+
+// Counting returns a Fanout over the sinks that count failures.
+func (f *Fanout) Counting() *Fanout {
+	out := &Fanout{}
+	for _, n := range f.sinks {
+		if CountsFailures(n) {
+			out.sinks = append(out.sinks, n)
+		}
+	}
+	return out
+}
+
+func (f *Fanout) SendEvent(ctx context.Context, ev *model.Event) error {
+	return f.each(func(n Notifier) error { return n.SendEvent(ctx, ev) })
+}
+
+func (f *Fanout) SendSummary(ctx context.Context, sum *model.Summary) error {
+	return f.each(func(n Notifier) error { return n.SendSummary(ctx, sum) })
+}
+
+// SendText posts text to the sinks that support free-form messages.
+func (f *Fanout) SendText(ctx context.Context, text string) error {
+	return f.each(func(n Notifier) error {
+		if ts, ok := n.(TextSender); ok {
+			return ts.SendText(ctx, text)
+		}
+		return nil
+	})
+}
+
+// Flush flushes every buffering sink.
+func (f *Fanout) Flush(ctx context.Context) error {
+	return f.each(func(n Notifier) error {
+		if fl, ok := n.(Flusher); ok {
+			return fl.Flush(ctx)
+		}
+		return nil
+	})
+}
+
+// Health reports the worst sink: failing if any sink is failing.
+func (f *Fanout) Health() Health {
+	var out Health
+	for _, n := range f.sinks {
+		h := n.Health()
+		if h.ConsecutiveFailures > out.ConsecutiveFailures {
+			out = h
+		}
+		if h.LastSuccess.After(out.LastSuccess) {
+			out.LastSuccess = h.LastSuccess
+		}
+	}
+	return out
+}
+
+func (f *Fanout) each(fn func(Notifier) error) error {
+	errs := make([]error, len(f.sinks))
+	var wg sync.WaitGroup
+	for i, n := range f.sinks {
+		wg.Add(1)
+		go func(i int, n Notifier) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("%s: panic: %v", n.Name(), r)
+				}
+			}()
+			if err := fn(n); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", n.Name(), err)
+			}
+		}(i, n)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
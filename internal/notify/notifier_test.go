@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/tmpl"
+)
+
+type failingNotifier struct {
+	healthTracker
+	panics bool
+}
+
+func (f *failingNotifier) Name() string { return "broken" }
+
+func (f *failingNotifier) SendEvent(context.Context, *model.Event) error {
+	if f.panics {
+		panic("boom")
+	}
+	return f.track(errors.New("down"))
+}
+
+func (f *failingNotifier) SendSummary(context.Context, *model.Summary) error { return nil }
+
+func TestBuild_NotifiersArray(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{Notifiers: []config.Notifier{
+		{Type: "slack", Name: "sec", URL: srv.URL},
+		{Type: "log", Name: "audit-log"},
+	}}
+	sinks, err := Build(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sinks) != 2 || sinks[0].Name() != "sec" || sinks[1].Name() != "audit-log" {
+		t.Fatalf("unexpected sinks: %v", sinks)
+	}
+
+	fan := NewFanout(append(sinks, &failingNotifier{}, &failingNotifier{panics: true})...)
+	err = fan.SendEvent(context.Background(), &model.Event{Type: "login_success", Username: "alice"})
+	if err == nil || !strings.Contains(err.Error(), "broken: down") || !strings.Contains(err.Error(), "panic: boom") || strings.Count(err.Error(), "\n") != 1 {
+		t.Fatalf("expected joined sink errors, got %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("slack sink should still receive the event, hits=%d", hits.Load())
+	}
+	if h := sinks[0].Health(); !h.OK() || h.LastSuccess.IsZero() {
+		t.Fatalf("unexpected slack health: %+v", h)
+	}
+	if h := fan.Health(); h.OK() {
+		t.Fatalf("fanout health should report the failing sink: %+v", h)
+	}
+}
+
+func TestBuild_LegacyAndUnknown(t *testing.T) {
+	sinks, err := Build(&config.Config{SlackWebhook: "https://hooks.example/x"})
+	if err != nil || len(sinks) != 1 || sinks[0].Name() != "slack" {
+		t.Fatalf("legacy webhook: %v %v", sinks, err)
+	}
+	sinks, err = Build(&config.Config{})
+	if err != nil || len(sinks) != 1 || sinks[0].Name() != "log" {
+		t.Fatalf("default log notifier: %v %v", sinks, err)
+	}
+	if _, err := Build(&config.Config{Notifiers: []config.Notifier{{Type: "pigeon", Name: "p"}}}); err == nil {
+		t.Fatal("expected error for unknown type")
+	}
+	if _, err := Build(&config.Config{Notifiers: []config.Notifier{{Type: "log", Name: "l", Templates: map[string]string{"default": "x.txt"}}}}); err == nil || !strings.Contains(err.Error(), "does not support templates") {
+		t.Fatalf("expected templates to be rejected for log, got %v", err)
+	}
+}
+
+// config.Load rejects templates for the types Build would reject them for.
+func TestTemplatedTypes(t *testing.T) {
+	for _, typ := range Types() {
+		nc := config.Notifier{
+			Type: typ, Name: typ, URL: "https://example.com/x", Token: "t", Channel: "#c", ChatIDs: []string{"1"},
+			From: "a@example.com", To: []string{"b@example.com"}, Command: []string{"true"},
+			Path: filepath.Join(t.TempDir(), "out.jsonl"), Topic: "t", Room: "!r:example.com",
+		}
+		switch typ {
+		case "smtp":
+			nc.URL = "smtp://mail.example.com:587"
+		case "syslog":
+			nc.URL = "udp://127.0.0.1:514"
+		}
+		n, err := registry[typ](nc, &config.Config{})
+		if err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		_, ok := n.(interface{ setTemplates(*tmpl.Set) })
+		if ok != slices.Contains(config.TemplatedTypes, typ) {
+			t.Errorf("%s: supports templates %v, config.TemplatedTypes disagrees", typ, ok)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("ntfy", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		if nc.Topic == "" {
+			return nil, errors.New("ntfy: topic is required")
+		}
+		n := &Ntfy{
+			name:   nc.Name,
+			url:    strings.TrimRight(nc.URL, "/"),
+			topic:  nc.Topic,
+			token:  nc.Token,
+			tags:   nc.Tags,
+			client: &http.Client{Timeout: nc.Timeout(10 * time.Second)},
+		}
+		if n.url == "" {
+			n.url = "https://ntfy.sh"
+		}
+		return n, nil
+	})
+}
+
+// Ntfy publishes to an ntfy topic using JSON publishing.
+type Ntfy struct {
+	healthTracker
+	templates
+	name   string
+	url    string
+	topic  string
+	token  string
+	tags   []string
+	client *http.Client
+}
+
+func (n *Ntfy) Name() string { return n.name }
+
+// ntfyPriority maps severity to ntfy's 1 (min) to 5 (urgent) scale.
+func ntfyPriority(s model.Severity) int {
+	switch s {
+	case model.SeverityCritical:
+		return 5
+	case model.SeverityHigh:
+		return 4
+	case model.SeverityMedium:
+		return 3
+	default:
+		return 2
+	}
+}
+
+func (n *Ntfy) SendEvent(ctx context.Context, ev *model.Event) error {
+	tags := append([]string{ev.Type}, n.tags...)
+	text, ok, err := n.render(ev)
+	if err != nil {
+		return n.track(err)
+	}
+	if !ok {
+		text = eventText(ev)
+	}
+	return n.track(n.publish(ctx, headline(ev), text, ntfyPriority(ev.Level()), tags))
+}
+
+func (n *Ntfy) SendSummary(ctx context.Context, sum *model.Summary) error {
+	tags := append([]string{"summary"}, n.tags...)
+	return n.track(n.publish(ctx, "📊 SSH SUMMARY "+safe(sum.Hostname), summaryText(sum), 2, tags))
+}
+
+func (n *Ntfy) SendText(ctx context.Context, text string) error {
+	return n.track(n.publish(ctx, "ssh-noti", text, 4, n.tags))
+}
+
+func (n *Ntfy) publish(ctx context.Context, title, message string, priority int, tags []string) error {
+	var header map[string]string
+	if n.token != "" {
+		header = map[string]string{"Authorization": "Bearer " + n.token}
+	}
+	return doJSON(ctx, n.client, "ntfy", http.MethodPost, n.url, map[string]any{
+		"topic":    n.topic,
+		"title":    title,
+		"message":  message,
+		"priority": priority,
+		"tags":     tags,
+	}, header, nil)
+}
@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ssh-noty/internal/config"
+)
+
+func TestNtfy_PublishJSON(t *testing.T) {
+	var got map[string]any
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n, err := registry["ntfy"](config.Notifier{Name: "ntfy", URL: srv.URL + "/", Topic: "ssh", Token: "tk_x", Tags: []string{"prod"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.SendEvent(context.Background(), testEvent("root")); err != nil {
+		t.Fatal(err)
+	}
+	tags, _ := got["tags"].([]any)
+	if auth != "Bearer tk_x" || got["topic"] != "ssh" || got["priority"] != float64(4) || len(tags) != 2 || tags[0] != "login_success" {
+		t.Fatalf("unexpected publish: auth=%q body=%v", auth, got)
+	}
+	if _, err := registry["ntfy"](config.Notifier{Name: "ntfy"}, nil); err == nil {
+		t.Fatal("expected error without topic")
+	}
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("opsgenie", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		if nc.Token == "" {
+			return nil, fmt.Errorf("opsgenie: %w (API integration key)", errNoToken)
+		}
+		og := &opsgenie{
+			url:    strings.TrimRight(nc.URL, "/"),
+			apiKey: nc.Token,
+			client: &http.Client{Timeout: nc.Timeout(10 * time.Second)},
+		}
+		if og.url == "" {
+			og.url = "https://api.opsgenie.com"
+		}
+		return newPager(nc, og)
+	})
+}
+
+// opsgenie talks to the Opsgenie Alert API. The incident key is used as the
+// alert alias so later acknowledge/close calls address the same alert.
+type opsgenie struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func opsgeniePriority(s model.Severity) string {
+	switch s {
+	case model.SeverityCritical:
+		return "P1"
+	case model.SeverityHigh:
+		return "P2"
+	case model.SeverityMedium:
+		return "P3"
+	case model.SeverityLow:
+		return "P4"
+	default:
+		return "P5"
+	}
+}
+
+func (og *opsgenie) post(ctx context.Context, path string, body map[string]any) error {
+	header := map[string]string{"Authorization": "GenieKey " + og.apiKey}
+	return doJSON(ctx, og.client, "opsgenie", http.MethodPost, og.url+path, body, header, nil)
+}
+
+func (og *opsgenie) trigger(ctx context.Context, inc *Incident) error {
+	ev := &inc.Event
+	msg := truncate(inc.Summary, 130)
+	return og.post(ctx, "/v2/alerts", map[string]any{
+		"message":     msg,
+		"alias":       inc.Key,
+		"description": inc.Summary,
+		"priority":    opsgeniePriority(inc.Severity),
+		"source":      "ssh-noti",
+		"entity":      safe(ev.Hostname),
+		"tags":        []string{"ssh", ev.Type, inc.Severity.String()},
+		"details": map[string]string{
+			"user":   ev.Username,
+			"source": fmt.Sprintf("%s:%d", ev.SourceIP, ev.Port),
+			"method": ev.Method,
+			"time":   ev.Timestamp.Format(time.RFC3339),
+		},
+	})
+}
+
+func (og *opsgenie) acknowledge(ctx context.Context, key string) error {
+	return og.post(ctx, "/v2/alerts/"+neturl.PathEscape(key)+"/acknowledge?identifierType=alias", map[string]any{"source": "ssh-noti"})
+}
+
+func (og *opsgenie) resolve(ctx context.Context, key string) error {
+	return og.post(ctx, "/v2/alerts/"+neturl.PathEscape(key)+"/close?identifierType=alias", map[string]any{"source": "ssh-noti", "note": "source went quiet"})
+}
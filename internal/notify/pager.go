@@ -0,0 +1,254 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+// Incident is one page sent to an on-call service. Key is stable for the
+// lifetime of the incident so repeated triggers are deduplicated upstream.
+type Incident struct {
+	Key      string
+	Summary  string
+	Severity model.Severity
+	Event    model.Event
+	// AutoResolve incidents are resolved once their source goes quiet.
+	AutoResolve bool
+}
+
+// pagerBackend is the service-specific API behind a Pager.
+type pagerBackend interface {
+	trigger(ctx context.Context, inc *Incident) error
+	acknowledge(ctx context.Context, key string) error
+	resolve(ctx context.Context, key string) error
+}
+
+// Pager pages only for significant events: anything at or above the
+// configured severity, brute-force bursts from one IP, and a successful
+// login from an IP that failed repeatedly shortly before. Brute-force
+// incidents resolve themselves after a quiet period.
+type Pager struct {
+	healthTracker
+	name    string
+	backend pagerBackend
+	min     model.Severity
+
+	threshold int
+	window    time.Duration
+	quiet     time.Duration
+	// afterFailures is how many recent failures make a success critical.
+	afterFailures int
+
+	mu       sync.Mutex
+	failures map[string][]time.Time // source IP -> recent failure times
+	swept    time.Time
+	open     map[string]*openIncident
+	sweeping bool
+}
+
+type openIncident struct {
+	inc      *Incident
+	lastSeen time.Time
+}
+
+func newPager(nc config.Notifier, backend pagerBackend) (*Pager, error) {
+	p := &Pager{
+		name:      nc.Name,
+		backend:   backend,
+		min:       model.SeverityHigh,
+		threshold: nc.BruteForceThreshold,
+		window:    time.Duration(nc.BruteForceWindowSeconds) * time.Second,
+		quiet:     time.Duration(nc.ResolveAfterSeconds) * time.Second,
+		failures:  make(map[string][]time.Time),
+		open:      make(map[string]*openIncident),
+
+		afterFailures: nc.SuccessAfterFailures,
+	}
+	if nc.MinSeverity != "" {
+		sev, err := model.ParseSeverity(nc.MinSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("min_severity: %w", err)
+		}
+		p.min = sev
+	}
+	if p.threshold <= 0 {
+		p.threshold = 20
+	}
+	if p.window <= 0 {
+		p.window = 5 * time.Minute
+	}
+	if p.quiet <= 0 {
+		p.quiet = 15 * time.Minute
+	}
+	if p.afterFailures <= 0 {
+		p.afterFailures = 5
+	}
+	return p, nil
+}
+
+func (p *Pager) Name() string { return p.name }
+
+func incidentKey(parts ...string) string {
+	return "ssh-noti/" + strings.Join(parts, "/")
+}
+
+// CountsFailures is true: the brute-force threshold needs every failure.
+func (p *Pager) CountsFailures() bool { return true }
+
+func (p *Pager) SendEvent(ctx context.Context, ev *model.Event) error {
+	now := ev.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	inc := p.observe(ev, now)
+	if inc == nil || inc.Severity < p.min {
+		return nil
+	}
+	return p.track(p.backend.trigger(ctx, inc))
+}
+
+// observe updates failure counts and returns the incident ev opens, if any.
+func (p *Pager) observe(ev *model.Event, now time.Time) *Incident {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now.Sub(p.swept) >= p.window {
+		// Forget IPs that stopped failing.
+		for ip, times := range p.failures {
+			if now.Sub(times[len(times)-1]) >= p.window {
+				delete(p.failures, ip)
+			}
+		}
+		p.swept = now
+	}
+	ip := ev.SourceIP
+	recent := p.failures[ip][:0]
+	for _, t := range p.failures[ip] {
+		if now.Sub(t) < p.window {
+			recent = append(recent, t)
+		}
+	}
+	bfKey := incidentKey(safe(ev.Hostname), "bruteforce", ip)
+
+	if IsFailure(ev) && ip != "" {
+		recent = append(recent, now)
+		p.failures[ip] = recent
+		if o, ok := p.open[bfKey]; ok {
+			o.lastSeen = time.Now()
+			return nil
+		}
+		if len(recent) < p.threshold || model.SeverityHigh < p.min {
+			return nil
+		}
+		inc := &Incident{
+			Key:         bfKey,
+			Summary:     fmt.Sprintf("SSH brute force on %s: %d failures from %s in %s", safe(ev.Hostname), len(recent), ip, p.window),
+			Severity:    model.SeverityHigh,
+			Event:       *ev,
+			AutoResolve: true,
+		}
+		p.openLocked(inc)
+		return inc
+	}
+	if len(recent) == 0 {
+		delete(p.failures, ip)
+	} else {
+		p.failures[ip] = recent
+	}
+
+	if ev.Type == "login_success" && ip != "" {
+		if _, attacked := p.open[bfKey]; attacked || len(recent) >= p.afterFailures {
+			return &Incident{
+				Key:      incidentKey(safe(ev.Hostname), "success-after-failures", ip, safe(ev.Username)),
+				Summary:  fmt.Sprintf("SSH login as %s on %s from %s after %d failed attempts", safe(ev.Username), safe(ev.Hostname), ip, len(recent)),
+				Severity: model.SeverityCritical,
+				Event:    *ev,
+			}
+		}
+	}
+	if ev.Type == "alert" {
+		return &Incident{
+			Key:      incidentKey(safe(ev.Hostname), "alert", ev.Fields["rule"], safe(ip)),
+			Summary:  ev.Fields["alert"],
+			Severity: ev.Level(),
+			Event:    *ev,
+		}
+	}
+	return &Incident{
+		Key:      incidentKey(safe(ev.Hostname), ev.Type, safe(ev.Username), safe(ip)),
+		Summary:  fmt.Sprintf("%s: %s from %s on %s", title(ev), safe(ev.Username), safe(ip), safe(ev.Hostname)),
+		Severity: ev.Level(),
+		Event:    *ev,
+	}
+}
+
+// openLocked records an auto-resolving incident and starts the sweeper.
+func (p *Pager) openLocked(inc *Incident) {
+	p.open[inc.Key] = &openIncident{inc: inc, lastSeen: time.Now()}
+	if !p.sweeping {
+		p.sweeping = true
+		go p.sweep()
+	}
+}
+
+// sweep resolves quiet incidents and exits once none are open.
+func (p *Pager) sweep() {
+	interval := p.quiet / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	for {
+		time.Sleep(interval)
+		for _, key := range p.quietIncidents(time.Now()) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := p.track(p.backend.resolve(ctx, key)); err != nil {
+				log().Warn("failed to resolve incident", "notifier", p.name, "key", key, "error", err)
+			}
+			cancel()
+		}
+		p.mu.Lock()
+		if len(p.open) == 0 {
+			p.sweeping = false
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *Pager) quietIncidents(now time.Time) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var keys []string
+	for k, o := range p.open {
+		if now.Sub(o.lastSeen) >= p.quiet {
+			keys = append(keys, k)
+			delete(p.open, k)
+		}
+	}
+	return keys
+}
+
+// Acknowledge marks an incident as being handled.
+func (p *Pager) Acknowledge(ctx context.Context, key string) error {
+	return p.track(p.backend.acknowledge(ctx, key))
+}
+
+// Resolve closes an incident and stops tracking it.
+func (p *Pager) Resolve(ctx context.Context, key string) error {
+	p.mu.Lock()
+	delete(p.open, key)
+	p.mu.Unlock()
+	return p.track(p.backend.resolve(ctx, key))
+}
+
+// SendSummary is a no-op: digests never page.
+func (p *Pager) SendSummary(context.Context, *model.Summary) error { return nil }
+
+var errNoToken = errors.New("token is required")
@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+type recorded struct {
+	path   string
+	auth   string
+	body   map[string]any
+	action string
+}
+
+func recorder(t *testing.T) (*httptest.Server, func() []recorded) {
+	var mu sync.Mutex
+	var got []recorded
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		action, _ := body["event_action"].(string)
+		mu.Lock()
+		got = append(got, recorded{path: r.URL.RequestURI(), auth: r.Header.Get("Authorization"), body: body, action: action})
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []recorded {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recorded(nil), got...)
+	}
+}
+
+func TestPagerDuty_Incidents(t *testing.T) {
+	srv, got := recorder(t)
+	sinks, err := Build(&config.Config{Notifiers: []config.Notifier{{
+		Type: "pagerduty", Name: "pd", URL: srv.URL, Token: "RKEY",
+		BruteForceThreshold: 3, BruteForceWindowSeconds: 60, ResolveAfterSeconds: 1,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pd := sinks[0].(*Pager)
+	pd.quiet = 80 * time.Millisecond
+	ctx := context.Background()
+	ts := time.Now()
+	ev := func(typ, user, ip string) *model.Event {
+		ts = ts.Add(time.Second)
+		return &model.Event{Type: typ, Username: user, SourceIP: ip, Hostname: "web1", Timestamp: ts}
+	}
+
+	// Ordinary logins, isolated failures and a login after a typo do not page.
+	pd.SendEvent(ctx, ev("login_success", "alice", "203.0.113.5"))
+	pd.SendEvent(ctx, ev("login_failure", "bob", "198.51.100.1"))
+	pd.SendEvent(ctx, ev("login_failure", "carol", "192.0.2.9"))
+	pd.SendEvent(ctx, ev("login_success", "carol", "192.0.2.9"))
+	if n := len(got()); n != 0 {
+		t.Fatalf("expected no pages, got %d", n)
+	}
+	// Root login is high severity.
+	pd.SendEvent(ctx, ev("login_success", "root", "203.0.113.5"))
+	// Third failure from the IP opens a brute-force incident; later ones do not re-page.
+	for i := 0; i < 4; i++ {
+		pd.SendEvent(ctx, ev("login_failure", "bob", "198.51.100.1"))
+	}
+	// Success from the attacking IP is critical.
+	pd.SendEvent(ctx, ev("login_success", "bob", "198.51.100.1"))
+
+	calls := got()
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 triggers, got %+v", calls)
+	}
+	wantKeys := []string{
+		"ssh-noti/web1/login_success/root/203.0.113.5",
+		"ssh-noti/web1/bruteforce/198.51.100.1",
+		"ssh-noti/web1/success-after-failures/198.51.100.1/bob",
+	}
+	wantSev := []string{"error", "error", "critical"}
+	for i, c := range calls {
+		if c.path != "/v2/enqueue" || c.action != "trigger" || c.body["routing_key"] != "RKEY" || c.body["dedup_key"] != wantKeys[i] {
+			t.Fatalf("call %d: %+v", i, c)
+		}
+		if sev := c.body["payload"].(map[string]any)["severity"]; sev != wantSev[i] {
+			t.Fatalf("call %d severity %v, want %s", i, sev, wantSev[i])
+		}
+	}
+
+	// The brute-force incident resolves once the IP goes quiet.
+	deadline := time.Now().Add(2 * time.Second)
+	for len(got()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	calls = got()
+	if len(calls) != 4 || calls[3].action != "resolve" || calls[3].body["dedup_key"] != wantKeys[1] {
+		t.Fatalf("expected auto-resolve of the brute-force incident, got %+v", calls)
+	}
+}
+
+func TestPager_ForgetsQuietIPs(t *testing.T) {
+	srv, _ := recorder(t)
+	sinks, err := Build(&config.Config{Notifiers: []config.Notifier{{Type: "pagerduty", Name: "pd", URL: srv.URL, Token: "RKEY", BruteForceWindowSeconds: 60}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pd := sinks[0].(*Pager)
+	ctx := context.Background()
+	ts := time.Now()
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2"} {
+		pd.SendEvent(ctx, &model.Event{Type: "login_failure", Username: "bob", SourceIP: ip, Hostname: "web1", Timestamp: ts})
+	}
+	pd.SendEvent(ctx, &model.Event{Type: "invalid_user", Username: "x", SourceIP: "198.51.100.3", Hostname: "web1", Timestamp: ts.Add(2 * time.Minute)})
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+	if len(pd.failures) != 1 {
+		t.Fatalf("failures of quiet IPs should be forgotten: %v", pd.failures)
+	}
+}
+
+func TestOpsgenie_AliasLifecycle(t *testing.T) {
+	srv, got := recorder(t)
+	sinks, err := Build(&config.Config{Notifiers: []config.Notifier{{
+		Type: "opsgenie", Name: "og", URL: srv.URL, Token: "KEY", MinSeverity: "medium",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	og := sinks[0].(*Pager)
+	ctx := context.Background()
+	og.SendEvent(ctx, &model.Event{Type: "login_success", Username: "alice", SourceIP: "203.0.113.5", Hostname: "web1", Timestamp: time.Now()})
+	key := "ssh-noti/web1/login_success/alice/203.0.113.5"
+	og.Acknowledge(ctx, key)
+	og.Resolve(ctx, key)
+
+	calls := got()
+	if len(calls) != 3 {
+		t.Fatalf("expected create, acknowledge, close: %+v", calls)
+	}
+	if calls[0].path != "/v2/alerts" || calls[0].auth != "GenieKey KEY" || calls[0].body["alias"] != key || calls[0].body["priority"] != "P3" {
+		t.Fatalf("unexpected create: %+v", calls[0])
+	}
+	escaped := "/v2/alerts/ssh-noti%2Fweb1%2Flogin_success%2Falice%2F203.0.113.5"
+	if calls[1].path != escaped+"/acknowledge?identifierType=alias" || calls[2].path != escaped+"/close?identifierType=alias" {
+		t.Fatalf("unexpected paths: %s %s", calls[1].path, calls[2].path)
+	}
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("pagerduty", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		if nc.Token == "" {
+			return nil, fmt.Errorf("pagerduty: %w (integration routing key)", errNoToken)
+		}
+		pd := &pagerDuty{
+			url:        strings.TrimRight(nc.URL, "/"),
+			routingKey: nc.Token,
+			client:     &http.Client{Timeout: nc.Timeout(10 * time.Second)},
+		}
+		if pd.url == "" {
+			pd.url = "https://events.pagerduty.com"
+		}
+		return newPager(nc, pd)
+	})
+}
+
+// pagerDuty talks to the PagerDuty Events API v2.
+type pagerDuty struct {
+	url        string
+	routingKey string
+	client     *http.Client
+}
+
+func pagerDutySeverity(s model.Severity) string {
+	switch s {
+	case model.SeverityCritical:
+		return "critical"
+	case model.SeverityHigh:
+		return "error"
+	case model.SeverityMedium:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+func (pd *pagerDuty) enqueue(ctx context.Context, body map[string]any) error {
+	body["routing_key"] = pd.routingKey
+	return doJSON(ctx, pd.client, "pagerduty", http.MethodPost, pd.url+"/v2/enqueue", body, nil, nil)
+}
+
+func (pd *pagerDuty) trigger(ctx context.Context, inc *Incident) error {
+	ev := &inc.Event
+	details := map[string]any{
+		"user":     ev.Username,
+		"source":   fmt.Sprintf("%s:%d", ev.SourceIP, ev.Port),
+		"method":   ev.Method,
+		"type":     ev.Type,
+		"severity": inc.Severity.String(),
+	}
+	ts := ev.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return pd.enqueue(ctx, map[string]any{
+		"event_action": "trigger",
+		"dedup_key":    inc.Key,
+		"payload": map[string]any{
+			"summary":        inc.Summary,
+			"source":         safe(ev.Hostname),
+			"severity":       pagerDutySeverity(inc.Severity),
+			"timestamp":      ts.UTC().Format(time.RFC3339),
+			"component":      "sshd",
+			"group":          "ssh-noti",
+			"class":          ev.Type,
+			"custom_details": details,
+		},
+	})
+}
+
+func (pd *pagerDuty) acknowledge(ctx context.Context, key string) error {
+	return pd.enqueue(ctx, map[string]any{"event_action": "acknowledge", "dedup_key": key})
+}
+
+func (pd *pagerDuty) resolve(ctx context.Context, key string) error {
+	return pd.enqueue(ctx, map[string]any{"event_action": "resolve", "dedup_key": key})
+}
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"ssh-noty/internal/config"
@@ -13,24 +17,108 @@ import (
 	"ssh-noty/internal/model"
 )
 
+func init() {
+	Register("slack", func(nc config.Notifier, cfg *config.Config) (Notifier, error) {
+		s := &Slack{name: nc.Name, client: &http.Client{Timeout: nc.Timeout(10 * time.Second)}}
+		if cfg != nil {
+			s.format = cfg.Formatting
+		}
+		if nc.Token == "" {
+			if nc.URL == "" {
+				return nil, errors.New("slack: url (incoming webhook) or token (bot token) is required")
+			}
+			s.webhook = nc.URL
+			return s, nil
+		}
+		if nc.Channel == "" {
+			return nil, errors.New("slack: channel is required with a bot token")
+		}
+		s.token, s.channel, s.digestChannel = nc.Token, nc.Channel, nc.DigestChannel
+		s.apiBase = strings.TrimRight(nc.URL, "/")
+		if s.apiBase == "" {
+			s.apiBase = "https://slack.com/api"
+		}
+		if s.digestChannel == "" {
+			s.digestChannel = s.channel
+		}
+		s.threadTTL = time.Duration(nc.ThreadSeconds) * time.Second
+		if s.threadTTL <= 0 {
+			s.threadTTL = time.Hour
+		}
+		s.threads = make(map[string]*slackThread)
+		s.buttons = cfg != nil && cfg.Interactive.Addr != ""
+		return s, nil
+	})
+}
+
+// Slack posts to an incoming webhook or, with a bot token, through the Web
+// API. Bot mode threads follow-up events from one source IP under the first
+// alert and edits a running failure counter into it instead of posting
+// every failed attempt.
 type Slack struct {
+	healthTracker
+	templates
+	name    string
 	webhook string
 	client  *http.Client
+	format  config.Format
+
+	token         string
+	apiBase       string
+	channel       string
+	digestChannel string
+	threadTTL     time.Duration
+	// buttons adds Ack / Silence / Block actions handled by SlackActions.
+	buttons bool
+
+	// post keeps one threaded send in flight so that an IP gets a single
+	// thread. mu guards threads and is not held during API calls, so that
+	// actions recorded by SlackActions never wait for Slack.
+	post    sync.Mutex
+	mu      sync.Mutex
+	threads map[string]*slackThread // source IP -> open thread
 }
 
-func NewSlack(cfg *config.Config) *Slack {
-	return &Slack{
-		webhook: cfg.SlackWebhook,
-		client:  &http.Client{Timeout: 10 * time.Second},
-	}
+// slackThread is the first alert posted for a source IP.
+type slackThread struct {
+	channel  string
+	ts       string
+	root     model.Event
+	failures int
+	users    []string
+	last     time.Time
+	// acted are the buttons used on the root, kept when it is re-rendered.
+	acted []slackActed
 }
 
+type slackActed struct {
+	action string
+	note   string
+}
+
+func (s *Slack) Name() string { return s.name }
+
+// SlackMessage is a webhook payload; the channel and thread fields are only
+// used by the Web API.
 type SlackMessage struct {
-	Text   string        `json:"text,omitempty"`
-	Blocks []interface{} `json:"blocks,omitempty"`
+	Channel        string        `json:"channel,omitempty"`
+	TS             string        `json:"ts,omitempty"`
+	ThreadTS       string        `json:"thread_ts,omitempty"`
+	ReplyBroadcast bool          `json:"reply_broadcast,omitempty"`
+	Text           string        `json:"text,omitempty"`
+	Blocks         []interface{} `json:"blocks,omitempty"`
 }
 
+// Send posts msg to the webhook, or in bot mode to msg.Channel (default
+// the alert channel).
 func (s *Slack) Send(ctx context.Context, msg *SlackMessage) error {
+	if s.token != "" {
+		if msg.Channel == "" {
+			msg.Channel = s.channel
+		}
+		_, err := s.call(ctx, "chat.postMessage", msg)
+		return s.track(err)
+	}
 	if s.webhook == "" {
 		return nil
 	}
@@ -39,35 +127,288 @@ func (s *Slack) Send(ctx context.Context, msg *SlackMessage) error {
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return err
+		return s.track(err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("slack webhook status %d", resp.StatusCode)
+		return s.track(&StatusError{Service: "slack", Code: resp.StatusCode, RetryAfter: retryAfter(resp.Header.Get("Retry-After"))})
 	}
-	return nil
+	return s.track(nil)
 }
 
-// Minimal event to Slack text for now; can be upgraded to rich blocks later.
+type slackResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// call invokes a Web API method. Slack reports most errors with HTTP 200
+// and ok=false.
+func (s *Slack) call(ctx context.Context, method string, msg *SlackMessage) (*slackResponse, error) {
+	var resp slackResponse
+	header := map[string]string{"Authorization": "Bearer " + s.token}
+	if err := doJSON(ctx, s.client, "slack", http.MethodPost, s.apiBase+"/"+method, msg, header, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("slack %s: %s", method, resp.Error)
+	}
+	return &resp, nil
+}
+
+func (s *Slack) SendText(ctx context.Context, text string) error {
+	return s.Send(ctx, &SlackMessage{Text: text})
+}
+
+// CountsFailures is true in bot mode, where every failure updates the
+// counter of its thread.
+func (s *Slack) CountsFailures() bool { return s.token != "" }
+
 func (s *Slack) SendEvent(ctx context.Context, ev *model.Event) error {
-	// mrkdwn formatted message using Slack blocks
-	header := "🔐 SSH LOGIN SUCCESS"
-	if ev.Type != "login_success" {
-		header = "🚨 SSH FAILED/INVALID LOGIN"
+	if s.token != "" && ev.SourceIP != "" {
+		return s.track(s.sendThreaded(ctx, ev))
 	}
+	msg, err := s.eventMessage(ev)
+	if err != nil {
+		return s.track(err)
+	}
+	return s.Send(ctx, msg)
+}
+
+// eventMessage applies the user template for ev, if any. Text templates
+// become the message text; JSON templates render a message object or an
+// array of blocks.
+func (s *Slack) eventMessage(ev *model.Event) (*SlackMessage, error) {
+	def := SlackEventMessage(ev, s.format)
+	out, err := s.tmpl.Render(ev)
+	if err != nil || out == nil {
+		return def, err
+	}
+	if !out.JSON {
+		return &SlackMessage{Text: out.Text}, nil
+	}
+	var msg SlackMessage
+	if strings.HasPrefix(strings.TrimSpace(out.Text), "[") {
+		err = json.Unmarshal([]byte(out.Text), &msg.Blocks)
+	} else {
+		err = json.Unmarshal([]byte(out.Text), &msg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("slack template: %w", err)
+	}
+	if msg.Text == "" {
+		msg.Text = def.Text
+	}
+	return &msg, nil
+}
 
+// SlackEventMessage renders an event according to the formatting options:
+// a header and a section of fields, or with Concise a single line. Text is
+// the plain fallback shown in notifications and by clients without blocks.
+func SlackEventMessage(ev *model.Event, f config.Format) *SlackMessage {
+	text := fmt.Sprintf("%s: %s from %s:%d via %s", headline(ev), safe(ev.Username), safe(ev.SourceIP), ev.Port, safe(ev.Method))
+	if f.ShowHostname {
+		text += " on " + safe(ev.Hostname)
+	}
+	showKey := f.ShowKeyFingerprint && ev.KeyFingerprint != ""
+	if showKey {
+		text += " (key " + ev.KeyFingerprint + ")"
+	}
+	if f.Concise {
+		line := fmt.Sprintf("%s `%s` from `%s:%d` via `%s`", headline(ev), safe(ev.Username), safe(ev.SourceIP), ev.Port, safe(ev.Method))
+		if f.ShowHostname {
+			line += fmt.Sprintf(" on `%s`", safe(ev.Hostname))
+		}
+		if showKey {
+			line += fmt.Sprintf(" key `%s`", ev.KeyFingerprint)
+		}
+		line += fmt.Sprintf(" at %s (%s)", ev.Timestamp.Format(time.RFC3339), ev.Level())
+		return &SlackMessage{Text: text, Blocks: []interface{}{
+			map[string]any{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": line}},
+		}}
+	}
 	fields := []map[string]any{
 		{"type": "mrkdwn", "text": fmt.Sprintf("*User*: `%s`", safe(ev.Username))},
 		{"type": "mrkdwn", "text": fmt.Sprintf("*Source*: `%s`:`%d`", safe(ev.SourceIP), ev.Port)},
 		{"type": "mrkdwn", "text": fmt.Sprintf("*Method*: `%s`", safe(ev.Method))},
-		{"type": "mrkdwn", "text": fmt.Sprintf("*Host*: `%s`", safe(ev.Hostname))},
-		{"type": "mrkdwn", "text": fmt.Sprintf("*Time*: `%s`", ev.Timestamp.Format(time.RFC3339))},
 	}
+	if f.ShowHostname {
+		fields = append(fields, map[string]any{"type": "mrkdwn", "text": fmt.Sprintf("*Host*: `%s`", safe(ev.Hostname))})
+	}
+	fields = append(fields, map[string]any{"type": "mrkdwn", "text": fmt.Sprintf("*Time*: `%s`", ev.Timestamp.Format(time.RFC3339))})
+	if showKey {
+		fields = append(fields, map[string]any{"type": "mrkdwn", "text": fmt.Sprintf("*Key*: `%s`", ev.KeyFingerprint)})
+	}
+	fields = append(fields, map[string]any{"type": "mrkdwn", "text": "*Severity*: " + ev.Level().String()})
 	blocks := []interface{}{
-		map[string]any{"type": "header", "text": map[string]any{"type": "plain_text", "text": header}},
+		map[string]any{"type": "header", "text": map[string]any{"type": "plain_text", "text": headline(ev)}},
 		map[string]any{"type": "section", "fields": fields},
 	}
-	return s.Send(ctx, &SlackMessage{Blocks: blocks})
+	return &SlackMessage{Text: text, Blocks: blocks}
+}
+
+// sendThreaded posts the first event from an IP and records it as a thread.
+// Later failures update the counter on that message; other events are
+// replied in the thread, successful logins and rule alerts also to the
+// channel.
+func (s *Slack) sendThreaded(ctx context.Context, ev *model.Event) error {
+	now := ev.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	s.post.Lock()
+	defer s.post.Unlock()
+	s.mu.Lock()
+	for ip, th := range s.threads {
+		if now.Sub(th.last) > s.threadTTL {
+			delete(s.threads, ip)
+		}
+	}
+	th := s.threads[ev.SourceIP]
+	s.mu.Unlock()
+	if th == nil {
+		msg, err := s.rootMessage(ev)
+		if err != nil {
+			return err
+		}
+		msg.Channel = s.channel
+		resp, err := s.call(ctx, "chat.postMessage", msg)
+		if err != nil {
+			return err
+		}
+		th = &slackThread{channel: resp.Channel, ts: resp.TS, root: *ev, last: now}
+		if IsFailure(ev) {
+			th.failures, th.users = 1, []string{ev.Username}
+		}
+		s.mu.Lock()
+		s.threads[ev.SourceIP] = th
+		s.mu.Unlock()
+		return nil
+	}
+	method := "chat.postMessage"
+	s.mu.Lock()
+	th.last = now
+	var msg *SlackMessage
+	var err error
+	if IsFailure(ev) {
+		th.failures++
+		if !slices.Contains(th.users, ev.Username) {
+			th.users = append(th.users, ev.Username)
+		}
+		if msg, err = s.threadMessage(th); err == nil {
+			msg.Channel, msg.TS = th.channel, th.ts
+			method = "chat.update"
+		}
+	} else if msg, err = s.eventMessage(ev); err == nil {
+		msg.Channel, msg.ThreadTS = th.channel, th.ts
+		msg.ReplyBroadcast = ev.Type == "login_success" || ev.Type == "alert"
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	_, err = s.call(ctx, method, msg)
+	return err
+}
+
+// rootMessage is the first alert for an IP, with action buttons when the
+// interactive endpoint is configured.
+func (s *Slack) rootMessage(ev *model.Event) (*SlackMessage, error) {
+	msg, err := s.eventMessage(ev)
+	if err == nil && s.buttons {
+		msg.Blocks = append(msg.Blocks, slackActionButtons(ev.SourceIP))
+	}
+	return msg, err
+}
+
+// threadMessage renders the thread root with its failure counter.
+func (s *Slack) threadMessage(th *slackThread) (*SlackMessage, error) {
+	msg, err := s.rootMessage(&th.root)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range th.acted {
+		for i, b := range msg.Blocks {
+			if m, ok := b.(map[string]any); ok && m["type"] == "actions" {
+				if m = withoutButton(m, a.action); m == nil {
+					msg.Blocks = slices.Delete(msg.Blocks, i, i+1)
+				} else {
+					msg.Blocks[i] = m
+				}
+				break
+			}
+		}
+	}
+	if th.failures > 1 {
+		users := strings.Join(th.users, ", ")
+		if len(users) > 200 {
+			users = truncate(users, 200) + "…"
+		}
+		msg.Blocks = append(msg.Blocks, map[string]any{"type": "context", "elements": []map[string]any{
+			{"type": "mrkdwn", "text": fmt.Sprintf("*%d failed attempts* from `%s`, last at %s · users: %s",
+				th.failures, th.root.SourceIP, th.last.Format(time.RFC3339), safe(users))},
+		}})
+	}
+	for _, a := range th.acted {
+		msg.Blocks = append(msg.Blocks, map[string]any{"type": "context", "elements": []map[string]any{{"type": "mrkdwn", "text": a.note}}})
+	}
+	return msg, nil
+}
+
+// acted records an action taken on the thread root posted as channel/ts,
+// so that counter updates keep its note instead of restoring the button.
+func (s *Slack) acted(channel, ts, action, note string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, th := range s.threads {
+		if th.channel == channel && th.ts == ts {
+			th.acted = append(th.acted, slackActed{action, note})
+			return
+		}
+	}
+}
+
+// SendSummary posts a batch digest with per-type counts and top talkers.
+func (s *Slack) SendSummary(ctx context.Context, sum *model.Summary) error {
+	header := fmt.Sprintf("📊 SSH SUMMARY %s", safe(sum.Hostname))
+	types := sortedKeys(sum.Counts)
+	var counts strings.Builder
+	for _, t := range types {
+		fmt.Fprintf(&counts, "*%s*: %d\n", t, sum.Counts[t])
+	}
+	blocks := []interface{}{
+		map[string]any{"type": "header", "text": map[string]any{"type": "plain_text", "text": header}},
+		map[string]any{"type": "context", "elements": []map[string]any{
+			{"type": "mrkdwn", "text": fmt.Sprintf("%s → %s", sum.Start.Format(time.RFC3339), sum.End.Format(time.RFC3339))},
+		}},
+		map[string]any{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": safe(counts.String())}},
+	}
+	if len(sum.TopSources) > 0 || len(sum.TopUsers) > 0 {
+		blocks = append(blocks, map[string]any{"type": "section", "fields": []map[string]any{
+			{"type": "mrkdwn", "text": "*Top sources*\n" + countList(sum.TopSources)},
+			{"type": "mrkdwn", "text": "*Top users*\n" + countList(sum.TopUsers)},
+		}})
+	}
+	if len(sum.Violations) > 0 {
+		blocks = append(blocks, map[string]any{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": "*Violations*\n" + countList(sum.Violations)}})
+	}
+	if len(sum.Silenced) > 0 {
+		blocks = append(blocks, map[string]any{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": "*Silenced*\n" + countList(sum.Silenced)}})
+	}
+	text := fmt.Sprintf("SSH summary for %s: %d events", safe(sum.Hostname), sum.Total())
+	return s.Send(ctx, &SlackMessage{Channel: s.digestChannel, Text: text, Blocks: blocks})
+}
+
+func countList(cs []model.Count) string {
+	if len(cs) == 0 {
+		return "-"
+	}
+	var b strings.Builder
+	for _, c := range cs {
+		fmt.Fprintf(&b, "`%s` %d\n", c.Key, c.Count)
+	}
+	return b.String()
 }
 
 func safe(s string) string {
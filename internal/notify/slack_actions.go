@@ -0,0 +1,245 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	neturl "net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"ssh-noty/internal/silence"
+)
+
+// Slack action IDs of the alert buttons.
+const (
+	slackActionAck     = "ack"
+	slackActionSilence = "silence_ip"
+	slackActionBlock   = "block_ip"
+)
+
+// slackMaxSkew is how old a signed request may be before it is rejected as
+// a possible replay.
+const slackMaxSkew = 5 * time.Minute
+
+func slackActionButtons(ip string) map[string]any {
+	button := func(id, text string) map[string]any {
+		return map[string]any{"type": "button", "action_id": id, "value": ip, "text": map[string]any{"type": "plain_text", "text": text}}
+	}
+	block := button(slackActionBlock, "Block IP")
+	block["style"] = "danger"
+	block["confirm"] = map[string]any{
+		"title":   map[string]any{"type": "plain_text", "text": "Block " + ip + "?"},
+		"text":    map[string]any{"type": "plain_text", "text": "The firewall command will be run for " + ip + "."},
+		"confirm": map[string]any{"type": "plain_text", "text": "Block"},
+		"deny":    map[string]any{"type": "plain_text", "text": "Cancel"},
+	}
+	return map[string]any{"type": "actions", "block_id": "ssh-noti-actions", "elements": []any{
+		button(slackActionAck, "Ack"),
+		button(slackActionSilence, "Silence this IP 24h"),
+		block,
+	}}
+}
+
+// VerifySlackSignature checks the X-Slack-Signature header, the hex
+// HMAC-SHA256 of "v0:<timestamp>:<body>", and that the timestamp is within
+// five minutes of now.
+func VerifySlackSignature(secret []byte, header http.Header, body []byte, now time.Time) error {
+	tsHeader := header.Get("X-Slack-Request-Timestamp")
+	ts, err := strconv.ParseInt(tsHeader, 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid X-Slack-Request-Timestamp")
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return fmt.Errorf("request timestamp too far from now (%s)", skew.Round(time.Second))
+	}
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "v0:%s:", tsHeader)
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(header.Get("X-Slack-Signature")), []byte(want)) {
+		return errors.New("invalid X-Slack-Signature")
+	}
+	return nil
+}
+
+// SlackActions handles Slack interactivity requests for the alert buttons:
+// Ack marks the alert, Silence adds a 24h silence for the IP and Block runs
+// the firewall command. The message is then updated to show who acted.
+type SlackActions struct {
+	Secret   []byte
+	Silences *silence.Store
+	// Firewall is the command run to block an IP; "{ip}" in any argument
+	// is replaced by the address.
+	Firewall []string
+	// Threads are the bot-mode notifiers that posted the alerts; they keep
+	// the action on the thread root when it is next updated.
+	Threads []*Slack
+	Client  *http.Client
+	// Now is used to check request timestamps; defaults to time.Now.
+	Now func() time.Time
+}
+
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string `json:"response_url"`
+	Channel     struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+	Message struct {
+		TS     string            `json:"ts"`
+		Text   string            `json:"text"`
+		Blocks []json.RawMessage `json:"blocks"`
+	} `json:"message"`
+}
+
+func (a *SlackActions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	if err := VerifySlackSignature(a.Secret, r.Header, body, now()); err != nil {
+		log().Warn("rejected slack action", "remote", r.RemoteAddr, "error", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := neturl.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var in slackInteraction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &in); err != nil || in.Type != "block_actions" || len(in.Actions) == 0 {
+		http.Error(w, "unsupported payload", http.StatusBadRequest)
+		return
+	}
+	// Slack expects an answer within 3s; act and update the message after.
+	w.WriteHeader(http.StatusOK)
+	go a.handle(&in)
+}
+
+func (a *SlackActions) handle(in *slackInteraction) {
+	act := in.Actions[0]
+	who := "<@" + in.User.ID + ">"
+	note := a.apply(act.ActionID, act.Value, in.User.Username, who)
+	log().Info("slack action", "action", act.ActionID, "ip", act.Value, "user", in.User.Username, "result", note)
+	for _, s := range a.Threads {
+		s.acted(in.Channel.ID, in.Message.TS, act.ActionID, note)
+	}
+
+	blocks := make([]any, 0, len(in.Message.Blocks)+1)
+	for _, raw := range in.Message.Blocks {
+		var b map[string]any
+		if json.Unmarshal(raw, &b) != nil {
+			continue
+		}
+		if b["type"] == "actions" {
+			b = withoutButton(b, act.ActionID)
+			if b == nil {
+				continue
+			}
+		}
+		blocks = append(blocks, b)
+	}
+	blocks = append(blocks, map[string]any{"type": "context", "elements": []map[string]any{{"type": "mrkdwn", "text": note}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	msg := map[string]any{"replace_original": true, "text": in.Message.Text, "blocks": blocks}
+	if err := doJSON(ctx, client, "slack", http.MethodPost, in.ResponseURL, msg, nil, nil); err != nil {
+		log().Warn("failed to update slack message", "error", err)
+	}
+}
+
+// apply performs the action and returns the note added to the message.
+func (a *SlackActions) apply(action, value, user, who string) string {
+	ip, err := netip.ParseAddr(value)
+	if err != nil {
+		return fmt.Sprintf("⚠️ %s: invalid IP %q", who, value)
+	}
+	switch action {
+	case slackActionAck:
+		return "✅ Acknowledged by " + who
+	case slackActionSilence:
+		if a.Silences == nil {
+			return "⚠️ Silences are not configured"
+		}
+		sl, err := a.Silences.Add(silence.Silence{IP: ip.String(), End: time.Now().Add(24 * time.Hour), CreatedBy: user, Comment: "Slack action"})
+		if err != nil {
+			return fmt.Sprintf("⚠️ Silencing %s failed: %v", ip, err)
+		}
+		return fmt.Sprintf("🔕 %s silenced until %s by %s", ip, sl.End.Format(time.RFC3339), who)
+	case slackActionBlock:
+		if len(a.Firewall) == 0 {
+			return "⚠️ No firewall command is configured"
+		}
+		if err := a.block(ip); err != nil {
+			return fmt.Sprintf("⚠️ Blocking %s failed: %v", ip, err)
+		}
+		return fmt.Sprintf("⛔ %s blocked by %s", ip, who)
+	}
+	return fmt.Sprintf("⚠️ Unknown action %q", action)
+}
+
+func (a *SlackActions) block(ip netip.Addr) error {
+	args := make([]string, len(a.Firewall))
+	for i, arg := range a.Firewall {
+		args[i] = strings.ReplaceAll(arg, "{ip}", ip.String())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	return err
+}
+
+// withoutButton removes the button with action id from an actions block;
+// it returns nil when no buttons remain.
+func withoutButton(block map[string]any, id string) map[string]any {
+	elems, _ := block["elements"].([]any)
+	var kept []any
+	for _, e := range elems {
+		if m, ok := e.(map[string]any); ok && m["action_id"] == id {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	block["elements"] = kept
+	return block
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/silence"
+)
+
+func slackSign(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	// Example from Slack's "Verifying requests" documentation.
+	secret := []byte("8f742231b10e8888abcd99yyyzzz85a5")
+	body := []byte("token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c")
+	now := time.Unix(1531420618, 0)
+	h := http.Header{}
+	h.Set("X-Slack-Request-Timestamp", "1531420618")
+	h.Set("X-Slack-Signature", "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503")
+	if err := VerifySlackSignature(secret, h, body, now); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	cases := map[string]func(h http.Header) ([]byte, time.Time){
+		"tampered body": func(h http.Header) ([]byte, time.Time) { return append(body, '1'), now },
+		"wrong secret": func(h http.Header) ([]byte, time.Time) {
+			h.Set("X-Slack-Signature", slackSign("other", "1531420618", body))
+			return body, now
+		},
+		"stale":         func(h http.Header) ([]byte, time.Time) { return body, now.Add(6 * time.Minute) },
+		"future":        func(h http.Header) ([]byte, time.Time) { return body, now.Add(-6 * time.Minute) },
+		"no timestamp":  func(h http.Header) ([]byte, time.Time) { h.Del("X-Slack-Request-Timestamp"); return body, now },
+		"no signature":  func(h http.Header) ([]byte, time.Time) { h.Del("X-Slack-Signature"); return body, now },
+		"bad signature": func(h http.Header) ([]byte, time.Time) { h.Set("X-Slack-Signature", "v0=zz"); return body, now },
+	}
+	for name, mutate := range cases {
+		hc := h.Clone()
+		b, at := mutate(hc)
+		if err := VerifySlackSignature(secret, hc, b, at); err == nil {
+			t.Errorf("%s: expected rejection", name)
+		}
+	}
+	if err := VerifySlackSignature(secret, h, body, now.Add(4*time.Minute)); err != nil {
+		t.Errorf("skew within 5 minutes rejected: %v", err)
+	}
+}
+
+func TestSlackActions_SilenceUpdatesMessage(t *testing.T) {
+	updated := make(chan map[string]any, 1)
+	respSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]any
+		json.NewDecoder(r.Body).Decode(&m)
+		updated <- m
+	}))
+	defer respSrv.Close()
+
+	store, _ := silence.Open(filepath.Join(t.TempDir(), "silences.json"))
+	a := &SlackActions{Secret: []byte("s3cret"), Silences: store}
+	msg := SlackEventMessage(testEvent("root"), config.Format{})
+	msg.Blocks = append(msg.Blocks, slackActionButtons("203.0.113.5"))
+	payload, _ := json.Marshal(map[string]any{
+		"type":         "block_actions",
+		"user":         map[string]string{"id": "U1", "username": "alice"},
+		"actions":      []map[string]string{{"action_id": slackActionSilence, "value": "203.0.113.5"}},
+		"response_url": respSrv.URL,
+		"message":      map[string]any{"text": "alert", "blocks": msg.Blocks},
+	})
+	body := []byte("payload=" + neturl.QueryEscape(string(payload)))
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req := httptest.NewRequest(http.MethodPost, "/slack/actions", strings.NewReader(string(body)))
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", slackSign("s3cret", ts, body))
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var m map[string]any
+	select {
+	case m = <-updated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not updated")
+	}
+	b, _ := json.Marshal(m)
+	if m["replace_original"] != true || !strings.Contains(string(b), "silenced until") || !strings.Contains(string(b), "\\u003c@U1\\u003e") || strings.Contains(string(b), slackActionSilence) {
+		t.Fatalf("unexpected update: %s", b)
+	}
+	if !strings.Contains(string(b), slackActionBlock) {
+		t.Fatal("other buttons should remain")
+	}
+	if store.Match(&model.Event{SourceIP: "203.0.113.5"}, time.Now()) == nil {
+		t.Fatal("IP was not silenced")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/slack/actions", strings.NewReader(string(body)))
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", slackSign("wrong", ts, body))
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unsigned request accepted: %d", rec.Code)
+	}
+}
+
+func TestSlackActions_Block(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "blocked")
+	a := &SlackActions{Firewall: []string{"/bin/sh", "-c", `echo "$1" > "$0"`, out, "{ip}"}}
+	if note := a.apply(slackActionBlock, "198.51.100.9", "alice", "<@U1>"); !strings.HasPrefix(note, "⛔ 198.51.100.9 blocked") {
+		t.Fatalf("unexpected note %q", note)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "198.51.100.9\n" {
+		t.Fatalf("firewall command got %q", b)
+	}
+	if note := a.apply(slackActionBlock, "1.2.3.4; rm -rf /", "alice", "<@U1>"); !strings.Contains(note, "invalid IP") {
+		t.Fatalf("non-IP values must be rejected: %q", note)
+	}
+}
@@ -0,0 +1,268 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+type slackCall struct {
+	method string
+	msg    SlackMessage
+}
+
+// fakeSlackAPI answers chat.postMessage and chat.update like the Web API.
+func fakeSlackAPI(t *testing.T) (*httptest.Server, *[]slackCall) {
+	var calls []slackCall
+	n := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-1" {
+			w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+			return
+		}
+		var c slackCall
+		c.method = strings.TrimPrefix(r.URL.Path, "/")
+		json.NewDecoder(r.Body).Decode(&c.msg)
+		calls = append(calls, c)
+		n++
+		ts := c.msg.TS
+		if ts == "" {
+			ts = fmt.Sprintf("1700000000.%06d", n)
+		}
+		fmt.Fprintf(w, `{"ok":true,"channel":"C1","ts":%q}`, ts)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestSlackBot_ThreadsAndCounter(t *testing.T) {
+	srv, calls := fakeSlackAPI(t)
+	n, err := registry["slack"](config.Notifier{Name: "slack", Token: "xoxb-1", URL: srv.URL, Channel: "#alerts", DigestChannel: "#digest"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	fail := func(user string) *model.Event {
+		ev := testEvent(user)
+		ev.Type = "login_failure"
+		return ev
+	}
+	for _, ev := range []*model.Event{fail("root"), fail("admin"), fail("root"), testEvent("admin")} {
+		if err := n.SendEvent(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := testEvent("bob")
+	other.SourceIP = "198.51.100.7"
+	n.SendEvent(ctx, other)
+	n.SendSummary(ctx, &model.Summary{Hostname: "web1", Counts: map[string]int{"login_failure": 3}})
+
+	c := *calls
+	if len(c) != 6 {
+		t.Fatalf("expected 6 API calls, got %d: %+v", len(c), c)
+	}
+	if c[0].method != "chat.postMessage" || c[0].msg.Channel != "#alerts" {
+		t.Fatalf("first failure should be posted: %+v", c[0])
+	}
+	for _, u := range c[1:3] {
+		if u.method != "chat.update" || u.msg.TS != "1700000000.000001" || u.msg.Channel != "C1" {
+			t.Fatalf("repeated failures should update the first message: %+v", u)
+		}
+	}
+	b, _ := json.Marshal(c[2].msg.Blocks)
+	if !strings.Contains(string(b), "*3 failed attempts*") || !strings.Contains(string(b), "users: root, admin") {
+		t.Fatalf("counter not updated: %s", b)
+	}
+	if c[3].msg.ThreadTS != "1700000000.000001" || !c[3].msg.ReplyBroadcast {
+		t.Fatalf("success from the same IP should be a broadcast thread reply: %+v", c[3].msg)
+	}
+	if c[4].msg.ThreadTS != "" || c[5].msg.Channel != "#digest" {
+		t.Fatalf("unexpected routing: %+v / %+v", c[4].msg, c[5].msg)
+	}
+}
+
+func TestSlackBot_CounterKeepsActions(t *testing.T) {
+	srv, calls := fakeSlackAPI(t)
+	cfg := &config.Config{Interactive: config.Interactive{Addr: ":8443"}}
+	n, err := registry["slack"](config.Notifier{Name: "slack", Token: "xoxb-1", URL: srv.URL, Channel: "#alerts"}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	ev := testEvent("root")
+	ev.Type = "login_failure"
+	n.SendEvent(ctx, ev)
+	n.(*Slack).acted("C1", "1700000000.000001", slackActionAck, "✅ Acknowledged by <@U1>")
+	n.SendEvent(ctx, ev)
+
+	c := *calls
+	if len(c) != 2 || c[1].method != "chat.update" {
+		t.Fatalf("expected a post and an update, got %+v", c)
+	}
+	b, _ := json.Marshal(c[1].msg.Blocks)
+	if strings.Contains(string(b), `"action_id":"`+slackActionAck+`"`) || !strings.Contains(string(b), "Acknowledged by") || !strings.Contains(string(b), slackActionBlock) {
+		t.Fatalf("update lost the action state: %s", b)
+	}
+}
+
+func TestSlackBot_ActionsDoNotWaitForAPICalls(t *testing.T) {
+	updating, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chat.update" {
+			close(updating)
+			<-release
+		}
+		w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1.0"}`))
+	}))
+	defer srv.Close()
+	defer close(release)
+	n, err := registry["slack"](config.Notifier{Name: "slack", Token: "xoxb-1", URL: srv.URL, Channel: "#alerts"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := testEvent("root")
+	ev.Type = "login_failure"
+	n.SendEvent(context.Background(), ev)
+	go n.SendEvent(context.Background(), ev)
+	<-updating
+
+	acted := make(chan struct{})
+	go func() {
+		n.(*Slack).acted("C1", "1.0", slackActionAck, "✅ Acknowledged")
+		close(acted)
+	}()
+	select {
+	case <-acted:
+	case <-time.After(time.Second):
+		t.Fatal("recording an action waited for a pending chat.update")
+	}
+}
+
+func TestSlackBot_AlertsAreBroadcast(t *testing.T) {
+	srv, calls := fakeSlackAPI(t)
+	n, err := registry["slack"](config.Notifier{Name: "slack", Token: "xoxb-1", URL: srv.URL, Channel: "#alerts"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	ev := testEvent("root")
+	ev.Type = "login_failure"
+	n.SendEvent(ctx, ev)
+	alert := testEvent("root")
+	alert.Type, alert.Fields = "alert", map[string]string{"rule": "root-after-failures", "alert": "root logged in after failures"}
+	n.SendEvent(ctx, alert)
+
+	c := *calls
+	if len(c) != 2 || c[1].msg.ThreadTS != "1700000000.000001" || !c[1].msg.ReplyBroadcast {
+		t.Fatalf("an alert in a thread should also go to the channel: %+v", c)
+	}
+}
+
+func TestSlackBot_APIError(t *testing.T) {
+	srv, _ := fakeSlackAPI(t)
+	n, _ := registry["slack"](config.Notifier{Name: "slack", Token: "wrong", URL: srv.URL, Channel: "#alerts"}, nil)
+	if err := n.SendEvent(context.Background(), testEvent("a")); err == nil || !strings.Contains(err.Error(), "invalid_auth") {
+		t.Fatalf("expected invalid_auth, got %v", err)
+	}
+	if _, err := registry["slack"](config.Notifier{Name: "slack", Token: "x"}, nil); err == nil {
+		t.Fatal("bot mode requires a channel")
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// golden compares got with testdata/name, rewriting it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch:\n--- got\n%s\n--- want\n%s", name, got, want)
+	}
+}
+
+func TestSlackEventMessage_Golden(t *testing.T) {
+	ev := testEvent("alice")
+	ev.KeyFingerprint = "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"
+	for _, concise := range []bool{false, true} {
+		for _, host := range []bool{false, true} {
+			for _, key := range []bool{false, true} {
+				f := config.Format{Concise: concise, ShowHostname: host, ShowKeyFingerprint: key}
+				name := "slack_event_full"
+				if concise {
+					name = "slack_event_concise"
+				}
+				if host {
+					name += "_host"
+				}
+				if key {
+					name += "_key"
+				}
+				t.Run(name, func(t *testing.T) {
+					b, err := json.MarshalIndent(SlackEventMessage(ev, f), "", "  ")
+					if err != nil {
+						t.Fatal(err)
+					}
+					golden(t, name+".json", append(b, '\n'))
+				})
+			}
+		}
+	}
+}
+
+func TestSlack_Templates(t *testing.T) {
+	var got []SlackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m SlackMessage
+		json.NewDecoder(r.Body).Decode(&m)
+		got = append(got, m)
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "fail.txt"), []byte("{{.Username}} failed from {{.SourceIP}}"), 0o644)
+	os.WriteFile(filepath.Join(dir, "ok.json"), []byte(`[{"type":"section","text":{"type":"mrkdwn","text":{{json .Username}}}}]`), 0o644)
+	sinks, err := Build(&config.Config{Notifiers: []config.Notifier{{Type: "slack", Name: "slack", URL: srv.URL, Templates: map[string]string{
+		"login_failure": filepath.Join(dir, "fail.txt"),
+		"login_success": filepath.Join(dir, "ok.json"),
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := testEvent("root")
+	fail.Type = "login_failure"
+	for _, ev := range []*model.Event{fail, testEvent("alice")} {
+		if err := sinks[0].SendEvent(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 2 || got[0].Text != "root failed from 203.0.113.5" || got[0].Blocks != nil {
+		t.Fatalf("text template should replace the message: %+v", got)
+	}
+	if len(got[1].Blocks) != 1 || !strings.Contains(got[1].Text, "alice") {
+		t.Fatalf("json template should set blocks and keep the fallback text: %+v", got[1])
+	}
+}
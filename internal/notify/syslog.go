@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	neturl "net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("syslog", newSyslog)
+}
+
+// syslogSDID is the structured data ID of event parameters. 32473 is the
+// private enterprise number reserved for documentation (RFC 5612).
+const syslogSDID = "ssh-noti@32473"
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog re-emits normalised events as RFC 5424 messages carrying the event
+// fields in an SD-element. url selects the transport: unixgram:///dev/log
+// (default), unix://, udp://host:514 or tcp://host:514 (octet-counted).
+type Syslog struct {
+	healthTracker
+	name     string
+	network  string
+	addr     string
+	facility int
+	hostname string
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newSyslog(nc config.Notifier, _ *config.Config) (Notifier, error) {
+	s := &Syslog{name: nc.Name, network: "unixgram", addr: "/dev/log", facility: syslogFacilities["local0"], timeout: nc.Timeout(5 * time.Second)}
+	if nc.URL != "" {
+		u, err := neturl.Parse(nc.URL)
+		if err != nil {
+			return nil, fmt.Errorf("syslog: url: %w", err)
+		}
+		switch u.Scheme {
+		case "unix", "unixgram":
+			s.network, s.addr = u.Scheme, u.Path
+		case "udp", "tcp":
+			s.network, s.addr = u.Scheme, u.Host
+		default:
+			return nil, fmt.Errorf("syslog: unsupported url scheme %q", u.Scheme)
+		}
+	}
+	if nc.Facility != "" {
+		f, ok := syslogFacilities[strings.ToLower(nc.Facility)]
+		if !ok {
+			return nil, fmt.Errorf("syslog: unknown facility %q", nc.Facility)
+		}
+		s.facility = f
+	}
+	s.hostname, _ = os.Hostname()
+	return s, nil
+}
+
+func (s *Syslog) Name() string { return s.name }
+
+// syslogSeverity maps event severity to syslog severity codes.
+func syslogSeverity(sev model.Severity) int {
+	switch sev {
+	case model.SeverityCritical:
+		return 2 // crit
+	case model.SeverityHigh:
+		return 3 // err
+	case model.SeverityMedium:
+		return 4 // warning
+	case model.SeverityLow:
+		return 5 // notice
+	default:
+		return 6 // info
+	}
+}
+
+func (s *Syslog) SendEvent(ctx context.Context, ev *model.Event) error {
+	params := []fact{
+		{"type", ev.Type}, {"user", ev.Username}, {"src", ev.SourceIP}, {"port", fmt.Sprint(ev.Port)},
+		{"method", ev.Method}, {"severity", ev.Level().String()},
+	}
+	if ev.KeyFingerprint != "" {
+		params = append(params, fact{"fingerprint", ev.KeyFingerprint})
+	}
+	msg := fmt.Sprintf("%s: %s from %s", title(ev), safe(ev.Username), safe(ev.SourceIP))
+	return s.track(s.send(ctx, syslogSeverity(ev.Level()), ev.Timestamp, ev.Hostname, ev.Type, sdElement(syslogSDID, params), msg))
+}
+
+func (s *Syslog) SendSummary(ctx context.Context, sum *model.Summary) error {
+	var params []fact
+	for _, k := range sortedKeys(sum.Counts) {
+		params = append(params, fact{k, fmt.Sprint(sum.Counts[k])})
+	}
+	msg := fmt.Sprintf("SSH summary %s - %s: %d events", sum.Start.Format(time.RFC3339), sum.End.Format(time.RFC3339), sum.Total())
+	if n := silencedTotal(sum); n > 0 {
+		msg += fmt.Sprintf(", %d silenced", n)
+	}
+	return s.track(s.send(ctx, 6, sum.End, sum.Hostname, "summary", sdElement(syslogSDID, params), msg))
+}
+
+func (s *Syslog) SendText(ctx context.Context, text string) error {
+	return s.track(s.send(ctx, 4, time.Now(), "", "message", "-", text))
+}
+
+// sdElement renders [id name="value" ...], escaping '"', '\' and ']' and
+// dropping characters that are invalid in parameter names.
+func sdElement(id string, params []fact) string {
+	if len(params) == 0 {
+		return "-"
+	}
+	esc := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	var b strings.Builder
+	b.WriteString("[" + id)
+	for _, p := range params {
+		name := strings.Map(func(r rune) rune {
+			if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+				return -1
+			}
+			return r
+		}, p.Title)
+		if len(name) > 32 {
+			name = name[:32]
+		}
+		fmt.Fprintf(&b, ` %s="%s"`, name, esc.Replace(p.Value))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// format builds an RFC 5424 message: <PRI>1 TIMESTAMP HOST APP PROCID MSGID SD MSG.
+func (s *Syslog) format(sev int, ts time.Time, host, msgID, sd, msg string) string {
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	if host == "" {
+		host = s.hostname
+	}
+	if host == "" {
+		host = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s ssh-noti %d %s %s %s",
+		s.facility*8+sev, ts.Format("2006-01-02T15:04:05.000000Z07:00"), strings.ReplaceAll(host, " ", "_"), os.Getpid(), msgID, sd, msg)
+}
+
+func (s *Syslog) send(ctx context.Context, sev int, ts time.Time, host, msgID, sd, msg string) error {
+	line := s.format(sev, ts, host, msgID, sd, msg)
+	if s.network == "tcp" {
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Retry once on a fresh connection, e.g. after syslogd restarted.
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			d := net.Dialer{Timeout: s.timeout}
+			if s.conn, err = d.DialContext(ctx, s.network, s.addr); err != nil {
+				return err
+			}
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		if _, err = s.conn.Write([]byte(line)); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
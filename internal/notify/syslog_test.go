@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/config"
+)
+
+func TestSdElementEscaping(t *testing.T) {
+	got := sdElement("x@1", []fact{{"user", `a"b\c]d`}, {"bad name=", "v"}})
+	if want := `[x@1 user="a\"b\\c\]d" badname="v"]`; got != want {
+		t.Fatalf("sdElement = %s, want %s", got, want)
+	}
+	if sdElement("x@1", nil) != "-" {
+		t.Fatal("empty SD should be the nil value")
+	}
+}
+
+func TestSyslog_RFC5424OverUnixgram(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenPacket("unixgram", sock)
+	if err != nil {
+		t.Skipf("unixgram unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	n, err := newSyslog(config.Notifier{Name: "syslog", URL: "unixgram://" + sock, Facility: "authpriv"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := testEvent("root")
+	ev.KeyFingerprint = "SHA256:abc"
+	if err := n.SendEvent(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	k, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// authpriv(10)*8 + err(3) = 83 for a high severity root login.
+	re := regexp.MustCompile(`^<83>1 2024-01-01T10:00:00\.000000Z web1 ssh-noti \d+ login_success \[ssh-noti@32473 type="login_success" user="root" src="203\.0\.113\.5" port="5000" method="publickey" severity="high" fingerprint="SHA256:abc"\] SSH LOGIN SUCCESS: root from 203\.0\.113\.5$`)
+	if !re.Match(buf[:k]) {
+		t.Fatalf("unexpected message %q", buf[:k])
+	}
+}
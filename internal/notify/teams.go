@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/tmpl"
+)
+
+func init() {
+	Register("teams", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		if nc.URL == "" {
+			return nil, errors.New("teams: url (incoming webhook or Workflows URL) is required")
+		}
+		return &Teams{name: nc.Name, url: nc.URL, client: &http.Client{Timeout: nc.Timeout(10 * time.Second)}}, nil
+	})
+}
+
+// Teams posts Adaptive Cards to a Microsoft Teams incoming webhook or a
+// Workflows "post to a channel when a webhook request is received" URL.
+type Teams struct {
+	healthTracker
+	templates
+	name   string
+	url    string
+	client *http.Client
+}
+
+func (t *Teams) Name() string { return t.name }
+
+// teamsStyle maps severity to an Adaptive Card container style.
+func teamsStyle(s model.Severity) string {
+	switch s {
+	case model.SeverityCritical, model.SeverityHigh:
+		return "attention"
+	case model.SeverityMedium:
+		return "warning"
+	case model.SeverityLow:
+		return "accent"
+	default:
+		return "good"
+	}
+}
+
+func (t *Teams) SendEvent(ctx context.Context, ev *model.Event) error {
+	if out, err := t.tmpl.Render(ev); err != nil {
+		return t.track(err)
+	} else if out != nil {
+		return t.sendTemplate(ctx, headline(ev), out)
+	}
+	facts := make([]map[string]any, 0, 6)
+	for _, f := range eventFacts(ev) {
+		facts = append(facts, map[string]any{"title": f.Title, "value": f.Value})
+	}
+	facts = append(facts, map[string]any{"title": "Severity", "value": ev.Level().String()})
+	body := []any{
+		map[string]any{
+			"type":  "Container",
+			"style": teamsStyle(ev.Level()),
+			"bleed": true,
+			"items": []any{map[string]any{"type": "TextBlock", "text": headline(ev), "weight": "Bolder", "size": "Medium", "wrap": true}},
+		},
+		map[string]any{"type": "FactSet", "facts": facts},
+	}
+	return t.send(ctx, headline(ev), body)
+}
+
+func (t *Teams) SendSummary(ctx context.Context, sum *model.Summary) error {
+	types := sortedKeys(sum.Counts)
+	counts := make([]map[string]any, 0, len(types))
+	for _, k := range types {
+		counts = append(counts, map[string]any{"title": k, "value": fmt.Sprint(sum.Counts[k])})
+	}
+	title := fmt.Sprintf("📊 SSH SUMMARY %s", safe(sum.Hostname))
+	body := []any{
+		map[string]any{"type": "TextBlock", "text": title, "weight": "Bolder", "size": "Medium"},
+		map[string]any{"type": "TextBlock", "text": fmt.Sprintf("%s → %s", sum.Start.Format(time.RFC3339), sum.End.Format(time.RFC3339)), "isSubtle": true, "spacing": "None"},
+		map[string]any{"type": "FactSet", "facts": counts},
+	}
+	if len(sum.TopSources) > 0 || len(sum.TopUsers) > 0 {
+		body = append(body, map[string]any{"type": "ColumnSet", "columns": []any{
+			teamsCountColumn("Top sources", sum.TopSources),
+			teamsCountColumn("Top users", sum.TopUsers),
+		}})
+	}
+	if len(sum.Violations) > 0 {
+		body = append(body, map[string]any{"type": "ColumnSet", "columns": []any{teamsCountColumn("Violations", sum.Violations)}})
+	}
+	if len(sum.Silenced) > 0 {
+		body = append(body, map[string]any{"type": "ColumnSet", "columns": []any{teamsCountColumn("Silenced", sum.Silenced)}})
+	}
+	return t.send(ctx, title, body)
+}
+
+func (t *Teams) SendText(ctx context.Context, text string) error {
+	return t.send(ctx, text, []any{map[string]any{"type": "TextBlock", "text": text, "wrap": true}})
+}
+
+func teamsCountColumn(title string, cs []model.Count) map[string]any {
+	items := []any{map[string]any{"type": "TextBlock", "text": title, "weight": "Bolder"}}
+	for _, c := range cs {
+		items = append(items, map[string]any{"type": "TextBlock", "text": fmt.Sprintf("%s (%d)", c.Key, c.Count), "spacing": "None"})
+	}
+	return map[string]any{"type": "Column", "width": "stretch", "items": items}
+}
+
+// sendTemplate posts a user template: JSON templates render the card body
+// (an array) or a whole card (an object), text templates a TextBlock.
+func (t *Teams) sendTemplate(ctx context.Context, summary string, out *tmpl.Output) error {
+	if !out.JSON {
+		return t.send(ctx, summary, []any{map[string]any{"type": "TextBlock", "text": out.Text, "wrap": true}})
+	}
+	var v any
+	if err := json.Unmarshal([]byte(out.Text), &v); err != nil {
+		return t.track(fmt.Errorf("teams template: %w", err))
+	}
+	switch v := v.(type) {
+	case []any:
+		return t.send(ctx, summary, v)
+	case map[string]any:
+		return t.post(ctx, summary, v)
+	default:
+		return t.track(errors.New("teams template must render a card or an array of card elements"))
+	}
+}
+
+func (t *Teams) send(ctx context.Context, summary string, body []any) error {
+	return t.post(ctx, summary, map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"msteams": map[string]any{"width": "Full"},
+		"body":    body,
+	})
+}
+
+func (t *Teams) post(ctx context.Context, summary string, card map[string]any) error {
+	payload := map[string]any{
+		"type":    "message",
+		"summary": summary,
+		"attachments": []any{map[string]any{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+	return t.track(doJSON(ctx, t.client, "teams", http.MethodPost, t.url, payload, nil, nil))
+}
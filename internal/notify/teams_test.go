@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+// teamsStandIn records the last Adaptive Card posted to it.
+func teamsStandIn(t *testing.T, status int) (*httptest.Server, *map[string]any) {
+	var card map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Type        string `json:"type"`
+			Attachments []struct {
+				ContentType string         `json:"contentType"`
+				Content     map[string]any `json:"content"`
+			} `json:"attachments"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("invalid json: %v", err)
+		}
+		if msg.Type != "message" || len(msg.Attachments) != 1 || msg.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+			t.Errorf("unexpected envelope: %+v", msg)
+		} else {
+			card = msg.Attachments[0].Content
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &card
+}
+
+func TestTeams_SendEvent(t *testing.T) {
+	srv, card := teamsStandIn(t, http.StatusAccepted)
+	n, err := Build(&config.Config{Notifiers: []config.Notifier{{Type: "teams", Name: "teams", URL: srv.URL}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := &model.Event{Type: "login_success", Username: "root", SourceIP: "203.0.113.5", Port: 5000, Method: "publickey", Hostname: "web1", Timestamp: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+	if err := n[0].SendEvent(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	body := (*card)["body"].([]any)
+	header := body[0].(map[string]any)
+	if header["style"] != "attention" {
+		t.Fatalf("root login should render with attention style: %v", header)
+	}
+	facts := body[1].(map[string]any)["facts"].([]any)
+	want := map[string]string{"User": "root", "Source": "203.0.113.5:5000", "Method": "publickey", "Host": "web1", "Time": "2024-01-01T10:00:00Z", "Severity": "high"}
+	for _, f := range facts {
+		m := f.(map[string]any)
+		if want[m["title"].(string)] != m["value"] {
+			t.Fatalf("fact %v != %q", m, want[m["title"].(string)])
+		}
+		delete(want, m["title"].(string))
+	}
+	if len(want) != 0 {
+		t.Fatalf("missing facts: %v", want)
+	}
+}
+
+func TestTeams_SummaryAndErrors(t *testing.T) {
+	srv, card := teamsStandIn(t, http.StatusOK)
+	tm := &Teams{name: "teams", url: srv.URL, client: srv.Client()}
+	sum := &model.Summary{Hostname: "web1", Counts: map[string]int{"login_failure": 12, "login_success": 1}, TopSources: []model.Count{{Key: "198.51.100.1", Count: 12}}}
+	if err := tm.SendSummary(context.Background(), sum); err != nil {
+		t.Fatal(err)
+	}
+	if body := (*card)["body"].([]any); len(body) != 4 {
+		t.Fatalf("expected title, window, counts and top columns: %v", body)
+	}
+
+	bad, _ := teamsStandIn(t, http.StatusTooManyRequests)
+	tm.url = bad.URL
+	err := tm.SendText(context.Background(), "hello")
+	if se, ok := err.(*StatusError); !ok || se.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	if tm.Health().OK() {
+		t.Fatal("health should record the failure")
+	}
+}
+
+func TestTeams_TemplateCard(t *testing.T) {
+	srv, card := teamsStandIn(t, http.StatusAccepted)
+	path := filepath.Join(t.TempDir(), "card.json")
+	os.WriteFile(path, []byte(`{"type":"AdaptiveCard","version":"1.5","body":[{"type":"TextBlock","text":{{json .Username}}}]}`), 0o644)
+	n, err := Build(&config.Config{Notifiers: []config.Notifier{{Type: "teams", Name: "teams", URL: srv.URL, Templates: map[string]string{"default": path}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n[0].SendEvent(context.Background(), testEvent("alice")); err != nil {
+		t.Fatal(err)
+	}
+	if (*card)["version"] != "1.5" || (*card)["body"].([]any)[0].(map[string]any)["text"] != "alice" {
+		t.Fatalf("template should be posted as the card: %v", *card)
+	}
+}
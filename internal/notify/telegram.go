@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func init() {
+	Register("telegram", func(nc config.Notifier, _ *config.Config) (Notifier, error) {
+		if nc.Token == "" || len(nc.ChatIDs) == 0 {
+			return nil, errors.New("telegram: token and chat_ids are required")
+		}
+		t := &Telegram{
+			name:    nc.Name,
+			apiBase: strings.TrimRight(nc.URL, "/"),
+			token:   nc.Token,
+			chatIDs: nc.ChatIDs,
+			client:  &http.Client{Timeout: nc.Timeout(10 * time.Second)},
+		}
+		if t.apiBase == "" {
+			t.apiBase = "https://api.telegram.org"
+		}
+		if nc.SilentBelow != "" {
+			sev, err := model.ParseSeverity(nc.SilentBelow)
+			if err != nil {
+				return nil, fmt.Errorf("telegram: silent_below: %w", err)
+			}
+			t.silentBelow = sev
+		}
+		return t, nil
+	})
+}
+
+// Telegram sends MarkdownV2 messages through the Bot API to one or more chats.
+type Telegram struct {
+	healthTracker
+	templates
+	name        string
+	apiBase     string
+	token       string
+	chatIDs     []string
+	silentBelow model.Severity
+	client      *http.Client
+}
+
+func (t *Telegram) Name() string { return t.name }
+
+// markdownV2Special are the characters Telegram requires to be escaped
+// outside code entities.
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// escapeMarkdownV2 escapes s for use as plain MarkdownV2 text.
+func escapeMarkdownV2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownV2Special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// escapeMarkdownV2Code escapes s for use inside `code` spans, where only
+// backtick and backslash are special.
+func escapeMarkdownV2Code(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s)
+}
+
+func (t *Telegram) SendEvent(ctx context.Context, ev *model.Event) error {
+	silent := t.silentBelow != model.SeverityUnset && ev.Level() < t.silentBelow
+	// Templated messages are sent as plain text.
+	if text, ok, err := t.render(ev); err != nil {
+		return t.track(err)
+	} else if ok {
+		return t.track(t.send(ctx, text, "", silent))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n", escapeMarkdownV2(headline(ev)))
+	for _, f := range eventFacts(ev) {
+		fmt.Fprintf(&b, "*%s*: `%s`\n", escapeMarkdownV2(f.Title), escapeMarkdownV2Code(f.Value))
+	}
+	fmt.Fprintf(&b, "*Severity*: %s", escapeMarkdownV2(ev.Level().String()))
+	return t.track(t.send(ctx, b.String(), "MarkdownV2", silent))
+}
+
+func (t *Telegram) SendSummary(ctx context.Context, sum *model.Summary) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n", escapeMarkdownV2("📊 SSH SUMMARY "+safe(sum.Hostname)))
+	fmt.Fprintf(&b, "_%s_\n\n", escapeMarkdownV2(sum.Start.Format(time.RFC3339)+" → "+sum.End.Format(time.RFC3339)))
+	types := sortedKeys(sum.Counts)
+	for _, k := range types {
+		fmt.Fprintf(&b, "*%s*: %d\n", escapeMarkdownV2(k), sum.Counts[k])
+	}
+	for _, sec := range summarySections(sum) {
+		fmt.Fprintf(&b, "\n*%s*\n", sec.Title)
+		for _, c := range sec.List {
+			fmt.Fprintf(&b, "`%s` %d\n", escapeMarkdownV2Code(c.Key), c.Count)
+		}
+	}
+	return t.track(t.send(ctx, b.String(), "MarkdownV2", true))
+}
+
+func (t *Telegram) SendText(ctx context.Context, text string) error {
+	return t.track(t.send(ctx, escapeMarkdownV2(text), "MarkdownV2", false))
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// send delivers text to every chat; failures for one chat do not stop the
+// others. An empty parseMode sends plain text.
+func (t *Telegram) send(ctx context.Context, text, parseMode string, silent bool) error {
+	url := fmt.Sprintf("%s/bot%s/sendMessage", t.apiBase, t.token)
+	var errs []error
+	for _, chat := range t.chatIDs {
+		payload := map[string]any{
+			"chat_id":              chat,
+			"text":                 text,
+			"disable_notification": silent,
+		}
+		if parseMode != "" {
+			payload["parse_mode"] = parseMode
+		}
+		var resp telegramResponse
+		err := doJSON(ctx, t.client, "telegram", http.MethodPost, url, payload, nil, &resp)
+		var se *StatusError
+		var ue *neturl.Error
+		if errors.As(err, &ue) {
+			// Keep the bot token out of logs.
+			ue.URL = t.apiBase + "/bot<redacted>/sendMessage"
+		}
+		if errors.As(err, &se) {
+			// Error bodies carry the reason and, for 429, retry_after.
+			if json.Unmarshal([]byte(se.Body), &resp) == nil && resp.Parameters.RetryAfter > 0 {
+				se.RetryAfter = time.Duration(resp.Parameters.RetryAfter) * time.Second
+			}
+		} else if err == nil && !resp.OK {
+			err = fmt.Errorf("telegram: %s", resp.Description)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", chat, err))
+		}
+	}
+	return errors.Join(errs...)
+}
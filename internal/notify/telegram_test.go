@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	cases := map[string]string{
+		"alice":               "alice",
+		"first.last":          `first\.last`,
+		"203.0.113.5":         `203\.0\.113\.5`,
+		"2001:db8::1":         "2001:db8::1",
+		"user_name-1":         `user\_name\-1`,
+		"a*b[c](d)~e`f>g#h":   "a\\*b\\[c\\]\\(d\\)\\~e\\`f\\>g\\#h",
+		"+=|{}!":              `\+\=\|\{\}\!`,
+		`back\slash`:          `back\\slash`,
+		"ключ.ünï":            `ключ\.ünï`,
+		"🔐 SSH LOGIN SUCCESS": "🔐 SSH LOGIN SUCCESS",
+	}
+	for in, want := range cases {
+		if got := escapeMarkdownV2(in); got != want {
+			t.Errorf("escapeMarkdownV2(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := escapeMarkdownV2Code("a`b\\c.d_e"); got != "a\\`b\\\\c.d_e" {
+		t.Errorf("escapeMarkdownV2Code = %q", got)
+	}
+}
+
+func TestTelegram_SendEventToChats(t *testing.T) {
+	var got []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/botTOKEN/sendMessage" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var p map[string]any
+		json.NewDecoder(r.Body).Decode(&p)
+		got = append(got, p)
+		if p["chat_id"] == "-100bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer srv.Close()
+
+	sinks, err := Build(&config.Config{Notifiers: []config.Notifier{{
+		Type: "telegram", Name: "tg", URL: srv.URL, Token: "TOKEN",
+		ChatIDs: []string{"-100bad", "42"}, SilentBelow: "medium",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := &model.Event{Type: "login_failure", Username: "evil_user`", SourceIP: "198.51.100.1", Port: 22, Timestamp: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+	err = sinks[0].SendEvent(context.Background(), ev)
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Fatalf("expected error for the bad chat, got %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("the second chat should still be tried, got %d requests", len(got))
+	}
+	p := got[1]
+	if p["chat_id"] != "42" || p["parse_mode"] != "MarkdownV2" || p["disable_notification"] != true {
+		t.Fatalf("unexpected payload: %v", p)
+	}
+	text := p["text"].(string)
+	if !strings.Contains(text, "*User*: `evil_user\\``") || !strings.Contains(text, "*Source*: `198.51.100.1:22`") {
+		t.Fatalf("unexpected text:\n%s", text)
+	}
+
+	got = nil
+	ev.Type, ev.Username = "login_success", "root"
+	sinks[0].SendEvent(context.Background(), ev)
+	if got[1]["disable_notification"] != false {
+		t.Fatal("high severity events should notify with sound")
+	}
+}
@@ -0,0 +1,186 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/tmpl"
+)
+
+func init() {
+	Register("webhook", newWebhook)
+}
+
+// Signature headers set when a webhook secret is configured. The signature
+// is hex(HMAC-SHA256(secret, timestamp + "." + body)).
+const (
+	WebhookSignatureHeader = "X-SSH-Noti-Signature"
+	WebhookTimestampHeader = "X-SSH-Noti-Timestamp"
+)
+
+// defaultWebhookBody renders events, summaries and text as JSON objects.
+const defaultWebhookBody = `{{if eq .Kind "event"}}{"kind":"event","type":{{json .Type}},"user":{{json .Username}},"source_ip":{{json .SourceIP}},"port":{{.Port}},"method":{{json .Method}},"host":{{json .Hostname}},"severity":{{json .Level.String}},"time":{{json (rfc3339 .Timestamp)}}}` +
+	`{{else if eq .Kind "summary"}}{"kind":"summary","host":{{json .Summary.Hostname}},"start":{{json (rfc3339 .Summary.Start)}},"end":{{json (rfc3339 .Summary.End)}},"counts":{{json .Summary.Counts}}}` +
+	`{{else}}{"kind":"text","text":{{json .Text}}}{{end}}`
+
+// WebhookData is the template context. For events the embedded Event holds
+// the event; for summaries and text messages it is the zero value.
+type WebhookData struct {
+	model.Event
+	Kind    string // "event", "summary" or "text"
+	Summary *model.Summary
+	Text    string
+}
+
+// Webhook sends an HTTP request built from templates to any endpoint.
+type Webhook struct {
+	healthTracker
+	name    string
+	method  *template.Template
+	url     *template.Template
+	headers map[string]*template.Template
+	body    *template.Template
+	secret  []byte
+	client  *http.Client
+}
+
+func newWebhook(nc config.Notifier, _ *config.Config) (Notifier, error) {
+	if nc.URL == "" {
+		return nil, errors.New("webhook: url is required")
+	}
+	w := &Webhook{
+		name:    nc.Name,
+		headers: make(map[string]*template.Template),
+		secret:  []byte(nc.Secret),
+		client:  &http.Client{Timeout: nc.Timeout(10 * time.Second)},
+	}
+	method := nc.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	var err error
+	if w.method, err = parseWebhookTemplate("method", method); err != nil {
+		return nil, err
+	}
+	if w.url, err = parseWebhookTemplate("url", nc.URL); err != nil {
+		return nil, err
+	}
+	body := nc.Body
+	if body == "" {
+		body = defaultWebhookBody
+	}
+	if w.body, err = parseWebhookTemplate("body", body); err != nil {
+		return nil, err
+	}
+	for k, v := range nc.Headers {
+		if w.headers[k], err = parseWebhookTemplate("header "+k, v); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func parseWebhookTemplate(name, text string) (*template.Template, error) {
+	t, err := tmpl.Parse(name, text)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: %w", err)
+	}
+	return t, nil
+}
+
+// validWebhookMethod reports whether m is a non-empty HTTP token.
+func validWebhookMethod(m string) bool {
+	if m == "" {
+		return false
+	}
+	for _, c := range m {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *Webhook) Name() string { return w.name }
+
+func (w *Webhook) SendEvent(ctx context.Context, ev *model.Event) error {
+	return w.track(w.send(ctx, &WebhookData{Event: *ev, Kind: "event"}))
+}
+
+func (w *Webhook) SendSummary(ctx context.Context, sum *model.Summary) error {
+	return w.track(w.send(ctx, &WebhookData{Kind: "summary", Summary: sum}))
+}
+
+func (w *Webhook) SendText(ctx context.Context, text string) error {
+	return w.track(w.send(ctx, &WebhookData{Kind: "text", Text: text}))
+}
+
+func (w *Webhook) send(ctx context.Context, data *WebhookData) error {
+	method, err := tmpl.Execute(w.method, data)
+	if err != nil {
+		return err
+	}
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if !validWebhookMethod(method) {
+		return fmt.Errorf("webhook: invalid method %q", method)
+	}
+	url, err := tmpl.Execute(w.url, data)
+	if err != nil {
+		return err
+	}
+	body, err := tmpl.Execute(w.body, data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSpace(url), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ssh-noti")
+	for k, t := range w.headers {
+		v, err := tmpl.Execute(t, data)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(k, v)
+	}
+	if len(w.secret) > 0 {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, ts)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(w.secret, ts, []byte(body)))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{Service: "webhook", Code: resp.StatusCode, Body: string(bytes.TrimSpace(b)), RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	}
+	return nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 that receivers recompute to
+// verify a request: HMAC(secret, timestamp + "." + body).
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
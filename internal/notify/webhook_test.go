@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func TestWebhook_TemplatesAndSignature(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, body = r, nil
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	n, err := newWebhook(config.Notifier{
+		Name:    "hook",
+		URL:     srv.URL + "/alerts/{{.Hostname}}",
+		Method:  "put",
+		Headers: map[string]string{"X-Event": "{{upper .Type}}"},
+		Body:    `{"text":{{json (printf "%s logged in" .Username)}},"at":{{json (rfc3339 .Timestamp)}},"sev":"{{.Level}}"}`,
+		Secret:  "shh",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.SendEvent(context.Background(), testEvent(`"quoted"`)); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != http.MethodPut || got.URL.Path != "/alerts/web1" || got.Header.Get("X-Event") != "LOGIN_SUCCESS" {
+		t.Fatalf("unexpected request %s %s %v", got.Method, got.URL.Path, got.Header)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("body is not JSON: %v\n%s", err, body)
+	}
+	if payload["text"] != `"quoted" logged in` || payload["at"] != "2024-01-01T10:00:00Z" || payload["sev"] != "medium" {
+		t.Fatalf("unexpected payload %v", payload)
+	}
+	sig := got.Header.Get(WebhookSignatureHeader)
+	want := "sha256=" + SignWebhook([]byte("shh"), got.Header.Get(WebhookTimestampHeader), body)
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		t.Fatalf("signature %q, want %q", sig, want)
+	}
+}
+
+func TestWebhook_DefaultBody(t *testing.T) {
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Errorf("invalid JSON: %v", err)
+		}
+		bodies = append(bodies, m)
+		if r.Header.Get(WebhookSignatureHeader) != "" {
+			t.Error("unsigned webhook sent a signature")
+		}
+	}))
+	defer srv.Close()
+
+	n, err := newWebhook(config.Notifier{Name: "hook", URL: srv.URL}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := n.(*Webhook)
+	ctx := context.Background()
+	if err := w.SendEvent(ctx, testEvent("root")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SendSummary(ctx, &model.Summary{Hostname: "web1", Counts: map[string]int{"login_failure": 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SendText(ctx, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if bodies[0]["user"] != "root" || bodies[0]["severity"] != "high" || bodies[0]["port"] != float64(5000) {
+		t.Fatalf("unexpected event body %v", bodies[0])
+	}
+	if bodies[1]["kind"] != "summary" || bodies[1]["counts"].(map[string]any)["login_failure"] != float64(3) {
+		t.Fatalf("unexpected summary body %v", bodies[1])
+	}
+	if bodies[2]["text"] != "hello" {
+		t.Fatalf("unexpected text body %v", bodies[2])
+	}
+}
+
+func TestWebhook_InvalidTemplate(t *testing.T) {
+	if _, err := newWebhook(config.Notifier{Name: "hook", URL: "http://x/{{.Nope"}, nil); err == nil {
+		t.Fatal("expected template parse error")
+	}
+	n, _ := newWebhook(config.Notifier{Name: "hook", URL: "http://127.0.0.1/", Body: "{{.Nope}}"}, nil)
+	if err := n.SendEvent(context.Background(), testEvent("a")); err == nil || n.Health().OK() {
+		t.Fatalf("expected render error, got %v", err)
+	}
+}
+
+func TestWebhook_MethodTemplate(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+	}))
+	defer srv.Close()
+
+	n, err := newWebhook(config.Notifier{
+		Name:   "hook",
+		URL:    srv.URL,
+		Method: `{{if eq .Kind "event"}}put{{else}}post{{end}}`,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.SendEvent(context.Background(), testEvent("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.(*Webhook).SendText(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) != 2 || methods[0] != http.MethodPut || methods[1] != http.MethodPost {
+		t.Fatalf("unexpected methods %v", methods)
+	}
+
+	n, _ = newWebhook(config.Notifier{Name: "hook", URL: srv.URL, Method: `{{if eq .Kind "text"}}GET{{end}}`}, nil)
+	if err := n.SendEvent(context.Background(), testEvent("a")); err == nil || len(methods) != 2 {
+		t.Fatalf("expected invalid method error, got %v", err)
+	}
+	if _, err := newWebhook(config.Notifier{Name: "hook", URL: srv.URL, Method: "{{.Nope"}, nil); err == nil {
+		t.Fatal("expected method parse error")
+	}
+}
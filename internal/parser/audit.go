@@ -0,0 +1,222 @@
+package parser
+
+import (
+	"encoding/hex"
+	osuser "os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+// AuditRecord is one line of /var/log/audit/audit.log.
+type AuditRecord struct {
+	Type   string
+	Time   time.Time
+	Serial uint64
+	Fields map[string]string
+}
+
+var reAuditHeader = regexp.MustCompile(`^type=(\S+) msg=audit\((\d+)\.(\d+):(\d+)\):\s*`)
+
+// ParseAuditLine splits an audit record into its header and key=value
+// fields. The nested msg='...' of userspace records is flattened into Fields.
+func ParseAuditLine(line string) (AuditRecord, bool) {
+	// Enriched log_format appends interpreted fields after a GS separator.
+	if i := strings.IndexByte(line, 0x1d); i >= 0 {
+		line = line[:i]
+	}
+	// node= precedes type= when name_format is set in auditd.conf.
+	var node string
+	if strings.HasPrefix(line, "node=") {
+		if sp := strings.IndexByte(line, ' '); sp > 0 {
+			node, line = line[len("node="):sp], line[sp+1:]
+		}
+	}
+	m := reAuditHeader.FindStringSubmatch(line)
+	if m == nil {
+		return AuditRecord{}, false
+	}
+	sec, _ := strconv.ParseInt(m[2], 10, 64)
+	ms, _ := strconv.ParseInt(m[3], 10, 64)
+	serial, _ := strconv.ParseUint(m[4], 10, 64)
+	rec := AuditRecord{
+		Type:   m[1],
+		Time:   time.Unix(sec, ms*int64(time.Millisecond)),
+		Serial: serial,
+		Fields: make(map[string]string),
+	}
+	parseAuditFields(line[len(m[0]):], rec.Fields)
+	if node != "" {
+		rec.Fields["node"] = node
+	}
+	return rec, true
+}
+
+func parseAuditFields(s string, into map[string]string) {
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ")
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+		var val string
+		quoted := len(s) > 0 && (s[0] == '"' || s[0] == '\'')
+		if quoted {
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				val, s = s[1:], ""
+			} else {
+				val, s = s[1:end+1], s[end+2:]
+			}
+		} else if sp := strings.IndexByte(s, ' '); sp >= 0 {
+			val, s = s[:sp], s[sp:]
+		} else {
+			val, s = s, ""
+		}
+		if key == "msg" && quoted {
+			parseAuditFields(val, into)
+			continue
+		}
+		// Untrusted strings are hex encoded when logged without quotes.
+		if key == "acct" && !quoted {
+			if b, err := hex.DecodeString(val); err == nil {
+				val = string(b)
+			}
+		}
+		into[key] = val
+	}
+}
+
+// AuditAssembler groups audit records by serial and maps completed sshd
+// events onto model events. A group is complete when a record with another
+// serial or an EOE record arrives, or when Flush is called.
+type AuditAssembler struct {
+	Hostname string
+	pending  []AuditRecord
+}
+
+// Add feeds one audit.log line and returns any events completed by it.
+func (a *AuditAssembler) Add(line string) []model.Event {
+	rec, ok := ParseAuditLine(line)
+	if !ok {
+		return nil
+	}
+	var out []model.Event
+	if len(a.pending) > 0 && a.pending[0].Serial != rec.Serial {
+		out = a.Flush()
+	}
+	if rec.Type == "EOE" {
+		return append(out, a.Flush()...)
+	}
+	a.pending = append(a.pending, rec)
+	return out
+}
+
+// Flush completes the pending group. Its records are merged into one event:
+// USER_LOGIN, or else the first record that maps to an event, decides the
+// type and wins conflicting fields; the others fill in missing ones.
+func (a *AuditAssembler) Flush() []model.Event {
+	recs := a.pending
+	a.pending = nil
+	primary := -1
+	for i, r := range recs {
+		if r.Type == "USER_LOGIN" {
+			primary = i
+			break
+		}
+	}
+	for i := 0; primary < 0 && i < len(recs); i++ {
+		if _, ok := a.event(recs[i]); ok {
+			primary = i
+		}
+	}
+	if primary < 0 {
+		return nil
+	}
+	merged := recs[primary]
+	merged.Fields = make(map[string]string)
+	for _, r := range recs {
+		for k, v := range r.Fields {
+			if _, ok := merged.Fields[k]; !ok {
+				merged.Fields[k] = v
+			}
+		}
+	}
+	for k, v := range recs[primary].Fields {
+		merged.Fields[k] = v
+	}
+	if ev, ok := a.event(merged); ok {
+		return []model.Event{ev}
+	}
+	return nil
+}
+
+func (a *AuditAssembler) event(r AuditRecord) (model.Event, bool) {
+	f := r.Fields
+	if !strings.HasSuffix(f["exe"], "/sshd") {
+		return model.Event{}, false
+	}
+	ip := f["addr"]
+	if ip == "" || ip == "?" {
+		ip = f["hostname"]
+	}
+	if ip == "?" {
+		ip = ""
+	}
+	user := f["acct"]
+	// USER_LOGIN success reports the numeric id instead of acct.
+	if user == "" && f["id"] != "" {
+		if u, err := osuser.LookupId(f["id"]); err == nil {
+			user = u.Username
+		}
+	}
+	ev := model.Event{
+		Username:  user,
+		SourceIP:  ip,
+		Timestamp: r.Time,
+		Hostname:  a.Hostname,
+		Fields:    map[string]string{"audit_type": r.Type, "serial": strconv.FormatUint(r.Serial, 10)},
+	}
+	for _, k := range []string{"auid", "ses", "res", "op"} {
+		if v, ok := f[k]; ok {
+			ev.Fields[k] = v
+		}
+	}
+	if n, ok := f["node"]; ok && n != "" {
+		ev.Hostname = n
+	}
+	success := f["res"] == "success" || f["res"] == "1"
+	switch r.Type {
+	case "USER_LOGIN":
+		if success {
+			ev.Type = "login_success"
+		} else if user == "(invalid user)" {
+			ev.Type, ev.Username = "invalid_user", ""
+		} else {
+			ev.Type = "login_failure"
+		}
+	case "USER_AUTH":
+		if success {
+			return model.Event{}, false
+		}
+		ev.Type, ev.Method = "login_failure", "pam"
+		if user == "(invalid user)" || user == "?" {
+			ev.Username = ""
+		}
+	case "USER_START":
+		if !success {
+			return model.Event{}, false
+		}
+		ev.Type = "session_opened"
+	case "USER_END":
+		ev.Type = "session_closed"
+	default:
+		return model.Event{}, false
+	}
+	return ev, true
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAuditLine_Fields(t *testing.T) {
+	line := `node=web1 type=USER_LOGIN msg=audit(1700000000.250:4567): pid=1234 uid=0 auid=1000 ses=5 subj=unconfined msg='op=login acct="alice" exe="/usr/sbin/sshd" hostname=? addr=203.0.113.5 terminal=sshd res=success'` + "\x1dUID=\"root\""
+	rec, ok := ParseAuditLine(line)
+	if !ok {
+		t.Fatal("expected parse ok")
+	}
+	if rec.Type != "USER_LOGIN" || rec.Serial != 4567 || !rec.Time.Equal(time.Unix(1700000000, 250*int64(time.Millisecond))) {
+		t.Fatalf("unexpected header: %+v", rec)
+	}
+	want := map[string]string{"pid": "1234", "auid": "1000", "ses": "5", "acct": "alice", "exe": "/usr/sbin/sshd", "addr": "203.0.113.5", "res": "success", "node": "web1"}
+	for k, v := range want {
+		if rec.Fields[k] != v {
+			t.Fatalf("field %s = %q, want %q (all: %v)", k, rec.Fields[k], v, rec.Fields)
+		}
+	}
+	if _, ok := rec.Fields["UID"]; ok {
+		t.Fatalf("enriched fields should be dropped: %v", rec.Fields)
+	}
+}
+
+func TestAuditAssembler_Events(t *testing.T) {
+	a := &AuditAssembler{Hostname: "test-host"}
+	lines := []string{
+		// Hex-encoded acct and a failed PAM authentication.
+		`type=USER_AUTH msg=audit(1700000000.100:10): pid=1 uid=0 auid=4294967295 ses=4294967295 msg='op=PAM:authentication grantors=? acct=726F6F74 exe="/usr/sbin/sshd" hostname=198.51.100.7 addr=198.51.100.7 terminal=ssh res=failed'`,
+		`type=USER_LOGIN msg=audit(1700000001.100:11): pid=1 uid=0 auid=4294967295 ses=4294967295 msg='op=login acct=28696E76616C6964207573657229 exe="/usr/sbin/sshd" hostname=? addr=198.51.100.8 terminal=sshd res=failed'`,
+		`type=USER_START msg=audit(1700000002.100:12): pid=1 uid=0 auid=1000 ses=7 msg='op=PAM:session_open grantors=pam_unix acct="alice" exe="/usr/sbin/sshd" hostname=203.0.113.5 addr=203.0.113.5 terminal=ssh res=success'`,
+		// Not sshd: ignored.
+		`type=USER_LOGIN msg=audit(1700000003.100:13): pid=1 uid=0 auid=0 ses=1 msg='op=login acct="root" exe="/usr/bin/login" hostname=? addr=? terminal=tty1 res=success'`,
+		`type=USER_LOGIN msg=audit(1700000004.100:14): pid=1 uid=0 auid=1000 ses=7 msg='op=login id=3999999 exe="/usr/sbin/sshd" hostname=? addr=203.0.113.5 terminal=/dev/pts/0 res=success'`,
+		`type=EOE msg=audit(1700000004.100:14): `,
+	}
+	var got []string
+	for _, l := range lines {
+		for _, ev := range a.Add(l) {
+			got = append(got, ev.Type+"|"+ev.Username+"|"+ev.SourceIP+"|"+ev.Fields["ses"])
+		}
+	}
+	for _, ev := range a.Flush() {
+		got = append(got, ev.Type)
+	}
+	want := []string{
+		"login_failure|root|198.51.100.7|4294967295",
+		"invalid_user||198.51.100.8|4294967295",
+		"session_opened|alice|203.0.113.5|7",
+		"login_success||203.0.113.5|7",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("event %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAuditAssembler_MergesSerial(t *testing.T) {
+	a := &AuditAssembler{Hostname: "test-host"}
+	lines := []string{
+		// One failed login logged as USER_AUTH and USER_LOGIN with one serial.
+		`type=USER_AUTH msg=audit(1700000000.100:20): pid=1 uid=0 auid=4294967295 ses=4294967295 msg='op=PAM:authentication grantors=? acct="bob" exe="/usr/sbin/sshd" hostname=198.51.100.7 addr=198.51.100.7 terminal=ssh res=failed'`,
+		`type=USER_LOGIN msg=audit(1700000000.100:20): pid=1 uid=0 auid=4294967295 ses=4294967295 msg='op=login acct="bob" exe="/usr/sbin/sshd" hostname=? addr=198.51.100.7 terminal=sshd res=failed'`,
+		`type=EOE msg=audit(1700000000.100:20): `,
+		// USER_LOGIN without acct takes the user from its companion record.
+		`type=USER_ACCT msg=audit(1700000001.100:21): pid=1 uid=0 auid=4294967295 ses=4294967295 msg='op=PAM:accounting acct="alice" exe="/usr/sbin/sshd" hostname=203.0.113.5 addr=203.0.113.5 terminal=ssh res=success'`,
+		`type=USER_LOGIN msg=audit(1700000001.100:21): pid=1 uid=0 auid=1000 ses=7 msg='op=login id=3999999 exe="/usr/sbin/sshd" hostname=? addr=203.0.113.5 terminal=/dev/pts/0 res=success'`,
+	}
+	var got []string
+	for _, l := range lines {
+		for _, ev := range a.Add(l) {
+			got = append(got, ev.Type+"|"+ev.Username+"|"+ev.Method+"|"+ev.Fields["audit_type"])
+		}
+	}
+	for _, ev := range a.Flush() {
+		got = append(got, ev.Type+"|"+ev.Username+"|"+ev.Method+"|"+ev.Fields["audit_type"])
+	}
+	want := []string{"login_failure|bob||USER_LOGIN", "login_success|alice||USER_LOGIN"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -11,6 +11,10 @@ type RawRecord struct {
 	Line      string
 	Timestamp time.Time
 	Hostname  string
+	PID       int
+	// Event is set by structured sources (utmp) that decode events themselves;
+	// Parse returns it unchanged instead of matching Line.
+	Event *model.Event
 }
 
 // Event is now moved to internal/model
@@ -43,6 +47,9 @@ func NewParser() *Parser {
 }
 
 func (p *Parser) Parse(rr RawRecord) (model.Event, bool) {
+	if rr.Event != nil {
+		return *rr.Event, true
+	}
 	line := strings.TrimSpace(rr.Line)
 	if m := p.reSuccess.FindStringSubmatch(line); m != nil {
 		return model.Event{Type: "login_success", Method: m[1], Username: m[2], SourceIP: m[3], Port: atoi(m[4]), Timestamp: rr.Timestamp, Hostname: rr.Hostname}, true
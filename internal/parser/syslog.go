@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Syslog is a text log line split into its header and message.
+type Syslog struct {
+	Time    time.Time
+	Host    string
+	Program string
+	PID     int
+	Message string
+}
+
+var (
+	reRFC3164 = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d) (\S+) ([^\s\[:]+)(?:\[(\d+)\])?: (.*)$`)
+	reRFC3339 = regexp.MustCompile(`^(\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(?:\.\d+)?(?:Z|[+-]\d\d:\d\d)) (\S+) ([^\s\[:]+)(?:\[(\d+)\])?: (.*)$`)
+)
+
+// SplitSyslog parses traditional ("Oct 19 12:00:01") and high-precision
+// RFC3339 syslog lines. Traditional timestamps carry no year; it is taken
+// from ref, stepping back a year when the result would lie in the future.
+func SplitSyslog(line string, ref time.Time) (Syslog, bool) {
+	if m := reRFC3339.FindStringSubmatch(line); m != nil {
+		ts, err := time.Parse(time.RFC3339Nano, m[1])
+		if err != nil {
+			return Syslog{}, false
+		}
+		return Syslog{Time: ts, Host: m[2], Program: m[3], PID: pid(m[4]), Message: m[5]}, true
+	}
+	if m := reRFC3164.FindStringSubmatch(line); m != nil {
+		ts, err := time.ParseInLocation("Jan _2 15:04:05", m[1], ref.Location())
+		if err != nil {
+			return Syslog{}, false
+		}
+		ts = ts.AddDate(ref.Year(), 0, 0)
+		if ts.After(ref.Add(24 * time.Hour)) {
+			ts = ts.AddDate(-1, 0, 0)
+		}
+		return Syslog{Time: ts, Host: m[2], Program: m[3], PID: pid(m[4]), Message: m[5]}, true
+	}
+	return Syslog{}, false
+}
+
+// IsSSHD reports whether a syslog program name belongs to OpenSSH's server.
+func IsSSHD(program string) bool {
+	return program == "sshd" || program == "sshd-session"
+}
+
+func pid(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitSyslog(t *testing.T) {
+	ref := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		line    string
+		want    time.Time
+		host    string
+		program string
+		pid     int
+		msg     string
+	}{
+		{"Jan  2 03:04:05 web1 sshd[812]: Accepted publickey for alice from 203.0.113.5 port 5000 ssh2",
+			time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "web1", "sshd", 812, "Accepted publickey for alice from 203.0.113.5 port 5000 ssh2"},
+		// December lines read in January belong to the previous year.
+		{"Dec 31 23:59:59 web1 sshd-session[9]: Invalid user x from 203.0.113.6",
+			time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC), "web1", "sshd-session", 9, "Invalid user x from 203.0.113.6"},
+		{"2024-01-01T10:00:00.123456+02:00 db2 sshd[77]: Failed password for root from 198.51.100.1 port 22 ssh2",
+			time.Date(2024, 1, 1, 8, 0, 0, 123456000, time.UTC), "db2", "sshd", 77, "Failed password for root from 198.51.100.1 port 22 ssh2"},
+		{"Jan  2 03:04:05 web1 CRON: pam_unix(cron:session): session opened",
+			time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "web1", "CRON", 0, "pam_unix(cron:session): session opened"},
+	}
+	for _, tc := range cases {
+		sl, ok := SplitSyslog(tc.line, ref)
+		if !ok {
+			t.Fatalf("expected parse ok for %q", tc.line)
+		}
+		if !sl.Time.Equal(tc.want) || sl.Host != tc.host || sl.Program != tc.program || sl.PID != tc.pid || sl.Message != tc.msg {
+			t.Fatalf("unexpected split for %q: %+v", tc.line, sl)
+		}
+	}
+	if _, ok := SplitSyslog("Accepted password for bob from 203.0.113.1 port 1 ssh2", ref); ok {
+		t.Fatal("bare message should not parse as syslog")
+	}
+}
@@ -0,0 +1,281 @@
+// Package queue provides a durable on-disk FIFO and a notifier wrapper that
+// spools undeliverable notifications and retries them with backoff.
+package queue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// segmentSize is the size after which appends start a new segment file.
+const segmentSize = 4 << 20
+
+// Queue is an append-only log of records split into numbered segment files
+// (00000001.seg, ...). Each record is a 4-byte length, a 4-byte CRC-32 and
+// the payload. The position of the first unacknowledged record is kept in
+// the "head" file; fully acknowledged segments are deleted.
+type Queue struct {
+	dir string
+
+	mu    sync.Mutex
+	w     *os.File
+	wseg  int
+	wsize int64
+	head  Position
+	count int
+	acked int
+}
+
+// Position addresses a record by segment number and byte offset.
+type Position struct {
+	Seg int
+	Off int64
+}
+
+// Item is a queued record. next is the position following it and seq its
+// sequence number counted in acknowledged records.
+type Item struct {
+	Data []byte
+	next Position
+	seq  int
+}
+
+// Open opens or creates the queue in dir, dropping a partially written
+// record left at the end of the last segment by a crash.
+func Open(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	q := &Queue{dir: dir}
+	segs, err := q.segments()
+	if err != nil {
+		return nil, err
+	}
+	if b, err := os.ReadFile(q.headPath()); err == nil {
+		if _, err := fmt.Sscanf(string(b), "%d %d", &q.head.Seg, &q.head.Off); err != nil {
+			return nil, fmt.Errorf("queue: corrupt head file: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(segs) > 0 && (q.head.Seg < segs[0]) {
+		q.head = Position{Seg: segs[0]}
+	}
+	q.wseg = 1
+	if len(segs) > 0 {
+		q.wseg = segs[len(segs)-1]
+	}
+	for _, s := range segs {
+		if s < q.head.Seg {
+			continue
+		}
+		off := int64(0)
+		if s == q.head.Seg {
+			off = q.head.Off
+		}
+		n, end, err := q.scan(s, off)
+		if err != nil {
+			return nil, err
+		}
+		q.count += n
+		if s == q.wseg {
+			// Truncate a torn tail so new appends follow the last good record.
+			if err := os.Truncate(q.segPath(s), end); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if q.head.Seg == 0 {
+		q.head.Seg = q.wseg
+	}
+	if q.w, err = os.OpenFile(q.segPath(q.wseg), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600); err != nil {
+		return nil, err
+	}
+	st, err := q.w.Stat()
+	if err != nil {
+		q.w.Close()
+		return nil, err
+	}
+	q.wsize = st.Size()
+	return q, nil
+}
+
+func (q *Queue) segPath(n int) string { return filepath.Join(q.dir, fmt.Sprintf("%08d.seg", n)) }
+func (q *Queue) headPath() string     { return filepath.Join(q.dir, "head") }
+
+func (q *Queue) segments() ([]int, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []int
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".seg") {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(name, ".seg")); err == nil {
+			out = append(out, n)
+		}
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+// scan counts the valid records of segment s from off and returns the
+// offset after the last one.
+func (q *Queue) scan(s int, off int64) (int, int64, error) {
+	f, err := os.Open(q.segPath(s))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	r := bufio.NewReader(f)
+	n := 0
+	for {
+		data, err := readRecord(r)
+		if err != nil {
+			return n, off, nil
+		}
+		n++
+		off += int64(8 + len(data))
+	}
+}
+
+var errCorrupt = errors.New("queue: corrupt record")
+
+func readRecord(r io.Reader) ([]byte, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(hdr[:4])
+	if size > segmentSize {
+		return nil, errCorrupt
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(hdr[4:]) {
+		return nil, errCorrupt
+	}
+	return data, nil
+}
+
+// Append durably adds a record to the end of the queue.
+func (q *Queue) Append(data []byte) error {
+	if len(data) > segmentSize {
+		return errors.New("queue: record too large")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.wsize > 0 && q.wsize+int64(8+len(data)) > segmentSize {
+		f, err := os.OpenFile(q.segPath(q.wseg+1), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return err
+		}
+		q.w.Close()
+		q.w, q.wseg, q.wsize = f, q.wseg+1, 0
+	}
+	buf := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[8:], data)
+	n, err := q.w.Write(buf)
+	q.wsize += int64(n)
+	if err != nil {
+		return err
+	}
+	if err := q.w.Sync(); err != nil {
+		return err
+	}
+	q.count++
+	return nil
+}
+
+// Len is the number of unacknowledged records.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count
+}
+
+// Peek returns up to max records from the head without removing them.
+func (q *Queue) Peek(max int) ([]Item, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []Item
+	pos := q.head
+	for len(out) < max && len(out) < q.count {
+		f, err := os.Open(q.segPath(pos.Seg))
+		if err != nil {
+			return out, err
+		}
+		if _, err := f.Seek(pos.Off, io.SeekStart); err != nil {
+			f.Close()
+			return out, err
+		}
+		r := bufio.NewReader(f)
+		for len(out) < max && len(out) < q.count {
+			data, err := readRecord(r)
+			if err != nil {
+				break
+			}
+			pos.Off += int64(8 + len(data))
+			out = append(out, Item{Data: data, next: pos, seq: q.acked + len(out) + 1})
+		}
+		f.Close()
+		if len(out) < max && len(out) < q.count {
+			if pos.Seg >= q.wseg {
+				return out, errCorrupt
+			}
+			pos = Position{Seg: pos.Seg + 1}
+		}
+	}
+	return out, nil
+}
+
+// Ack removes every record up to and including it. Items from one Peek
+// must be acknowledged in order.
+func (q *Queue) Ack(it Item) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if it.seq <= q.acked {
+		return nil
+	}
+	tmp := q.headPath() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", it.next.Seg, it.next.Off)), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, q.headPath()); err != nil {
+		return err
+	}
+	for s := q.head.Seg; s < it.next.Seg; s++ {
+		os.Remove(q.segPath(s))
+	}
+	q.head = it.next
+	q.count -= it.seq - q.acked
+	q.acked = it.seq
+	return nil
+}
+
+// Close closes the segment being written.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.w.Close()
+}
@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQueue_AppendAckReopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := q.Append([]byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items, _ := q.Peek(3)
+	if len(items) != 3 || string(items[2].Data) != "2" {
+		t.Fatalf("unexpected peek %v", items)
+	}
+	q.Ack(items[0])
+	q.Ack(items[1])
+	if q.Len() != 3 {
+		t.Fatalf("len = %d, want 3", q.Len())
+	}
+	q.Close()
+
+	q, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	items, _ = q.Peek(10)
+	if q.Len() != 3 || len(items) != 3 || string(items[0].Data) != "2" {
+		t.Fatalf("after reopen len=%d items=%d first=%q", q.Len(), len(items), items[0].Data)
+	}
+}
+
+func TestQueue_TornTail(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := Open(dir)
+	q.Append([]byte("ok"))
+	q.Close()
+	// Simulate a crash in the middle of writing the second record.
+	f, _ := os.OpenFile(filepath.Join(dir, "00000001.seg"), os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{0, 0, 0, 9, 1, 2})
+	f.Close()
+
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	q.Append([]byte("next"))
+	items, err := q.Peek(10)
+	if err != nil || len(items) != 2 || string(items[1].Data) != "next" {
+		t.Fatalf("torn record not dropped: %v %v", items, err)
+	}
+}
+
+func TestQueue_Segments(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := Open(dir)
+	defer q.Close()
+	big := bytes.Repeat([]byte("x"), segmentSize/2)
+	for i := 0; i < 4; i++ {
+		if err := q.Append(append([]byte{byte('0' + i)}, big...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segs, _ := q.segments()
+	if len(segs) != 4 {
+		t.Fatalf("expected 4 segments, got %v", segs)
+	}
+	items, _ := q.Peek(4)
+	if len(items) != 4 || items[3].Data[0] != '3' {
+		t.Fatalf("peek across segments returned %d items", len(items))
+	}
+	q.Ack(items[2])
+	segs, _ = q.segments()
+	if len(segs) != 2 || segs[0] != 3 || q.Len() != 1 {
+		t.Fatalf("acked segments not removed: %v len=%d", segs, q.Len())
+	}
+}
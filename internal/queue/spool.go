@@ -0,0 +1,356 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/logging"
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/notify"
+)
+
+// Options tune a Spool. Zero values select the defaults.
+type Options struct {
+	// MaxAge is how long a notification may wait; older ones are replaced
+	// by a single "N alerts were delayed" message. Default 6h.
+	MaxAge time.Duration
+	// MinBackoff and MaxBackoff bound the exponential retry delay.
+	// Defaults 5s and 5m.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// entry is the spooled form of one notification.
+type entry struct {
+	Kind   string        `json:"kind"`
+	Queued time.Time     `json:"queued"`
+	Event  *model.Event  `json:"event,omitempty"`
+	Events []model.Event `json:"events,omitempty"`
+	// Silenced goes with the events of a batch.
+	Silenced []model.Count  `json:"silenced,omitempty"`
+	Summary  *model.Summary `json:"summary,omitempty"`
+	Text     string         `json:"text,omitempty"`
+}
+
+// Spool wraps a notifier so that failed deliveries are written to a Queue
+// and retried in order in the background. While anything is spooled, new
+// notifications are queued behind it to keep delivery ordered.
+type Spool struct {
+	notify.Notifier
+	q    *Queue
+	opts Options
+
+	mu       sync.Mutex
+	draining bool
+	wake     chan time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewSpool opens the queue in dir and starts retrying anything left in it
+// from a previous run.
+func NewSpool(n notify.Notifier, dir string, opts Options) (*Spool, error) {
+	q, err := Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 6 * time.Hour
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 5 * time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(5*time.Minute, opts.MinBackoff)
+	}
+	s := &Spool{Notifier: n, q: q, opts: opts, wake: make(chan time.Duration, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	if q.Len() > 0 {
+		logging.L().Info("replaying spooled notifications", "notifier", n.Name(), "count", q.Len())
+	}
+	// Batches the notifier fails to send in the background are spooled.
+	if b, ok := n.(notify.BatchSender); ok {
+		b.OnBatchError(func(batch *notify.Batch, err error) {
+			if permanent(err) {
+				logging.L().Error("dropping undeliverable batch", "notifier", n.Name(), "events", len(batch.Events), "error", err)
+				return
+			}
+			if err := s.retryLater(&entry{Kind: "batch", Queued: time.Now(), Events: batch.Events, Silenced: batch.Silenced}, err); err != nil {
+				logging.L().Error("failed to spool batch", "notifier", n.Name(), "events", len(batch.Events), "error", err)
+			}
+		})
+	}
+	go s.run(q.Len() > 0)
+	return s, nil
+}
+
+func (s *Spool) SendEvent(ctx context.Context, ev *model.Event) error {
+	return s.send(ctx, &entry{Kind: "event", Event: ev})
+}
+
+func (s *Spool) SendSummary(ctx context.Context, sum *model.Summary) error {
+	return s.send(ctx, &entry{Kind: "summary", Summary: sum})
+}
+
+func (s *Spool) SendText(ctx context.Context, text string) error {
+	if _, ok := s.Notifier.(notify.TextSender); !ok {
+		return nil
+	}
+	return s.send(ctx, &entry{Kind: "text", Text: text})
+}
+
+func (s *Spool) CountsFailures() bool { return notify.CountsFailures(s.Notifier) }
+
+func (s *Spool) CountSilenced(ev *model.Event, label string) {
+	if sc, ok := s.Notifier.(notify.SilenceCounter); ok {
+		sc.CountSilenced(ev, label)
+	}
+}
+
+// Flush makes one attempt to deliver the spool and flushes the wrapped
+// notifier. Whatever is left stays on disk for the next start.
+func (s *Spool) Flush(ctx context.Context) error {
+	var errs []error
+	if s.q.Len() > 0 && s.begin() {
+		errs = append(errs, s.drain(ctx))
+	}
+	if fl, ok := s.Notifier.(notify.Flusher); ok {
+		errs = append(errs, fl.Flush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Health reports the wrapped notifier's health and the spool length.
+func (s *Spool) Health() notify.Health {
+	h := s.Notifier.Health()
+	h.Queued = s.q.Len()
+	return h
+}
+
+// Close stops retrying and closes the queue.
+func (s *Spool) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.q.Close()
+}
+
+// send delivers e directly when nothing is spooled. A retryable failure
+// spools e and is not returned: the notification is not lost.
+func (s *Spool) send(ctx context.Context, e *entry) error {
+	e.Queued = time.Now()
+	s.mu.Lock()
+	if s.draining || s.q.Len() > 0 {
+		defer s.mu.Unlock()
+		return s.append(e)
+	}
+	s.mu.Unlock()
+	err := s.deliver(ctx, e)
+	if err == nil || permanent(err) {
+		return err
+	}
+	return s.retryLater(e, err)
+}
+
+// retryLater spools e after its delivery failed with err and wakes the
+// retry loop.
+func (s *Spool) retryLater(e *entry, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if aerr := s.append(e); aerr != nil {
+		return errors.Join(err, aerr)
+	}
+	logging.L().Warn("delivery failed, spooled for retry", "notifier", s.Name(), "error", err)
+	select {
+	case s.wake <- retryDelay(err, s.opts.MinBackoff):
+	default:
+	}
+	return nil
+}
+
+func (s *Spool) append(e *entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.q.Append(b)
+}
+
+func (s *Spool) deliver(ctx context.Context, e *entry) error {
+	switch e.Kind {
+	case "event":
+		return s.Notifier.SendEvent(ctx, e.Event)
+	case "summary":
+		return s.Notifier.SendSummary(ctx, e.Summary)
+	case "batch":
+		if b, ok := s.Notifier.(notify.BatchSender); ok {
+			return b.SendBatch(ctx, &notify.Batch{Events: e.Events, Silenced: e.Silenced})
+		}
+	case "text":
+		if ts, ok := s.Notifier.(notify.TextSender); ok {
+			return ts.SendText(ctx, e.Text)
+		}
+	}
+	return nil
+}
+
+// permanent reports client errors that retrying will not fix.
+func permanent(err error) bool {
+	var se *notify.StatusError
+	return errors.As(err, &se) && se.Code >= 400 && se.Code < 500 &&
+		se.Code != http.StatusRequestTimeout && se.Code != http.StatusTooManyRequests
+}
+
+// retryDelay honours a server-requested Retry-After longer than d.
+func retryDelay(err error, d time.Duration) time.Duration {
+	var se *notify.StatusError
+	if errors.As(err, &se) && se.RetryAfter > d {
+		return se.RetryAfter
+	}
+	return d
+}
+
+// run retries the spool with exponential backoff until it is empty, then
+// waits for the next failed delivery. With replay set, entries left by a
+// previous run are retried right away.
+func (s *Spool) run(replay bool) {
+	defer close(s.done)
+	var delay time.Duration
+	retry := replay
+	for {
+		if !retry {
+			select {
+			case <-s.stop:
+				return
+			case delay = <-s.wake:
+			}
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-s.stop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		if !s.begin() {
+			delay = max(delay, s.opts.MinBackoff)
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-s.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		err := s.drain(ctx)
+		cancel()
+		if retry = err != nil; !retry {
+			continue
+		}
+		delay = min(max(delay*2, s.opts.MinBackoff), s.opts.MaxBackoff)
+		delay = retryDelay(err, delay)
+		logging.L().Warn("spooled delivery failed", "notifier", s.Name(), "queued", s.q.Len(), "retry_in", delay, "error", err)
+	}
+}
+
+// begin marks the spool as draining; it reports false if a drain is
+// already in progress.
+func (s *Spool) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return false
+	}
+	s.draining = true
+	return true
+}
+
+// drain delivers spooled notifications in order until the queue is empty or
+// a delivery fails, then clears the draining mark set by begin.
+func (s *Spool) drain(ctx context.Context) error {
+	for {
+		err := s.drainOnce(ctx)
+		s.mu.Lock()
+		if err != nil || s.q.Len() == 0 {
+			s.draining = false
+			s.mu.Unlock()
+			return err
+		}
+		s.mu.Unlock()
+	}
+}
+
+// drainOnce delivers what is currently queued. Runs of expired entries are
+// collapsed into one message.
+func (s *Spool) drainOnce(ctx context.Context) error {
+	for {
+		items, err := s.q.Peek(64)
+		if err != nil || len(items) == 0 {
+			return err
+		}
+		var expired []Item
+		var oldest, newest time.Time
+		for _, it := range items {
+			var e entry
+			if err := json.Unmarshal(it.Data, &e); err != nil {
+				if len(expired) > 0 {
+					break
+				}
+				logging.L().Error("dropping unreadable spool entry", "notifier", s.Name(), "error", err)
+				if err := s.q.Ack(it); err != nil {
+					return err
+				}
+				continue
+			}
+			if time.Since(e.Queued) > s.opts.MaxAge {
+				if len(expired) == 0 {
+					oldest = e.Queued
+				}
+				expired, newest = append(expired, it), e.Queued
+				continue
+			}
+			if len(expired) > 0 {
+				break
+			}
+			err := s.deliver(ctx, &e)
+			if err != nil && !permanent(err) {
+				return err
+			}
+			if err != nil {
+				logging.L().Error("dropping undeliverable notification", "notifier", s.Name(), "error", err)
+			}
+			if err := s.q.Ack(it); err != nil {
+				return err
+			}
+		}
+		if len(expired) > 0 {
+			if err := s.delayed(ctx, len(expired), oldest, newest); err != nil {
+				return err
+			}
+			if err := s.q.Ack(expired[len(expired)-1]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// delayed reports notifications that expired in the spool.
+func (s *Spool) delayed(ctx context.Context, n int, oldest, newest time.Time) error {
+	text := fmt.Sprintf("⚠️ %d alerts were delayed for more than %s and not delivered (queued %s to %s)",
+		n, s.opts.MaxAge, oldest.Format(time.RFC3339), newest.Format(time.RFC3339))
+	logging.L().Warn("expired spooled notifications", "notifier", s.Name(), "count", n)
+	ts, ok := s.Notifier.(notify.TextSender)
+	if !ok {
+		return nil
+	}
+	err := ts.SendText(ctx, text)
+	if permanent(err) {
+		return nil
+	}
+	return err
+}
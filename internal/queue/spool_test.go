@@ -0,0 +1,195 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/notify"
+)
+
+// flaky fails deliveries while down is set and records what it delivered.
+type flaky struct {
+	mu        sync.Mutex
+	down      error
+	users     []string
+	texts     []string
+	attempts  int
+	attemptAt []time.Time
+}
+
+func (f *flaky) Name() string          { return "flaky" }
+func (f *flaky) Health() notify.Health { return notify.Health{} }
+
+func (f *flaky) SendEvent(_ context.Context, ev *model.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	f.attemptAt = append(f.attemptAt, time.Now())
+	if f.down != nil {
+		return f.down
+	}
+	f.users = append(f.users, ev.Username)
+	return nil
+}
+
+func (f *flaky) SendSummary(context.Context, *model.Summary) error { return nil }
+
+func (f *flaky) SendText(_ context.Context, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down != nil {
+		return f.down
+	}
+	f.texts = append(f.texts, text)
+	return nil
+}
+
+func (f *flaky) set(err error) {
+	f.mu.Lock()
+	f.down = err
+	f.mu.Unlock()
+}
+
+func (f *flaky) delivered() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.users...)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSpool_RetriesInOrder(t *testing.T) {
+	f := &flaky{down: errors.New("connection refused")}
+	s, err := NewSpool(f, t.TempDir(), Options{MinBackoff: 20 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	for _, u := range []string{"a", "b", "c"} {
+		if err := s.SendEvent(ctx, &model.Event{Username: u}); err != nil {
+			t.Fatalf("spooled send should not fail: %v", err)
+		}
+	}
+	if s.Health().Queued != 3 {
+		t.Fatalf("queued = %d", s.Health().Queued)
+	}
+	time.Sleep(100 * time.Millisecond)
+	f.set(nil)
+	waitFor(t, func() bool { return len(f.delivered()) == 3 })
+	if got := strings.Join(f.delivered(), ""); got != "abc" {
+		t.Fatalf("delivered out of order: %s", got)
+	}
+	waitFor(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return !s.draining
+	})
+	s.SendEvent(ctx, &model.Event{Username: "d"})
+	if len(f.delivered()) != 4 || s.Health().Queued != 0 {
+		t.Fatal("healthy sink should be sent to directly")
+	}
+}
+
+func TestSpool_RetryAfterAndPermanent(t *testing.T) {
+	f := &flaky{down: &notify.StatusError{Service: "x", Code: 429, RetryAfter: 300 * time.Millisecond}}
+	s, _ := NewSpool(f, t.TempDir(), Options{MinBackoff: 10 * time.Millisecond})
+	defer s.Close()
+	s.SendEvent(context.Background(), &model.Event{Username: "a"})
+	waitFor(t, func() bool { f.mu.Lock(); defer f.mu.Unlock(); return f.attempts >= 2 })
+	f.mu.Lock()
+	gap := f.attemptAt[1].Sub(f.attemptAt[0])
+	f.mu.Unlock()
+	if gap < 300*time.Millisecond {
+		t.Fatalf("retried after %s despite Retry-After", gap)
+	}
+
+	f2 := &flaky{down: &notify.StatusError{Service: "x", Code: 400}}
+	s2, _ := NewSpool(f2, t.TempDir(), Options{})
+	defer s2.Close()
+	if err := s2.SendEvent(context.Background(), &model.Event{Username: "a"}); err == nil || s2.Health().Queued != 0 {
+		t.Fatal("a 400 should be returned, not spooled")
+	}
+}
+
+func TestSpool_ReplayAndExpiry(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := Open(dir)
+	for i, u := range []string{"old1", "old2", "new"} {
+		queued := time.Now()
+		if i < 2 {
+			queued = queued.Add(-2 * time.Hour)
+		}
+		b, _ := json.Marshal(entry{Kind: "event", Queued: queued, Event: &model.Event{Username: u}})
+		q.Append(b)
+	}
+	q.Close()
+
+	f := &flaky{}
+	s, err := NewSpool(f, dir, Options{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	waitFor(t, func() bool { return len(f.delivered()) == 1 })
+	if f.delivered()[0] != "new" || len(f.texts) != 1 || !strings.Contains(f.texts[0], "2 alerts were delayed") {
+		t.Fatalf("delivered=%v texts=%v", f.delivered(), f.texts)
+	}
+}
+
+// batcher is a flaky notifier that sends batches in the background.
+type batcher struct {
+	flaky
+	onErr func(*notify.Batch, error)
+}
+
+func (b *batcher) SendBatch(_ context.Context, batch *notify.Batch) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.down != nil {
+		return b.down
+	}
+	for _, ev := range batch.Events {
+		b.users = append(b.users, ev.Username)
+	}
+	return nil
+}
+
+func (b *batcher) OnBatchError(h func(*notify.Batch, error)) { b.onErr = h }
+
+func TestSpool_RetriesFailedBatches(t *testing.T) {
+	b := &batcher{}
+	s, err := NewSpool(b, t.TempDir(), Options{MinBackoff: 20 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if b.onErr == nil {
+		t.Fatal("spool should take failed batches")
+	}
+	b.set(errors.New("connection refused"))
+	b.onErr(&notify.Batch{Events: []model.Event{{Username: "a"}, {Username: "b"}}}, errors.New("connection refused"))
+	if s.Health().Queued != 1 {
+		t.Fatalf("batch not spooled: %+v", s.Health())
+	}
+	b.set(nil)
+	waitFor(t, func() bool { return len(b.delivered()) == 2 })
+	if got := b.delivered(); got[0] != "a" || got[1] != "b" {
+		t.Fatalf("got %v", got)
+	}
+}
@@ -0,0 +1,149 @@
+// Package route decides which notifiers receive an event.
+package route
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"slices"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+// Table is a compiled routing table.
+type Table struct {
+	routes []route
+}
+
+type route struct {
+	name      string
+	types     []string
+	users     []string
+	hosts     []string
+	prefixes  []netip.Prefix
+	min       model.Severity
+	tags      []string
+	notifiers []string
+	cont      bool
+}
+
+// New compiles routes. It returns nil when there are none, in which case
+// every event goes to every notifier.
+func New(routes []config.Route) (*Table, error) {
+	if len(routes) == 0 {
+		return nil, nil
+	}
+	t := &Table{}
+	for i, r := range routes {
+		c := route{
+			name:      r.Name,
+			types:     r.Match.Types,
+			users:     r.Match.Users,
+			hosts:     r.Match.Hosts,
+			tags:      r.Match.Tags,
+			notifiers: r.Notifiers,
+			cont:      r.Continue,
+		}
+		if c.name == "" {
+			c.name = fmt.Sprintf("#%d", i)
+		}
+		for _, s := range r.Match.CIDRs {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				a, aerr := netip.ParseAddr(s)
+				if aerr != nil {
+					return nil, fmt.Errorf("route %s: %w", c.name, err)
+				}
+				p = netip.PrefixFrom(a, a.BitLen())
+			}
+			c.prefixes = append(c.prefixes, p.Masked())
+		}
+		if r.Match.MinSeverity != "" {
+			sev, err := model.ParseSeverity(r.Match.MinSeverity)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: %w", c.name, err)
+			}
+			c.min = sev
+		}
+		t.routes = append(t.routes, c)
+	}
+	return t, nil
+}
+
+// Decision is the outcome of routing one event.
+type Decision struct {
+	// Routes are the names of the matching routes, in order.
+	Routes []string
+	// Notifiers are the destinations, without duplicates.
+	Notifiers []string
+	// Default is set when no route matched; the event then goes to every
+	// notifier.
+	Default bool
+}
+
+// Route matches ev against the table. A nil Table routes everything to the
+// default.
+func (t *Table) Route(ev *model.Event) Decision {
+	var d Decision
+	if t == nil {
+		d.Default = true
+		return d
+	}
+	for _, r := range t.routes {
+		if !r.matches(ev) {
+			continue
+		}
+		d.Routes = append(d.Routes, r.name)
+		for _, n := range r.notifiers {
+			if !slices.Contains(d.Notifiers, n) {
+				d.Notifiers = append(d.Notifiers, n)
+			}
+		}
+		if !r.cont {
+			break
+		}
+	}
+	d.Default = len(d.Routes) == 0
+	return d
+}
+
+func (r *route) matches(ev *model.Event) bool {
+	if len(r.types) > 0 && !slices.Contains(r.types, ev.Type) {
+		return false
+	}
+	if len(r.users) > 0 && !glob(r.users, ev.Username) {
+		return false
+	}
+	if len(r.hosts) > 0 && !glob(r.hosts, ev.Hostname) {
+		return false
+	}
+	if len(r.prefixes) > 0 {
+		addr, err := netip.ParseAddr(ev.SourceIP)
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		if !slices.ContainsFunc(r.prefixes, func(p netip.Prefix) bool { return p.Contains(addr) }) {
+			return false
+		}
+	}
+	if r.min != model.SeverityUnset && ev.Level() < r.min {
+		return false
+	}
+	for _, tag := range r.tags {
+		if !slices.Contains(ev.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func glob(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
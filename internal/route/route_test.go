@@ -0,0 +1,53 @@
+package route
+
+import (
+	"slices"
+	"testing"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func TestTable_FirstMatchAndContinue(t *testing.T) {
+	table, err := New([]config.Route{
+		{Name: "root", Match: config.RouteMatch{Types: []string{"login_success"}, Users: []string{"root"}}, Notifiers: []string{"sec", "pagerduty"}, Continue: true},
+		{Name: "internal", Match: config.RouteMatch{CIDRs: []string{"10.0.0.0/8", "192.0.2.1"}}, Notifiers: []string{"jsonl"}},
+		{Name: "noise", Match: config.RouteMatch{Types: []string{"invalid_user"}}},
+		{Name: "tagged", Match: config.RouteMatch{Tags: []string{"vpn", "admin"}, Hosts: []string{"bastion-*"}}, Notifiers: []string{"sec"}},
+		{Name: "urgent", Match: config.RouteMatch{MinSeverity: "high"}, Notifiers: []string{"sec", "slack"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name      string
+		ev        model.Event
+		routes    []string
+		notifiers []string
+	}{
+		{"root continues", model.Event{Type: "login_success", Username: "root", SourceIP: "203.0.113.5"}, []string{"root", "urgent"}, []string{"sec", "pagerduty", "slack"}},
+		{"first match stops", model.Event{Type: "login_success", Username: "alice", SourceIP: "::ffff:10.1.2.3", Severity: model.SeverityCritical}, []string{"internal"}, []string{"jsonl"}},
+		{"single address", model.Event{Type: "login_failure", SourceIP: "192.0.2.1"}, []string{"internal"}, []string{"jsonl"}},
+		{"drop", model.Event{Type: "invalid_user", SourceIP: "198.51.100.1"}, []string{"noise"}, nil},
+		{"all tags and host", model.Event{Type: "login_success", Username: "bob", Hostname: "bastion-2", Tags: []string{"admin", "vpn"}}, []string{"tagged"}, []string{"sec"}},
+		{"missing tag", model.Event{Type: "login_failure", Hostname: "bastion-2", Tags: []string{"vpn"}}, nil, nil},
+	} {
+		d := table.Route(&tc.ev)
+		if !slices.Equal(d.Routes, tc.routes) || !slices.Equal(d.Notifiers, tc.notifiers) || d.Default != (tc.routes == nil) {
+			t.Fatalf("%s: got %+v, want routes %v notifiers %v", tc.name, d, tc.routes, tc.notifiers)
+		}
+	}
+}
+
+func TestTable_NilAndInvalid(t *testing.T) {
+	table, err := New(nil)
+	if err != nil || table != nil {
+		t.Fatalf("no routes should compile to nil: %v %v", table, err)
+	}
+	if d := table.Route(&model.Event{Type: "login_success"}); !d.Default {
+		t.Fatalf("nil table should route to the default: %+v", d)
+	}
+	if _, err := New([]config.Route{{Match: config.RouteMatch{CIDRs: []string{"10.0.0.0/33"}}}}); err == nil {
+		t.Fatal("expected an invalid CIDR error")
+	}
+}
@@ -10,6 +10,11 @@ import (
 )
 
 type Deduper struct {
+	// EventTime measures the window on event timestamps, for replays. Live
+	// runs use the wall clock so that sources with skewed or misparsed
+	// timestamps cannot keep keys alive or expire them early.
+	EventTime bool
+
 	ttl  time.Duration
 	mu   sync.Mutex
 	seen map[string]time.Time // key -> expiresAt
@@ -26,6 +31,9 @@ func NewDeduper(windowSeconds int) *Deduper {
 func (d *Deduper) ShouldSend(ev *model.Event) bool {
 	k := eventKey(ev)
 	now := time.Now()
+	if d.EventTime && !ev.Timestamp.IsZero() {
+		now = ev.Timestamp
+	}
 	exp := now.Add(d.ttl)
 	d.mu.Lock()
 	defer d.mu.Unlock()
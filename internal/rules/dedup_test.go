@@ -0,0 +1,26 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+func TestDeduper_Clock(t *testing.T) {
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ev := func(ts time.Time) *model.Event {
+		return &model.Event{Type: "login_failure", Username: "bob", SourceIP: "198.51.100.1", Timestamp: ts}
+	}
+	// Live: logged timestamps, however far apart, do not open the window.
+	d := NewDeduper(30)
+	if !d.ShouldSend(ev(old)) || d.ShouldSend(ev(old.Add(time.Hour))) {
+		t.Fatal("live dedup should use the wall clock")
+	}
+	// Replay: event time decides.
+	d = NewDeduper(30)
+	d.EventTime = true
+	if !d.ShouldSend(ev(old)) || d.ShouldSend(ev(old.Add(10*time.Second))) || !d.ShouldSend(ev(old.Add(time.Minute))) {
+		t.Fatal("replay dedup should use event timestamps")
+	}
+}
@@ -0,0 +1,330 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+// Rule is one custom detection from the rules file. When selects events;
+// with Aggregate the rule fires only once enough selected events arrive
+// within a window, e.g. "count by ip over 5m > 20" or
+// "distinct user by ip over 10m >= 5".
+type Rule struct {
+	Name      string `json:"name"`
+	When      string `json:"when"`
+	Aggregate string `json:"aggregate"`
+	// Severity raises the severity of matching events to at least this.
+	Severity string   `json:"severity"`
+	Tags     []string `json:"tags"`
+	// Actions are "alert" (send a message naming the rule) and "drop" (do
+	// not notify the event). Matching events are always tagged
+	// "rule:<name>" plus Tags.
+	Actions []string `json:"actions"`
+}
+
+// Detection is a rule firing on an event.
+type Detection struct {
+	Rule     string
+	Severity model.Severity
+	Alert    bool
+	Drop     bool
+	// Aggregated detections carry the group, its count and the window.
+	Group  string
+	Count  int
+	Window time.Duration
+}
+
+// Text describes the detection for an alert message.
+func (d *Detection) Text(ev *model.Event) string {
+	sev := ""
+	if d.Severity != model.SeverityUnset {
+		sev = " (" + d.Severity.String() + ")"
+	}
+	if d.Window > 0 {
+		group := ""
+		if d.Group != "" {
+			group = " for " + d.Group
+		}
+		return fmt.Sprintf("🚨 Rule %s%s: %d matching events%s within %s on %s", d.Rule, sev, d.Count, group, d.Window, ev.Hostname)
+	}
+	return fmt.Sprintf("🚨 Rule %s%s: %s by %s from %s on %s", d.Rule, sev, ev.Type, ev.Username, ev.SourceIP, ev.Hostname)
+}
+
+// Detector evaluates compiled rules against events.
+type Detector struct {
+	rules []*detection
+}
+
+type detection struct {
+	Rule
+	when  *Expr
+	agg   *aggregate
+	min   model.Severity // parsed Severity
+	alert bool
+	drop  bool
+}
+
+// LoadRules reads a JSON array of rules from path and compiles them.
+func LoadRules(path string) (*Detector, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []Rule
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	d, err := NewDetector(rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// NewDetector compiles rules, reporting every invalid rule.
+func NewDetector(rules []Rule) (*Detector, error) {
+	d := &Detector{}
+	var errs []error
+	names := make(map[string]bool)
+	for i, r := range rules {
+		c, err := compileRule(r)
+		if err == nil && names[r.Name] {
+			err = errors.New("duplicate name")
+		}
+		if err != nil {
+			name := r.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i)
+			}
+			errs = append(errs, fmt.Errorf("rule %s: %w", name, err))
+			continue
+		}
+		names[r.Name] = true
+		d.rules = append(d.rules, c)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func compileRule(r Rule) (*detection, error) {
+	if r.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if r.When == "" {
+		return nil, errors.New("when is required")
+	}
+	c := &detection{Rule: r}
+	var err error
+	if c.when, err = Compile(r.When); err != nil {
+		return nil, fmt.Errorf("when: %w", err)
+	}
+	if r.Aggregate != "" {
+		if c.agg, err = parseAggregate(r.Aggregate); err != nil {
+			return nil, fmt.Errorf("aggregate: %w", err)
+		}
+	}
+	if r.Severity != "" {
+		if c.min, err = model.ParseSeverity(r.Severity); err != nil {
+			return nil, fmt.Errorf("severity: %w", err)
+		}
+	}
+	for _, a := range r.Actions {
+		switch a {
+		case "alert":
+			c.alert = true
+		case "drop":
+			c.drop = true
+		default:
+			return nil, fmt.Errorf("unknown action %q", a)
+		}
+	}
+	return c, nil
+}
+
+// Apply runs every rule on ev. Matching rules tag ev and raise its
+// severity; the detections are returned in rule order. A nil Detector
+// matches nothing.
+func (d *Detector) Apply(ev *model.Event) []Detection {
+	if d == nil {
+		return nil
+	}
+	var out []Detection
+	for _, r := range d.rules {
+		if !r.when.Eval(ev) {
+			continue
+		}
+		det := Detection{Rule: r.Name, Severity: r.min, Alert: r.alert, Drop: r.drop}
+		if r.agg != nil {
+			var fired bool
+			det.Group, det.Count, fired = r.agg.observe(ev)
+			if !fired {
+				continue
+			}
+			det.Window = r.agg.window
+		}
+		ev.Tags = append(ev.Tags, "rule:"+r.Name)
+		ev.Tags = append(ev.Tags, r.Tags...)
+		if r.min > ev.Level() {
+			ev.Severity = r.min
+		}
+		out = append(out, det)
+	}
+	return out
+}
+
+// aggregate counts matching events, or distinct values of a field, per
+// group over a sliding window of event time. It fires when the condition
+// becomes true and re-arms once it is false again.
+type aggregate struct {
+	distinct  string   // field counted, "" for a plain count
+	by        []string // grouping fields
+	window    time.Duration
+	op        string
+	threshold float64
+
+	mu     sync.Mutex
+	groups map[string]*aggGroup
+	swept  time.Time
+}
+
+type aggGroup struct {
+	times  []time.Time          // count: event times within the window
+	values map[string]time.Time // distinct: value -> last seen
+	last   time.Time
+	firing bool
+}
+
+// parseAggregate parses
+//
+//	(count | distinct <field>) [by <field>, ...] over <duration> <op> <number>
+func parseAggregate(src string) (*aggregate, error) {
+	p, err := newParser(src)
+	if err != nil {
+		return nil, err
+	}
+	a := &aggregate{groups: make(map[string]*aggGroup)}
+	fieldName := func() (string, error) {
+		t := p.next()
+		k, ok := fieldKinds[t.text]
+		if t.kind != tIdent || !ok || k == kList {
+			return "", p.errorf(t, "expected a field name, found %s", t)
+		}
+		return t.text, nil
+	}
+	switch t := p.next(); {
+	case t.kind == tIdent && t.text == "count":
+	case t.kind == tIdent && t.text == "distinct":
+		if a.distinct, err = fieldName(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errorf(t, `expected "count" or "distinct", found %s`, t)
+	}
+	if p.accept("by") {
+		for {
+			f, err := fieldName()
+			if err != nil {
+				return nil, err
+			}
+			a.by = append(a.by, f)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err := p.expect("over"); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	if t.kind != tDuration {
+		return nil, p.errorf(t, "expected a duration such as 5m, found %s", t)
+	}
+	if a.window, err = time.ParseDuration(t.text); err != nil || a.window <= 0 {
+		return nil, p.errorf(t, "invalid duration %s", t.text)
+	}
+	t = p.next()
+	if t.kind != tOp || !slices.Contains([]string{"==", "!=", "<", "<=", ">", ">="}, t.text) {
+		return nil, p.errorf(t, "expected a comparison, found %s", t)
+	}
+	a.op = t.text
+	t = p.next()
+	if t.kind != tNumber {
+		return nil, p.errorf(t, "expected a number, found %s", t)
+	}
+	if a.threshold, err = strconv.ParseFloat(t.text, 64); err != nil {
+		return nil, p.errorf(t, "invalid number %s", t.text)
+	}
+	if t := p.peek(); t.kind != tEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return a, nil
+}
+
+// observe adds ev to its group and reports the group, its count and
+// whether the rule fires now.
+func (a *aggregate) observe(ev *model.Event) (string, int, bool) {
+	now := ev.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	parts := make([]string, len(a.by))
+	for i, f := range a.by {
+		parts[i] = f + "=" + fmt.Sprint(fieldValue(ev, f))
+	}
+	key := strings.Join(parts, " ")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.swept) >= a.window {
+		for k, g := range a.groups {
+			if now.Sub(g.last) >= a.window {
+				delete(a.groups, k)
+			}
+		}
+		a.swept = now
+	}
+	g := a.groups[key]
+	if g == nil {
+		g = &aggGroup{}
+		a.groups[key] = g
+	}
+	g.last = now
+	var count int
+	if a.distinct == "" {
+		i := 0
+		for i < len(g.times) && now.Sub(g.times[i]) >= a.window {
+			i++
+		}
+		g.times = append(g.times[i:], now)
+		count = len(g.times)
+	} else {
+		if g.values == nil {
+			g.values = make(map[string]time.Time)
+		}
+		for v, t := range g.values {
+			if now.Sub(t) >= a.window {
+				delete(g.values, v)
+			}
+		}
+		g.values[fmt.Sprint(fieldValue(ev, a.distinct))] = now
+		count = len(g.values)
+	}
+	hold := (&compare{op: a.op, l: &literal{float64(count), kNumber}, r: &literal{a.threshold, kNumber}}).eval(ev).(bool)
+	fire := hold && !g.firing
+	g.firing = hold
+	return key, count, fire
+}
@@ -0,0 +1,180 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+func failure(ip, user string, at time.Time) *model.Event {
+	return &model.Event{Type: "login_failure", Username: user, SourceIP: ip, Hostname: "web1", Timestamp: at}
+}
+
+func TestDetector_CountByIP(t *testing.T) {
+	d, err := NewDetector([]Rule{{Name: "bruteforce", When: `type == "login_failure"`, Aggregate: "count by ip over 5m > 3", Severity: "high", Actions: []string{"alert"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)
+	var fired []int
+	for i := 0; i < 10; i++ {
+		ev := failure("203.0.113.5", "root", start.Add(time.Duration(i)*10*time.Second))
+		if dets := d.Apply(ev); len(dets) > 0 {
+			fired = append(fired, i)
+			det := dets[0]
+			if det.Count != 4 || det.Group != "ip=203.0.113.5" || !det.Alert || ev.Severity != model.SeverityHigh || !slices.Contains(ev.Tags, "rule:bruteforce") {
+				t.Fatalf("unexpected detection %+v on %+v", det, ev)
+			}
+			if text := det.Text(ev); text != "🚨 Rule bruteforce (high): 4 matching events for ip=203.0.113.5 within 5m0s on web1" {
+				t.Fatalf("unexpected text %q", text)
+			}
+		} else if slices.Contains(ev.Tags, "rule:bruteforce") {
+			t.Fatalf("event %d should not be tagged", i)
+		}
+	}
+	if !slices.Equal(fired, []int{3}) {
+		t.Fatalf("should fire once when the threshold is crossed, fired at %v", fired)
+	}
+	// Another IP is counted separately.
+	for i := 0; i < 3; i++ {
+		if d.Apply(failure("198.51.100.1", "root", start.Add(time.Minute))) != nil {
+			t.Fatal("other IP should not fire yet")
+		}
+	}
+	// After the window empties the rule re-arms.
+	later := start.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		d.Apply(failure("203.0.113.5", "root", later))
+	}
+	if dets := d.Apply(failure("203.0.113.5", "root", later)); len(dets) != 1 {
+		t.Fatalf("rule should fire again after the window: %+v", dets)
+	}
+}
+
+func TestDetector_DistinctAndWindow(t *testing.T) {
+	d, err := NewDetector([]Rule{{Name: "spray", When: `type in ["login_failure", "invalid_user"]`, Aggregate: "distinct user by ip over 10m >= 3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)
+	steps := []struct {
+		user   string
+		offset time.Duration
+		fire   bool
+	}{
+		{"a", 0, false},
+		{"a", time.Minute, false},
+		{"b", 2 * time.Minute, false},
+		{"b", 3 * time.Minute, false},
+		{"c", 11 * time.Minute, false}, // "a" left the window
+		{"d", 12 * time.Minute, true},
+		{"e", 13 * time.Minute, false}, // still firing
+	}
+	for i, s := range steps {
+		dets := d.Apply(failure("203.0.113.5", s.user, at.Add(s.offset)))
+		if (len(dets) == 1) != s.fire {
+			t.Fatalf("step %d: fired=%v, want %v", i, len(dets) == 1, s.fire)
+		}
+		if s.fire && dets[0].Count != 3 {
+			t.Fatalf("step %d: count %d", i, dets[0].Count)
+		}
+	}
+}
+
+func TestDetector_MatchActions(t *testing.T) {
+	d, err := NewDetector([]Rule{
+		{Name: "root-external", When: `type == "login_success" && user in ["root", "admin"] && !cidr_match(ip, "10.0.0.0/8")`, Severity: "critical", Tags: []string{"page"}, Actions: []string{"alert"}},
+		{Name: "monitoring", When: `user == "nagios" && cidr_match(ip, "10.0.0.0/8")`, Actions: []string{"drop"}},
+		{Name: "lower", When: `true`, Severity: "info"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := &model.Event{Type: "login_success", Username: "root", SourceIP: "203.0.113.5", Hostname: "web1"}
+	dets := d.Apply(ev)
+	if len(dets) != 2 || !dets[0].Alert || dets[0].Drop || ev.Severity != model.SeverityCritical {
+		t.Fatalf("unexpected detections %+v for %+v", dets, ev)
+	}
+	if !slices.Equal(ev.Tags, []string{"rule:root-external", "page", "rule:lower"}) {
+		t.Fatalf("unexpected tags %v", ev.Tags)
+	}
+	if text := dets[0].Text(ev); text != "🚨 Rule root-external (critical): login_success by root from 203.0.113.5 on web1" {
+		t.Fatalf("unexpected text %q", text)
+	}
+	ev = &model.Event{Type: "login_success", Username: "nagios", SourceIP: "10.1.1.1", Severity: model.SeverityMedium}
+	if dets := d.Apply(ev); len(dets) != 2 || !dets[0].Drop || ev.Severity != model.SeverityMedium {
+		t.Fatalf("expected drop without lowering severity: %+v %+v", dets, ev)
+	}
+	var none *Detector
+	if none.Apply(ev) != nil {
+		t.Fatal("nil detector should match nothing")
+	}
+}
+
+func TestNewDetector_Validation(t *testing.T) {
+	_, err := NewDetector([]Rule{
+		{Name: "ok", When: `true`},
+		{Name: "ok", When: `true`},
+		{When: `true`},
+		{Name: "no-when"},
+		{Name: "bad-expr", When: `user ==`},
+		{Name: "bad-sev", When: `true`, Severity: "urgent"},
+		{Name: "bad-action", When: `true`, Actions: []string{"page"}},
+		{Name: "bad-agg", When: `true`, Aggregate: "count by ip over 5m > many"},
+	})
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{
+		"rule ok: duplicate name", "rule #2: name is required", "rule no-when: when is required",
+		"rule bad-expr: when: col 8: unexpected end of expression", `rule bad-sev: severity: unknown severity "urgent"`,
+		`rule bad-action: unknown action "page"`, `rule bad-agg: aggregate: col 23: expected a number, found "many"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("missing %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestParseAggregate(t *testing.T) {
+	a, err := parseAggregate("distinct user by ip, host over 1h30m >= 5")
+	if err != nil || a.distinct != "user" || !slices.Equal(a.by, []string{"ip", "host"}) || a.window != 90*time.Minute || a.op != ">=" || a.threshold != 5 {
+		t.Fatalf("unexpected aggregate %+v %v", a, err)
+	}
+	if a, err := parseAggregate("count over 30s > 100"); err != nil || a.by != nil || a.window != 30*time.Second {
+		t.Fatalf("unexpected aggregate %+v %v", a, err)
+	}
+	for src, want := range map[string]string{
+		"sum by ip over 5m > 1":     `expected "count" or "distinct"`,
+		"count by tags over 5m > 1": "expected a field name",
+		"count by ip 5m > 1":        `expected "over"`,
+		"count by ip over 5 > 1":    "expected a duration",
+		"count by ip over 5x > 1":   "invalid duration",
+		"count by ip over 5m = 1":   "unexpected character '='",
+		"count by ip over 5m > 1 2": `unexpected "2"`,
+		"distinct over 5m > 1":      "expected a field name",
+	} {
+		if _, err := parseAggregate(src); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: got %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.json")
+	os.WriteFile(path, []byte(`[{"name": "r", "when": "type == \"login_success\"", "severity": "low"}]`), 0o644)
+	d, err := LoadRules(path)
+	if err != nil || len(d.rules) != 1 {
+		t.Fatalf("unexpected %v %v", d, err)
+	}
+	os.WriteFile(path, []byte(`[{"name": "r", "expr": "true"}]`), 0o644)
+	if _, err := LoadRules(path); err == nil || !strings.Contains(err.Error(), `unknown field "expr"`) {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+}
@@ -0,0 +1,718 @@
+package rules
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"ssh-noty/internal/model"
+)
+
+// Expr is a compiled boolean expression over event fields, e.g.
+//
+//	type == "login_success" && user in ["root", "admin"] && !cidr_match(ip, "10.0.0.0/8")
+//
+// Operators are ||, &&, !, ==, !=, <, <=, >, >=, in, not in and =~ (regular
+// expression); see fieldKinds and funcs for the identifiers available.
+// Expressions are type checked when compiled.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile parses and type checks src, which must be a boolean expression.
+func Compile(src string) (*Expr, error) {
+	p, err := newParser(src)
+	if err != nil {
+		return nil, err
+	}
+	n, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	if n.kind() != kBool {
+		return nil, fmt.Errorf("expression is %s, not bool", n.kind())
+	}
+	return &Expr{src: src, root: n}, nil
+}
+
+func (e *Expr) String() string { return e.src }
+
+// Eval evaluates the expression for ev.
+func (e *Expr) Eval(ev *model.Event) bool {
+	return e.root.eval(ev).(bool)
+}
+
+// kind is the static type of an expression.
+type kind int
+
+const (
+	kString kind = iota + 1
+	kNumber
+	kBool
+	kSeverity
+	kList // list of strings
+)
+
+func (k kind) String() string {
+	switch k {
+	case kString:
+		return "string"
+	case kNumber:
+		return "number"
+	case kBool:
+		return "bool"
+	case kSeverity:
+		return "severity"
+	case kList:
+		return "list"
+	}
+	return "invalid"
+}
+
+// fieldKinds are the event fields an expression can reference.
+var fieldKinds = map[string]kind{
+	"type":     kString,
+	"user":     kString,
+	"ip":       kString,
+	"port":     kNumber,
+	"method":   kString,
+	"host":     kString,
+	"key":      kString,
+	"country":  kString,
+	"severity": kSeverity,
+	"tags":     kList,
+}
+
+// fieldValue returns the value of a field listed in fieldKinds.
+func fieldValue(ev *model.Event, name string) any {
+	switch name {
+	case "type":
+		return ev.Type
+	case "user":
+		return ev.Username
+	case "ip":
+		return ev.SourceIP
+	case "port":
+		return float64(ev.Port)
+	case "method":
+		return ev.Method
+	case "host":
+		return ev.Hostname
+	case "key":
+		return ev.KeyFingerprint
+	case "country":
+		return ev.Country
+	case "severity":
+		return ev.Level()
+	case "tags":
+		return ev.Tags
+	}
+	panic("rules: unknown field " + name)
+}
+
+// ---- lexer ----
+
+type tokKind int
+
+const (
+	tEOF tokKind = iota
+	tIdent
+	tString
+	tNumber
+	tDuration
+	tOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("col %d: unterminated string", i+1)
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("col %d: invalid string: %v", i+1, err)
+			}
+			toks = append(toks, token{tString, s, i})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			kind := tNumber
+			// A unit makes a duration, which may have several parts (1h30m).
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || kind == tDuration && (src[j] >= '0' && src[j] <= '9' || src[j] == '.')) {
+				kind = tDuration
+				j++
+			}
+			toks = append(toks, token{kind, src[i:j], i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, token{tIdent, src[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("col %d: unexpected character %q", i+1, c)
+			}
+			toks = append(toks, token{tOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{tEOF, "", len(src)}), nil
+}
+
+// ---- parser ----
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func newParser(src string) (*parser, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{toks: toks}, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator or keyword s.
+func (p *parser) accept(s string) bool {
+	if t := p.peek(); (t.kind == tOp || t.kind == tIdent) && t.text == s {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, found %s", s, t)
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("col %d: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseExpr() (node, error) {
+	return p.parseBinary(0)
+}
+
+// logical operators by increasing precedence.
+var logical = []string{"||", "&&"}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(logical) {
+		return p.parseUnary()
+	}
+	l, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !p.accept(logical[level]) {
+			return l, nil
+		}
+		r, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if l.kind() != kBool || r.kind() != kBool {
+			return nil, p.errorf(t, "%s needs bool operands, got %s and %s", t.text, l.kind(), r.kind())
+		}
+		l = &logic{and: t.text == "&&", l: l, r: r}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if p.accept("!") {
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if n.kind() != kBool {
+			return nil, p.errorf(t, "! needs a bool operand, got %s", n.kind())
+		}
+		return &not{n}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch {
+	case t.kind == tOp && slices.Contains([]string{"==", "!=", "<", "<=", ">", ">="}, t.text):
+		p.next()
+		r, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return p.compare(t, l, r)
+	case p.accept("in"):
+		return p.membership(t, l, false)
+	case t.kind == tIdent && t.text == "not":
+		p.next()
+		if err := p.expect("in"); err != nil {
+			return nil, err
+		}
+		return p.membership(t, l, true)
+	case p.accept("=~"):
+		rt := p.next()
+		if rt.kind != tString {
+			return nil, p.errorf(rt, "=~ needs a string literal pattern")
+		}
+		if l.kind() != kString {
+			return nil, p.errorf(t, "=~ needs a string operand, got %s", l.kind())
+		}
+		re, err := regexp.Compile(rt.text)
+		if err != nil {
+			return nil, p.errorf(rt, "invalid pattern: %v", err)
+		}
+		return &match{l, re}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) compare(t token, l, r node) (node, error) {
+	// A string literal compared with a severity names a severity.
+	if l.kind() == kSeverity || r.kind() == kSeverity {
+		var err error
+		if l, err = asSeverity(l); err == nil {
+			r, err = asSeverity(r)
+		}
+		if err != nil {
+			return nil, p.errorf(t, "%v", err)
+		}
+		return &compare{t.text, l, r}, nil
+	}
+	if l.kind() != r.kind() {
+		return nil, p.errorf(t, "cannot compare %s with %s", l.kind(), r.kind())
+	}
+	switch {
+	case l.kind() == kList:
+		return nil, p.errorf(t, "cannot compare lists")
+	case t.text != "==" && t.text != "!=" && l.kind() != kNumber:
+		return nil, p.errorf(t, "%s needs numbers or severities, got %s", t.text, l.kind())
+	}
+	return &compare{t.text, l, r}, nil
+}
+
+func asSeverity(n node) (node, error) {
+	if n.kind() == kSeverity {
+		return n, nil
+	}
+	lit, ok := n.(*literal)
+	if !ok || n.kind() != kString {
+		return nil, fmt.Errorf("severity can only be compared with a severity name")
+	}
+	sev, err := model.ParseSeverity(lit.v.(string))
+	if err != nil {
+		return nil, err
+	}
+	return &literal{sev, kSeverity}, nil
+}
+
+func (p *parser) membership(t token, l node, negate bool) (node, error) {
+	r, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if r.kind() != kList {
+		return nil, p.errorf(t, "in needs a list on the right, got %s", r.kind())
+	}
+	if lst, ok := r.(*list); ok && len(lst.items) > 0 && lst.items[0].kind() != l.kind() {
+		return nil, p.errorf(t, "cannot look up %s in a list of %s", l.kind(), lst.items[0].kind())
+	}
+	if _, ok := r.(*list); !ok && l.kind() != kString {
+		return nil, p.errorf(t, "cannot look up %s in a list of string", l.kind())
+	}
+	return &in{l, r, negate}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tString:
+		return &literal{t.text, kString}, nil
+	case tNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %s", t.text)
+		}
+		return &literal{f, kNumber}, nil
+	case tDuration:
+		return nil, p.errorf(t, "unexpected duration %s", t.text)
+	case tIdent:
+		switch t.text {
+		case "true", "false":
+			return &literal{t.text == "true", kBool}, nil
+		}
+		if p.accept("(") {
+			return p.parseCall(t)
+		}
+		k, ok := fieldKinds[t.text]
+		if !ok {
+			return nil, p.errorf(t, "unknown field %s", t.text)
+		}
+		return &field{t.text, k}, nil
+	case tOp:
+		switch t.text {
+		case "(":
+			n, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			return p.parseList(t)
+		}
+	}
+	return nil, p.errorf(t, "unexpected %s", t)
+}
+
+func (p *parser) parseList(open token) (node, error) {
+	l := &list{}
+	if p.accept("]") {
+		return l, nil
+	}
+	for {
+		t := p.peek()
+		n, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := n.(*literal); !ok {
+			return nil, p.errorf(t, "list items must be literals")
+		}
+		if len(l.items) > 0 && n.kind() != l.items[0].kind() {
+			return nil, p.errorf(t, "list mixes %s and %s", l.items[0].kind(), n.kind())
+		}
+		l.items = append(l.items, n)
+		if p.accept("]") {
+			return l, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := funcs[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %s", name.text)
+	}
+	var args []node
+	if !p.accept(")") {
+		for {
+			n, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, n)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(args) < len(fn.args) || len(args) > len(fn.args) && !fn.variadic {
+		return nil, p.errorf(name, "%s takes %d argument(s), got %d", name.text, len(fn.args), len(args))
+	}
+	for i, a := range args {
+		want := fn.args[min(i, len(fn.args)-1)]
+		if a.kind() != want {
+			return nil, p.errorf(name, "%s argument %d must be %s, got %s", name.text, i+1, want, a.kind())
+		}
+	}
+	c := &call{name: name.text, fn: fn, args: args}
+	if fn.prepare != nil {
+		var err error
+		if c.data, err = fn.prepare(args); err != nil {
+			return nil, p.errorf(name, "%s: %v", name.text, err)
+		}
+	}
+	return c, nil
+}
+
+// ---- functions ----
+
+type function struct {
+	args     []kind
+	variadic bool // the last argument may repeat
+	result   kind
+	// prepare checks constant arguments when compiling; its result is
+	// passed to eval.
+	prepare func(args []node) (any, error)
+	eval    func(ev *model.Event, args []node, data any) any
+}
+
+var funcs map[string]*function
+
+func init() {
+	str := func(f func(a, b string) bool) *function {
+		return &function{args: []kind{kString, kString}, result: kBool, eval: func(ev *model.Event, args []node, _ any) any {
+			return f(args[0].eval(ev).(string), args[1].eval(ev).(string))
+		}}
+	}
+	funcs = map[string]*function{
+		// cidr_match(ip, "10.0.0.0/8", ...) reports whether ip is in any of
+		// the networks, which must be literals.
+		"cidr_match": {
+			args: []kind{kString, kString}, variadic: true, result: kBool,
+			prepare: func(args []node) (any, error) {
+				var out []netip.Prefix
+				for _, a := range args[1:] {
+					lit, ok := a.(*literal)
+					if !ok {
+						return nil, fmt.Errorf("networks must be string literals")
+					}
+					pfx, err := parsePrefix(lit.v.(string))
+					if err != nil {
+						return nil, err
+					}
+					out = append(out, pfx)
+				}
+				return out, nil
+			},
+			eval: func(ev *model.Event, args []node, data any) any {
+				addr, err := netip.ParseAddr(args[0].eval(ev).(string))
+				if err != nil {
+					return false
+				}
+				addr = addr.Unmap()
+				return slices.ContainsFunc(data.([]netip.Prefix), func(p netip.Prefix) bool { return p.Contains(addr) })
+			},
+		},
+		// glob(s, "pattern") matches shell-style wildcards.
+		"glob": {
+			args: []kind{kString, kString}, result: kBool,
+			prepare: func(args []node) (any, error) {
+				lit, ok := args[1].(*literal)
+				if !ok {
+					return nil, fmt.Errorf("pattern must be a string literal")
+				}
+				_, err := path.Match(lit.v.(string), "")
+				return lit.v.(string), err
+			},
+			eval: func(ev *model.Event, args []node, data any) any {
+				ok, _ := path.Match(data.(string), args[0].eval(ev).(string))
+				return ok
+			},
+		},
+		"starts_with": str(strings.HasPrefix),
+		"ends_with":   str(strings.HasSuffix),
+		"contains":    str(strings.Contains),
+		"lower": {args: []kind{kString}, result: kString, eval: func(ev *model.Event, args []node, _ any) any {
+			return strings.ToLower(args[0].eval(ev).(string))
+		}},
+		// field("auid") is a source-specific detail, "" when absent.
+		"field": {args: []kind{kString}, result: kString, eval: func(ev *model.Event, args []node, _ any) any {
+			return ev.Fields[args[0].eval(ev).(string)]
+		}},
+		// hour(tz) is the hour of the event timestamp in an IANA zone.
+		"hour": {
+			args: []kind{kString}, result: kNumber,
+			prepare: func(args []node) (any, error) {
+				lit, ok := args[0].(*literal)
+				if !ok {
+					return nil, fmt.Errorf("time zone must be a string literal")
+				}
+				return time.LoadLocation(lit.v.(string))
+			},
+			eval: func(ev *model.Event, _ []node, data any) any {
+				return float64(ev.Timestamp.In(data.(*time.Location)).Hour())
+			},
+		},
+	}
+}
+
+// ---- nodes ----
+
+type node interface {
+	kind() kind
+	eval(ev *model.Event) any
+}
+
+type literal struct {
+	v any
+	k kind
+}
+
+func (n *literal) kind() kind            { return n.k }
+func (n *literal) eval(*model.Event) any { return n.v }
+
+type field struct {
+	name string
+	k    kind
+}
+
+func (n *field) kind() kind               { return n.k }
+func (n *field) eval(ev *model.Event) any { return fieldValue(ev, n.name) }
+
+type list struct{ items []node }
+
+func (n *list) kind() kind { return kList }
+func (n *list) eval(ev *model.Event) any {
+	out := make([]any, len(n.items))
+	for i, it := range n.items {
+		out[i] = it.eval(ev)
+	}
+	return out
+}
+
+type logic struct {
+	and  bool
+	l, r node
+}
+
+func (n *logic) kind() kind { return kBool }
+func (n *logic) eval(ev *model.Event) any {
+	if n.and {
+		return n.l.eval(ev).(bool) && n.r.eval(ev).(bool)
+	}
+	return n.l.eval(ev).(bool) || n.r.eval(ev).(bool)
+}
+
+type not struct{ n node }
+
+func (n *not) kind() kind               { return kBool }
+func (n *not) eval(ev *model.Event) any { return !n.n.eval(ev).(bool) }
+
+type compare struct {
+	op   string
+	l, r node
+}
+
+func (n *compare) kind() kind { return kBool }
+func (n *compare) eval(ev *model.Event) any {
+	l, r := n.l.eval(ev), n.r.eval(ev)
+	switch n.op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	}
+	var a, b float64
+	switch l := l.(type) {
+	case float64:
+		a, b = l, r.(float64)
+	case model.Severity:
+		a, b = float64(l), float64(r.(model.Severity))
+	}
+	switch n.op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+type in struct {
+	l, r   node
+	negate bool
+}
+
+func (n *in) kind() kind { return kBool }
+func (n *in) eval(ev *model.Event) any {
+	v := n.l.eval(ev)
+	var found bool
+	switch r := n.r.eval(ev).(type) {
+	case []any:
+		found = slices.Contains(r, v)
+	case []string:
+		found = slices.Contains(r, v.(string))
+	}
+	return found != n.negate
+}
+
+type match struct {
+	l  node
+	re *regexp.Regexp
+}
+
+func (n *match) kind() kind               { return kBool }
+func (n *match) eval(ev *model.Event) any { return n.re.MatchString(n.l.eval(ev).(string)) }
+
+type call struct {
+	name string
+	fn   *function
+	args []node
+	data any
+}
+
+func (n *call) kind() kind               { return n.fn.result }
+func (n *call) eval(ev *model.Event) any { return n.fn.eval(ev, n.args, n.data) }
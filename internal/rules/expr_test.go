@@ -0,0 +1,114 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+func exprEvent() *model.Event {
+	return &model.Event{
+		Type: "login_success", Username: "root", SourceIP: "203.0.113.5", Port: 2222, Method: "publickey",
+		KeyFingerprint: "SHA256:abc", Hostname: "web1", Country: "ES", Severity: model.SeverityHigh,
+		Timestamp: time.Date(2024, 1, 8, 21, 30, 0, 0, time.UTC),
+		Fields:    map[string]string{"auid": "1000"}, Tags: []string{"root_user", "vpn"},
+	}
+}
+
+func TestExpr_Eval(t *testing.T) {
+	ev := exprEvent()
+	for src, want := range map[string]bool{
+		`type == "login_success"`:                                      true,
+		`type != "login_success"`:                                      false,
+		`type == "login_success" && user in ["root", "admin"]`:         true,
+		`type == "login_success" && user in ["alice"]`:                 false,
+		`user not in ["alice", "bob"]`:                                 true,
+		`!cidr_match(ip, "10.0.0.0/8")`:                                true,
+		`cidr_match(ip, "10.0.0.0/8", "203.0.113.0/24")`:               true,
+		`cidr_match(ip, "203.0.113.5")`:                                true,
+		`port == 2222 && port > 1024 && port <= 2222 && port < 3000.5`: true,
+		`port in [22, 2222]`:                                           true,
+		`port >= 2223`:                                                 false,
+		`severity >= "high"`:                                           true,
+		`severity > "high"`:                                            false,
+		`"medium" < severity`:                                          true,
+		`severity == "high"`:                                           true,
+		`"vpn" in tags && "admin" not in tags`:                         true,
+		`host =~ "^web[0-9]+$"`:                                        true,
+		`user =~ "^adm"`:                                               false,
+		`glob(host, "web*") && starts_with(key, "SHA256:")`:            true,
+		`ends_with(ip, ".5") && contains(method, "key")`:               true,
+		`lower(country) == "es"`:                                       true,
+		`field("auid") == "1000" && field("ses") == ""`:                true,
+		`hour("Europe/Madrid") == 22`:                                  true,
+		`hour("UTC") >= 8 && hour("UTC") < 19`:                         false,
+		`false || (true && !false)`:                                    true,
+		`!(user == "root") || method == "password"`:                    false,
+		`user == "x" || user == "y" || user == "root"`:                 true,
+	} {
+		e, err := Compile(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if got := e.Eval(ev); got != want {
+			t.Fatalf("%s: got %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestExpr_Precedence(t *testing.T) {
+	ev := exprEvent()
+	// && binds tighter than ||.
+	e, err := Compile(`user == "root" || user == "x" && false`)
+	if err != nil || !e.Eval(ev) {
+		t.Fatalf("expected true: %v", err)
+	}
+	e, _ = Compile(`(user == "root" || user == "x") && false`)
+	if e.Eval(ev) {
+		t.Fatal("parentheses should group ||")
+	}
+}
+
+func TestExpr_CompileErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		``:                              "unexpected end of expression",
+		`user`:                          "expression is string, not bool",
+		`usr == "root"`:                 "col 1: unknown field usr",
+		`user == 1`:                     "cannot compare string with number",
+		`user < "b"`:                    "< needs numbers or severities",
+		`severity >= "urgent"`:          `unknown severity "urgent"`,
+		`severity == user`:              "severity can only be compared with a severity name",
+		`user in ["a", 1]`:              "list mixes string and number",
+		`port in ["22"]`:                "cannot look up number in a list of string",
+		`user in user`:                  "in needs a list on the right",
+		`user in [user]`:                "list items must be literals",
+		`user == "a" &&`:                "unexpected end of expression",
+		`user == "a" & true`:            `col 13: unexpected character '&'`,
+		`user == "a" true`:              `col 13: unexpected "true"`,
+		`(user == "a"`:                  `expected ")"`,
+		`"unterminated`:                 "unterminated string",
+		`!user`:                         "! needs a bool operand, got string",
+		`port && true`:                  "&& needs bool operands",
+		`user =~ "("`:                   "invalid pattern",
+		`user =~ host`:                  "=~ needs a string literal pattern",
+		`port =~ "1"`:                   "=~ needs a string operand",
+		`nope(user)`:                    "unknown function nope",
+		`cidr_match(ip)`:                "cidr_match takes 2 argument(s), got 1",
+		`cidr_match(ip, "10.0.0.0/33")`: "cidr_match",
+		`cidr_match(ip, host)`:          "networks must be string literals",
+		`starts_with(user, 1)`:          "starts_with argument 2 must be string, got number",
+		`glob(user, "[")`:               "syntax error in pattern",
+		`hour("Mars/Base") > 1`:         "unknown time zone",
+		`lower(user, host) == "a"`:      "lower takes 1 argument(s), got 2",
+		`port > 5m`:                     "unexpected duration 5m",
+		`tags == tags`:                  "cannot compare lists",
+		`user not user`:                 `expected "in"`,
+	} {
+		_, err := Compile(src)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: got error %v, want %q", src, err, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/schedule"
+)
+
+// Policies checks successful logins against per-user allowed windows.
+type Policies struct {
+	list []*policy
+}
+
+type policy struct {
+	config.Policy
+	allowed []*schedule.Window
+	sev     model.Severity
+}
+
+// Violation is a login outside the schedule of a policy.
+type Violation struct {
+	Policy   string
+	Severity model.Severity
+	Alert    bool
+	// Local is the login time in the zone of the policy's first window.
+	Local time.Time
+}
+
+// Text describes the violation for an alert message.
+func (v *Violation) Text(ev *model.Event) string {
+	return fmt.Sprintf("⏰ Login outside schedule %s: %s from %s on %s at %s",
+		v.Policy, ev.Username, ev.SourceIP, ev.Hostname, v.Local.Format("Mon 2006-01-02 15:04 MST"))
+}
+
+// NewPolicies compiles policies; it returns nil when there are none.
+func NewPolicies(cfg []config.Policy) (*Policies, error) {
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+	ps := &Policies{}
+	for _, c := range cfg {
+		p := &policy{Policy: c, sev: model.SeverityHigh}
+		if len(c.Allowed) == 0 {
+			return nil, fmt.Errorf("policy %s: allowed needs at least one window", c.Name)
+		}
+		for _, h := range c.Allowed {
+			w, err := h.Window()
+			if err == nil && w == nil {
+				err = errors.New("start and end are required")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("policy %s: %w", c.Name, err)
+			}
+			p.allowed = append(p.allowed, w)
+		}
+		if c.Severity != "" {
+			var err error
+			if p.sev, err = model.ParseSeverity(c.Severity); err != nil {
+				return nil, fmt.Errorf("policy %s: %w", c.Name, err)
+			}
+		}
+		ps.list = append(ps.list, p)
+	}
+	return ps, nil
+}
+
+// Check evaluates a successful login at its own timestamp against the
+// first policy matching its user. A violation tags ev and raises its
+// severity. A nil Policies allows everything.
+func (ps *Policies) Check(ev *model.Event) *Violation {
+	if ps == nil || ev.Type != "login_success" {
+		return nil
+	}
+	at := ev.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	for _, p := range ps.list {
+		if !p.matches(ev.Username) {
+			continue
+		}
+		for _, w := range p.allowed {
+			if w.Contains(at) {
+				return nil
+			}
+		}
+		ev.Tags = append(ev.Tags, "outside_schedule", "policy:"+p.Name)
+		if p.sev > ev.Level() {
+			ev.Severity = p.sev
+		}
+		return &Violation{Policy: p.Name, Severity: p.sev, Alert: p.Alert, Local: at.In(p.allowed[0].Location())}
+	}
+	return nil
+}
+
+func (p *policy) matches(user string) bool {
+	if len(p.Users) == 0 {
+		return true
+	}
+	for _, u := range p.Users {
+		if ok, _ := path.Match(u, user); ok {
+			return true
+		}
+	}
+	return false
+}
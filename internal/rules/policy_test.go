@@ -0,0 +1,47 @@
+package rules
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func TestPolicies_OutsideSchedule(t *testing.T) {
+	ps, err := NewPolicies([]config.Policy{
+		{Name: "ops", Users: []string{"ops-*"}, Allowed: []config.Hours{{Timezone: "Europe/Madrid", Days: []string{"mon-fri"}, Start: "08:00", End: "19:00"}}, Alert: true},
+		{Name: "night", Allowed: []config.Hours{{Start: "22:00", End: "06:00"}}, Severity: "critical"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Monday 18:30 in Madrid, whatever the local zone of the host.
+	in := time.Date(2024, 1, 8, 17, 30, 0, 0, time.UTC)
+	ev := model.Event{Type: "login_success", Username: "ops-ana", Timestamp: in}
+	if v := ps.Check(&ev); v != nil {
+		t.Fatalf("login inside the window flagged: %+v", v)
+	}
+	ev = model.Event{Type: "login_success", Username: "ops-ana", Timestamp: in.Add(time.Hour)}
+	v := ps.Check(&ev)
+	if v == nil || v.Policy != "ops" || !v.Alert || ev.Severity != model.SeverityHigh || !slices.Contains(ev.Tags, "policy:ops") || !slices.Contains(ev.Tags, "outside_schedule") {
+		t.Fatalf("got %+v, event %+v", v, ev)
+	}
+	if v.Local.Hour() != 19 {
+		t.Fatalf("local time should be in Madrid, got %s", v.Local)
+	}
+	// Other users fall through to the catch-all policy.
+	ev = model.Event{Type: "login_success", Username: "bob", Timestamp: in}
+	if v := ps.Check(&ev); v == nil || v.Policy != "night" || ev.Severity != model.SeverityCritical {
+		t.Fatalf("got %+v, event %+v", v, ev)
+	}
+	ev = model.Event{Type: "login_failure", Username: "bob", Timestamp: in}
+	if v := ps.Check(&ev); v != nil {
+		t.Fatal("only successful logins are checked")
+	}
+	var none *Policies
+	if none.Check(&ev) != nil {
+		t.Fatal("nil policies should allow everything")
+	}
+}
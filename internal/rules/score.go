@@ -0,0 +1,172 @@
+package rules
+
+import (
+	"bufio"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/schedule"
+)
+
+// Scorer sets event severity from the score of its type plus the bumps for
+// risk factors that apply. Every applied bump also tags the event with its
+// name (e.g. "root_user") so routes can match on it.
+type Scorer struct {
+	base       map[string]int
+	bumps      map[string]int
+	thresholds []threshold
+	knownKeys  []string
+	blocklist  []netip.Prefix
+	home       []string
+	hours      *schedule.Window
+	window     time.Duration
+
+	mu       sync.Mutex
+	failures map[string]time.Time // source IP -> last failure
+	swept    time.Time
+}
+
+type threshold struct {
+	score int
+	sev   model.Severity
+}
+
+// NewScorer compiles a scoring config with its defaults applied.
+func NewScorer(cfg config.Scoring) (*Scorer, error) {
+	s := &Scorer{
+		base:      cfg.Base,
+		bumps:     cfg.Bumps,
+		knownKeys: cfg.KnownKeys,
+		window:    time.Duration(cfg.FailureWindowSeconds) * time.Second,
+		failures:  make(map[string]time.Time),
+	}
+	for name, score := range cfg.Thresholds {
+		sev, err := model.ParseSeverity(name)
+		if err != nil {
+			return nil, err
+		}
+		s.thresholds = append(s.thresholds, threshold{score, sev})
+	}
+	sort.Slice(s.thresholds, func(i, j int) bool { return s.thresholds[i].score > s.thresholds[j].score })
+	for _, c := range cfg.HomeCountries {
+		s.home = append(s.home, strings.ToUpper(c))
+	}
+	entries := cfg.Blocklist
+	if cfg.BlocklistFile != "" {
+		lines, err := readList(cfg.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(append([]string(nil), entries...), lines...)
+	}
+	for _, e := range entries {
+		p, err := parsePrefix(e)
+		if err != nil {
+			return nil, err
+		}
+		s.blocklist = append(s.blocklist, p)
+	}
+	var err error
+	if s.hours, err = cfg.BusinessHours.Window(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Score computes ev's score, sets its severity and tags, and returns the
+// score.
+func (s *Scorer) Score(ev *model.Event) int {
+	score := s.base[ev.Type]
+	bump := func(name string, applies bool) {
+		if applies && s.bumps[name] != 0 {
+			score += s.bumps[name]
+			ev.Tags = append(ev.Tags, name)
+		}
+	}
+	addr, err := netip.ParseAddr(ev.SourceIP)
+	addr = addr.Unmap()
+	bump("root_user", ev.Username == "root")
+	bump("unknown_key", len(s.knownKeys) > 0 && ev.KeyFingerprint != "" && !slices.Contains(s.knownKeys, ev.KeyFingerprint))
+	bump("blocklisted_ip", err == nil && slices.ContainsFunc(s.blocklist, func(p netip.Prefix) bool { return p.Contains(addr) }))
+	bump("foreign_country", len(s.home) > 0 && ev.Country != "" && !slices.Contains(s.home, strings.ToUpper(ev.Country)))
+	bump("off_hours", s.hours != nil && !ev.Timestamp.IsZero() && !s.hours.Contains(ev.Timestamp))
+	bump("success_after_failures", s.afterFailures(ev))
+
+	ev.Severity = model.SeverityInfo
+	for _, t := range s.thresholds {
+		if score >= t.score {
+			ev.Severity = t.sev
+			break
+		}
+	}
+	return score
+}
+
+// afterFailures records failures per source IP and reports whether ev is a
+// successful login from an IP that failed within the window. Time is taken
+// from event timestamps so replays score like live runs.
+func (s *Scorer) afterFailures(ev *model.Event) bool {
+	if ev.SourceIP == "" {
+		return false
+	}
+	now := ev.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.swept) >= s.window {
+		// Forget IPs that stopped failing.
+		for ip, t := range s.failures {
+			if now.Sub(t) >= s.window {
+				delete(s.failures, ip)
+			}
+		}
+		s.swept = now
+	}
+	last, failed := s.failures[ev.SourceIP]
+	switch ev.Type {
+	case "login_failure", "invalid_user":
+		s.failures[ev.SourceIP] = now
+	case "login_success":
+		delete(s.failures, ev.SourceIP)
+		return failed && now.Sub(last) < s.window
+	}
+	return false
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+// readList reads one entry per line, skipping blanks and # comments.
+func readList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out, sc.Err()
+}
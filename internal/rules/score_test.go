@@ -0,0 +1,87 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+)
+
+func testScoring(t *testing.T) config.Scoring {
+	t.Helper()
+	blocklist := filepath.Join(t.TempDir(), "blocklist")
+	os.WriteFile(blocklist, []byte("# scanners\n198.51.100.0/24\n"), 0o644)
+	return config.Scoring{
+		Base:                 map[string]int{"login_success": 40, "login_failure": 20, "invalid_user": 20},
+		Bumps:                map[string]int{"root_user": 30, "unknown_key": 20, "blocklisted_ip": 40, "foreign_country": 20, "off_hours": 20, "success_after_failures": 40},
+		Thresholds:           map[string]int{"low": 20, "medium": 40, "high": 60, "critical": 80},
+		KnownKeys:            []string{"SHA256:good"},
+		BlocklistFile:        blocklist,
+		HomeCountries:        []string{"es"},
+		BusinessHours:        config.Hours{Timezone: "UTC", Days: []string{"mon-fri"}, Start: "08:00", End: "19:00"},
+		FailureWindowSeconds: 600,
+	}
+}
+
+func TestScorer_Bumps(t *testing.T) {
+	s, err := NewScorer(testScoring(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	monday := time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name  string
+		ev    model.Event
+		score int
+		sev   model.Severity
+		tags  []string
+	}{
+		{"plain success", model.Event{Type: "login_success", Username: "alice", SourceIP: "192.0.2.1", KeyFingerprint: "SHA256:good", Country: "ES", Timestamp: monday}, 40, model.SeverityMedium, nil},
+		{"root", model.Event{Type: "login_success", Username: "root", SourceIP: "192.0.2.2", Timestamp: monday}, 70, model.SeverityHigh, []string{"root_user"}},
+		{"unknown key abroad", model.Event{Type: "login_success", Username: "alice", SourceIP: "192.0.2.3", KeyFingerprint: "SHA256:other", Country: "FR", Timestamp: monday}, 80, model.SeverityCritical, []string{"unknown_key", "foreign_country"}},
+		{"blocklisted off hours", model.Event{Type: "login_failure", Username: "alice", SourceIP: "::ffff:198.51.100.9", Timestamp: monday.Add(-3 * time.Hour)}, 80, model.SeverityCritical, []string{"blocklisted_ip", "off_hours"}},
+		{"session", model.Event{Type: "session_closed", Username: "alice", Timestamp: monday}, 0, model.SeverityInfo, nil},
+	} {
+		ev := tc.ev
+		if got := s.Score(&ev); got != tc.score || ev.Severity != tc.sev || !slices.Equal(ev.Tags, tc.tags) {
+			t.Fatalf("%s: got %d %s %v, want %d %s %v", tc.name, got, ev.Severity, ev.Tags, tc.score, tc.sev, tc.tags)
+		}
+	}
+}
+
+func TestScorer_SuccessAfterFailures(t *testing.T) {
+	s, err := NewScorer(testScoring(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)
+	ev := func(typ string, offset time.Duration) *model.Event {
+		return &model.Event{Type: typ, Username: "bob", SourceIP: "203.0.113.5", Timestamp: at.Add(offset)}
+	}
+	s.Score(ev("login_failure", 0))
+	s.Score(ev("invalid_user", time.Minute))
+	if ok := ev("login_success", 2*time.Minute); s.Score(ok) != 80 || ok.Severity != model.SeverityCritical {
+		t.Fatalf("success after failures should be critical: %+v", ok)
+	}
+	if again := ev("login_success", 3*time.Minute); s.Score(again) != 40 {
+		t.Fatalf("a success should clear the failures: %+v", again)
+	}
+	s.Score(ev("login_failure", 4*time.Minute))
+	if late := ev("login_success", 20*time.Minute); s.Score(late) != 40 {
+		t.Fatalf("failures outside the window should not count: %+v", late)
+	}
+}
+
+func TestScorer_DisabledBump(t *testing.T) {
+	cfg := testScoring(t)
+	cfg.Bumps["root_user"] = 0
+	s, _ := NewScorer(cfg)
+	ev := &model.Event{Type: "login_success", Username: "root", Timestamp: time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)}
+	if s.Score(ev) != 40 || len(ev.Tags) != 0 {
+		t.Fatalf("a zero bump should not apply: %+v", ev)
+	}
+}
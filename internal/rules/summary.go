@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"sort"
+	"ssh-noty/internal/model"
+	"time"
+)
+
+// SummaryBuilder counts events by type, source IP and user for batch digests.
+type SummaryBuilder struct {
+	start, end time.Time
+	hostname   string
+	counts     map[string]int
+	ips        map[string]int
+	users      map[string]int
+	silenced   map[string]int
+	violations map[string]int
+}
+
+func NewSummaryBuilder(start, end time.Time) *SummaryBuilder {
+	return &SummaryBuilder{
+		start:      start,
+		end:        end,
+		counts:     make(map[string]int),
+		ips:        make(map[string]int),
+		users:      make(map[string]int),
+		silenced:   make(map[string]int),
+		violations: make(map[string]int),
+	}
+}
+
+// Add records ev if its timestamp falls inside the summary window.
+func (b *SummaryBuilder) Add(ev *model.Event) {
+	if ev.Timestamp.Before(b.start) || ev.Timestamp.After(b.end) {
+		return
+	}
+	if b.hostname == "" {
+		b.hostname = ev.Hostname
+	}
+	b.counts[ev.Type]++
+	if ev.SourceIP != "" {
+		b.ips[ev.SourceIP]++
+	}
+	if ev.Username != "" {
+		b.users[ev.Username]++
+	}
+}
+
+// Silenced records that the silence labelled label suppressed ev.
+func (b *SummaryBuilder) Silenced(ev *model.Event, label string) {
+	if ev.Timestamp.Before(b.start) || ev.Timestamp.After(b.end) {
+		return
+	}
+	b.silenced[label]++
+}
+
+// Violation records a policy violation or rule alert for ev under key.
+func (b *SummaryBuilder) Violation(ev *model.Event, key string) {
+	if ev.Timestamp.Before(b.start) || ev.Timestamp.After(b.end) {
+		return
+	}
+	b.violations[key]++
+}
+
+func (b *SummaryBuilder) Summary(topN int) *model.Summary {
+	return &model.Summary{
+		Hostname:   b.hostname,
+		Start:      b.start,
+		End:        b.end,
+		Counts:     b.counts,
+		TopSources: top(b.ips, topN),
+		TopUsers:   top(b.users, topN),
+		Silenced:   top(b.silenced, 0),
+		Violations: top(b.violations, 0),
+	}
+}
+
+func top(m map[string]int, n int) []model.Count {
+	out := make([]model.Count, 0, len(m))
+	for k, v := range m {
+		out = append(out, model.Count{Key: k, Count: v})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
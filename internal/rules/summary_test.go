@@ -0,0 +1,26 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+func TestSummaryBuilder_Violations(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	b := NewSummaryBuilder(start, start.Add(time.Hour))
+	in := &model.Event{Type: "login_success", Username: "ops1", SourceIP: "203.0.113.5", Timestamp: start.Add(time.Minute)}
+	out := &model.Event{Type: "login_success", Username: "ops1", Timestamp: start.Add(-time.Minute)}
+	b.Add(in)
+	b.Violation(in, "policy:ops")
+	b.Violation(in, "policy:ops")
+	b.Violation(in, "rule:root-external")
+	b.Violation(out, "policy:ops")
+
+	sum := b.Summary(5)
+	want := []model.Count{{Key: "policy:ops", Count: 2}, {Key: "rule:root-external", Count: 1}}
+	if len(sum.Violations) != len(want) || sum.Violations[0] != want[0] || sum.Violations[1] != want[1] {
+		t.Fatalf("violations = %+v, want %+v", sum.Violations, want)
+	}
+}
@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Calendar is a set of whole days, such as public holidays. Days are civil
+// dates, checked in the zone of the window using the calendar.
+type Calendar struct {
+	dates  map[string]bool // "2006-01-02"
+	yearly map[string]bool // "01-02"
+}
+
+// maxEventDays bounds how many days one calendar entry can cover.
+const maxEventDays = 366
+
+// LoadCalendar reads holiday files. A file starting with BEGIN:VCALENDAR is
+// parsed as iCalendar (all-day or timed VEVENTs, RRULE:FREQ=YEARLY);
+// anything else has one "2006-01-02" date, "01-02" yearly date or
+// "2006-01-02..2006-01-05" range per line, optionally followed by a name,
+// with # comments.
+func LoadCalendar(paths ...string) (*Calendar, error) {
+	c := &Calendar{dates: make(map[string]bool), yearly: make(map[string]bool)}
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(string(b), "\ufeff")), "BEGIN:VCALENDAR") {
+			err = c.readICS(string(b))
+		} else {
+			err = c.readDates(string(b))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return c, nil
+}
+
+// Contains reports whether the date is in the calendar. A nil Calendar is
+// empty.
+func (c *Calendar) Contains(year int, month time.Month, day int) bool {
+	if c == nil {
+		return false
+	}
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return c.dates[d.Format("2006-01-02")] || c.yearly[d.Format("01-02")]
+}
+
+func (c *Calendar) readDates(s string) error {
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		from, to, isRange := strings.Cut(fields[0], "..")
+		if !isRange && len(from) == len("01-02") {
+			d, err := time.Parse("01-02", from)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid date %q", n, from)
+			}
+			c.yearly[d.Format("01-02")] = true
+			continue
+		}
+		start, err := time.Parse("2006-01-02", from)
+		end := start
+		if err == nil && isRange {
+			end, err = time.Parse("2006-01-02", to)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: invalid date %q (want YYYY-MM-DD, MM-DD or a range)", n, fields[0])
+		}
+		if err := c.addRange(start, end.AddDate(0, 0, 1)); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// readICS adds the days of every VEVENT; DTEND is exclusive.
+func (c *Calendar) readICS(s string) error {
+	// Unfold continuation lines (RFC 5545 3.1).
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.NewReplacer("\n ", "", "\n\t", "").Replace(s)
+	var start, end time.Time
+	var yearly, inEvent bool
+	for n, line := range strings.Split(s, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+		var err error
+		var partial bool
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			start, end, yearly, inEvent = time.Time{}, time.Time{}, false, true
+		case !inEvent:
+		case name == "DTSTART":
+			start, _, err = icsDate(value)
+		case name == "DTEND":
+			if end, partial, err = icsDate(value); partial {
+				end = end.AddDate(0, 0, 1)
+			}
+		case name == "RRULE":
+			yearly = strings.Contains(strings.ToUpper(value), "FREQ=YEARLY")
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if start.IsZero() {
+				return fmt.Errorf("line %d: VEVENT without DTSTART", n+1)
+			}
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			if yearly {
+				for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+					c.yearly[d.Format("01-02")] = true
+				}
+				continue
+			}
+			err = c.addRange(start, end)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n+1, err)
+		}
+	}
+	return nil
+}
+
+// icsDate returns the day of a DATE or DATE-TIME value and whether a
+// DATE-TIME lies past midnight, in which case an end still covers the day.
+// Times are taken in their own zone.
+func icsDate(value string) (time.Time, bool, error) {
+	d, err := time.Parse("20060102", value[:min(len(value), 8)])
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+	}
+	clock := strings.TrimSuffix(value[8:], "Z")
+	return d, clock != "" && clock != "T000000", nil
+}
+
+// addRange adds the days in [start, end).
+func (c *Calendar) addRange(start, end time.Time) error {
+	if end.Sub(start) > maxEventDays*24*time.Hour {
+		return fmt.Errorf("range from %s is longer than %d days", start.Format("2006-01-02"), maxEventDays)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("range from %s ends before it starts", start.Format("2006-01-02"))
+	}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		c.dates[d.Format("2006-01-02")] = true
+	}
+	return nil
+}
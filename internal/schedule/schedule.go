@@ -0,0 +1,104 @@
+// Package schedule evaluates weekly time windows in a time zone.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // hosts without zoneinfo still resolve IANA names
+)
+
+// Window is a daily time range on selected weekdays, in one location. A
+// range whose end is not after its start runs past midnight and belongs to
+// the day it starts on.
+type Window struct {
+	loc        *time.Location
+	days       [7]bool
+	start, end time.Duration
+	holidays   *Calendar
+}
+
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Parse builds a Window. tz is an IANA zone name (default UTC), days are
+// "mon".."sun" or ranges such as "mon-fri" (default every day), start and
+// end are "15:04".
+func Parse(tz string, days []string, start, end string) (*Window, error) {
+	w := &Window{loc: time.UTC}
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, err
+		}
+		w.loc = loc
+	}
+	if len(days) == 0 {
+		days = []string{"sun-sat"}
+	}
+	for _, d := range days {
+		from, to, isRange := strings.Cut(strings.ToLower(d), "-")
+		a, b := dayIndex(from), dayIndex(to)
+		if !isRange {
+			b = a
+		}
+		if a < 0 || b < 0 {
+			return nil, fmt.Errorf("invalid day %q", d)
+		}
+		for i := a; ; i = (i + 1) % 7 {
+			w.days[i] = true
+			if i == b {
+				break
+			}
+		}
+	}
+	var err error
+	if w.start, err = clock(start); err != nil {
+		return nil, err
+	}
+	if w.end, err = clock(end); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func dayIndex(s string) int {
+	for i, n := range dayNames {
+		if strings.HasPrefix(s, n) {
+			return i
+		}
+	}
+	return -1
+}
+
+func clock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// SetHolidays closes the window on the days of c.
+func (w *Window) SetHolidays(c *Calendar) { w.holidays = c }
+
+// Location is the window's time zone.
+func (w *Window) Location() *time.Location { return w.loc }
+
+// Contains reports whether t, converted to the window's location, falls in
+// the window and the day it opened on is not a holiday.
+func (w *Window) Contains(t time.Time) bool {
+	lt := t.In(w.loc)
+	h, m, sec := lt.Clock()
+	off := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	day := int(lt.Weekday())
+	switch {
+	case w.start < w.end && w.days[day] && off >= w.start && off < w.end:
+	case w.start >= w.end && w.days[day] && off >= w.start:
+	case w.start >= w.end && w.days[(day+6)%7] && off < w.end:
+		lt = lt.AddDate(0, 0, -1)
+	default:
+		return false
+	}
+	y, mo, d := lt.Date()
+	return !w.holidays.Contains(y, mo, d)
+}
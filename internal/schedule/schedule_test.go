@@ -0,0 +1,94 @@
+package schedule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWindow_ContainsInZone(t *testing.T) {
+	w, err := Parse("Europe/Madrid", []string{"mon-fri"}, "08:00", "19:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		at   string
+		want bool
+	}{
+		{"2024-01-08T07:30:00Z", true},  // Monday 08:30 CET
+		{"2024-01-08T06:59:00Z", false}, // Monday 07:59 CET
+		{"2024-07-08T16:59:00Z", true},  // Monday 18:59 CEST
+		{"2024-07-08T17:00:00Z", false}, // Monday 19:00 CEST
+		{"2024-01-13T10:00:00Z", false}, // Saturday
+	} {
+		at, _ := time.Parse(time.RFC3339, tc.at)
+		if got := w.Contains(at); got != tc.want {
+			t.Fatalf("%s: got %v, want %v", tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestWindow_OvernightAndErrors(t *testing.T) {
+	w, err := Parse("", []string{"fri"}, "22:00", "06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fri := time.Date(2024, 1, 12, 23, 0, 0, 0, time.UTC)
+	if !w.Contains(fri) || !w.Contains(fri.Add(6*time.Hour)) || w.Contains(fri.Add(8*time.Hour)) || w.Contains(fri.Add(-24*time.Hour)) {
+		t.Fatal("overnight window should run from Friday night into Saturday morning only")
+	}
+	for _, bad := range [][]string{{"Mars/Base", "mon", "08:00", "09:00"}, {"", "funday", "08:00", "09:00"}, {"", "mon", "8am", "09:00"}} {
+		if _, err := Parse(bad[0], []string{bad[1]}, bad[2], bad[3]); err == nil {
+			t.Fatalf("expected error for %v", bad)
+		}
+	}
+}
+
+func TestCalendar_DateFileAndICS(t *testing.T) {
+	dir := t.TempDir()
+	dates := filepath.Join(dir, "holidays.txt")
+	os.WriteFile(dates, []byte("# Madrid\n2024-01-06 Reyes\n2024-12-24..2024-12-26\n05-01 Labour day\n"), 0o644)
+	ics := filepath.Join(dir, "holidays.ics")
+	os.WriteFile(ics, []byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20240815\r\nDTEND;VALUE=DATE:20240817\r\nSUMMARY:Long\r\n  weekend\r\nEND:VEVENT\r\nBEGIN:VEVENT\r\nDTSTART:20231012T000000Z\r\nRRULE:FREQ=YEARLY\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"), 0o644)
+	c, err := LoadCalendar(dates, ics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"2024-01-06", "2024-12-25", "2024-12-26", "2030-05-01", "2024-08-16", "2026-10-12"} {
+		at, _ := time.Parse("2006-01-02", d)
+		if !c.Contains(at.Date()) {
+			t.Fatalf("%s should be a holiday", d)
+		}
+	}
+	for _, d := range []string{"2025-01-06", "2024-12-27", "2024-08-17"} {
+		at, _ := time.Parse("2006-01-02", d)
+		if c.Contains(at.Date()) {
+			t.Fatalf("%s should not be a holiday", d)
+		}
+	}
+	os.WriteFile(dates, []byte("2024-13-01\n"), 0o644)
+	if _, err := LoadCalendar(dates); err == nil {
+		t.Fatal("expected error for an invalid date")
+	}
+}
+
+func TestWindow_ClosedOnHolidays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holidays.txt")
+	os.WriteFile(path, []byte("2024-01-08\n"), 0o644)
+	c, err := LoadCalendar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, _ := Parse("Europe/Madrid", []string{"mon-fri"}, "08:00", "19:00")
+	w.SetHolidays(c)
+	if w.Contains(time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)) || !w.Contains(time.Date(2024, 1, 9, 9, 0, 0, 0, time.UTC)) {
+		t.Fatal("window should be closed on the holiday only")
+	}
+	night, _ := Parse("Europe/Madrid", nil, "22:00", "06:00")
+	night.SetHolidays(c)
+	// Tuesday 01:00 Madrid belongs to the shift opened on the Monday holiday.
+	if night.Contains(time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC)) || !night.Contains(time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("overnight window should be closed for the shift starting on the holiday")
+	}
+}
@@ -0,0 +1,138 @@
+package silence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Handler serves the store to the silence CLI:
+//
+//	GET  /silences              active and pending silences (?all=1 adds ended ones)
+//	POST /silences              add the Silence in the body
+//	POST /silences/{id}/expire  end a silence now
+func Handler(s *Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /silences", func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+		if r.URL.Query().Get("all") != "" {
+			t = time.Time{}
+		}
+		list := s.List(t)
+		if list == nil {
+			list = []Silence{}
+		}
+		reply(w, http.StatusOK, list)
+	})
+	mux.HandleFunc("POST /silences", func(w http.ResponseWriter, r *http.Request) {
+		var sl Silence
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&sl); err != nil {
+			reply(w, http.StatusBadRequest, apiError{err.Error()})
+			return
+		}
+		sl, err := s.Add(sl)
+		if err != nil {
+			reply(w, http.StatusBadRequest, apiError{err.Error()})
+			return
+		}
+		reply(w, http.StatusCreated, sl)
+	})
+	mux.HandleFunc("POST /silences/{id}/expire", func(w http.ResponseWriter, r *http.Request) {
+		sl, err := s.Expire(r.PathValue("id"), time.Now())
+		switch {
+		case errors.Is(err, ErrNotFound):
+			reply(w, http.StatusNotFound, apiError{err.Error()})
+		case err != nil:
+			reply(w, http.StatusConflict, apiError{err.Error()})
+		default:
+			reply(w, http.StatusOK, sl)
+		}
+	})
+	return mux
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func reply(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Client calls Handler on a running daemon through its unix socket.
+type Client struct {
+	client *http.Client
+}
+
+// NewClient returns a client for the daemon listening on socket.
+func NewClient(socket string) *Client {
+	return &Client{client: &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}},
+	}}
+}
+
+// Add creates a silence and returns it with its ID.
+func (c *Client) Add(ctx context.Context, sl Silence) (Silence, error) {
+	var out Silence
+	err := c.do(ctx, http.MethodPost, "/silences", sl, &out)
+	return out, err
+}
+
+// List returns the daemon's silences, including ended ones with all.
+func (c *Client) List(ctx context.Context, all bool) ([]Silence, error) {
+	path := "/silences"
+	if all {
+		path += "?all=1"
+	}
+	var out []Silence
+	err := c.do(ctx, http.MethodGet, path, nil, &out)
+	return out, err
+}
+
+// Expire ends the silence with the given ID now.
+func (c *Client) Expire(ctx context.Context, id string) (Silence, error) {
+	var out Silence
+	err := c.do(ctx, http.MethodPost, "/silences/"+url.PathEscape(id)+"/expire", nil, &out)
+	return out, err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://ssh-noti"+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w (is the daemon running?)", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e apiError
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return fmt.Errorf("daemon returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
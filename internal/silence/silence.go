@@ -0,0 +1,271 @@
+// Package silence keeps a persisted list of time-bounded silences that
+// suppress notifications for matching events.
+package silence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+// Silence suppresses matching events between Start and End. Every set
+// matcher must hold: IP is an address or CIDR prefix, Users and Hosts are
+// glob patterns and Types lists event types.
+type Silence struct {
+	ID        string    `json:"id"`
+	IP        string    `json:"ip,omitempty"`
+	Users     []string  `json:"users,omitempty"`
+	Hosts     []string  `json:"hosts,omitempty"`
+	Types     []string  `json:"types,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+	// Matched counts suppressed events; it is saved by Store.Save and with
+	// every change to the store.
+	Matched int `json:"matched,omitempty"`
+}
+
+// ErrNotFound is returned for an unknown silence ID.
+var ErrNotFound = errors.New("silence: not found")
+
+// Active reports whether s is in effect at t.
+func (s *Silence) Active(t time.Time) bool {
+	return !t.Before(s.Start) && t.Before(s.End)
+}
+
+// Label names s in digests: its ID and comment.
+func (s *Silence) Label() string {
+	if s.Comment == "" {
+		return s.ID
+	}
+	return s.ID + " " + s.Comment
+}
+
+func (s *Silence) matches(ev *model.Event) bool {
+	if s.IP != "" && !matchIP(s.IP, ev.SourceIP) {
+		return false
+	}
+	if len(s.Types) > 0 && !slices.Contains(s.Types, ev.Type) {
+		return false
+	}
+	return matchGlob(s.Users, ev.Username) && matchGlob(s.Hosts, ev.Hostname)
+}
+
+func matchIP(want, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	if p, err := netip.ParsePrefix(want); err == nil {
+		return p.Contains(addr.Unmap())
+	}
+	w, err := netip.ParseAddr(want)
+	return err == nil && w.Unmap() == addr.Unmap()
+}
+
+// matchGlob is true for no patterns or when one of them matches s.
+func matchGlob(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Silence) validate() error {
+	if s.IP == "" && len(s.Users) == 0 && len(s.Hosts) == 0 && len(s.Types) == 0 {
+		return errors.New("silence: at least one of ip, users, hosts or types is required")
+	}
+	if s.IP != "" {
+		if _, err := netip.ParsePrefix(s.IP); err != nil {
+			if _, err := netip.ParseAddr(s.IP); err != nil {
+				return fmt.Errorf("silence: invalid ip %q", s.IP)
+			}
+		}
+	}
+	for _, p := range append(append([]string(nil), s.Users...), s.Hosts...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("silence: pattern %q: %w", p, err)
+		}
+	}
+	if !s.End.After(s.Start) {
+		return errors.New("silence: end must be after start")
+	}
+	return nil
+}
+
+// Store is a list of silences persisted as JSON in one file.
+type Store struct {
+	path string
+
+	mu    sync.Mutex
+	items []Silence
+	dirty bool // match counts changed since the last save
+}
+
+// Open loads the store at path; a missing file is an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.items); err != nil {
+		return nil, fmt.Errorf("silence: %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Add validates sl, assigns an ID and a start time if unset, and saves it.
+func (s *Store) Add(sl Silence) (Silence, error) {
+	sl.Matched = 0
+	if sl.Start.IsZero() {
+		sl.Start = time.Now()
+	}
+	if err := sl.validate(); err != nil {
+		return sl, err
+	}
+	var id [6]byte
+	rand.Read(id[:])
+	sl.ID = hex.EncodeToString(id[:])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = append(s.items, sl)
+	return sl, s.saveLocked()
+}
+
+// Expire ends the silence with the given ID at t.
+func (s *Store) Expire(id string, t time.Time) (Silence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.items {
+		sl := &s.items[i]
+		if sl.ID != id {
+			continue
+		}
+		if !t.Before(sl.End) {
+			return *sl, fmt.Errorf("silence: %s already ended at %s", id, sl.End.Format(time.RFC3339))
+		}
+		sl.End = t
+		if sl.Start.After(t) {
+			sl.Start = t
+		}
+		out := *sl
+		return out, s.saveLocked()
+	}
+	return Silence{}, ErrNotFound
+}
+
+// Match returns the first silence active at t that matches ev and counts
+// the event against it.
+func (s *Store) Match(ev *model.Event, t time.Time) *Silence {
+	sl := s.Find(ev, t)
+	if sl != nil {
+		s.Count(sl.ID)
+		sl.Matched++
+	}
+	return sl
+}
+
+// Find returns the first silence active at t that matches ev without
+// counting the event.
+func (s *Store) Find(ev *model.Event, t time.Time) *Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.items {
+		if sl := &s.items[i]; sl.Active(t) && sl.matches(ev) {
+			out := *sl
+			return &out
+		}
+	}
+	return nil
+}
+
+// Count counts a suppressed event against the silence with the given ID.
+func (s *Store) Count(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.items {
+		if s.items[i].ID == id {
+			s.items[i].Matched++
+			s.dirty = true
+			return
+		}
+	}
+}
+
+// List returns all silences that have not ended before t; with the zero
+// time it returns every kept silence.
+func (s *Store) List(t time.Time) []Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Silence
+	for _, sl := range s.items {
+		if t.Before(sl.End) {
+			out = append(out, sl)
+		}
+	}
+	return out
+}
+
+// Save writes the store if match counts changed since it was last saved.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// Retention is how long ended silences are kept, so that digests can still
+// attribute events to them.
+const Retention = 7 * 24 * time.Hour
+
+// saveLocked drops silences that ended more than Retention ago and writes
+// the file atomically.
+func (s *Store) saveLocked() error {
+	cutoff := time.Now().Add(-Retention)
+	kept := s.items[:0]
+	for _, sl := range s.items {
+		if sl.End.After(cutoff) {
+			kept = append(kept, sl)
+		}
+	}
+	s.items = kept
+	b, err := json.MarshalIndent(s.items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,125 @@
+package silence
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+func TestStore_MatchAndPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "silences.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err := s.Add(Silence{IP: "203.0.113.0/24", Start: now, End: now.Add(time.Hour), CreatedBy: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(Silence{IP: "not-an-ip", End: now.Add(time.Hour)}); err == nil {
+		t.Fatal("expected invalid ip error")
+	}
+
+	s, _ = Open(path)
+	ev := &model.Event{SourceIP: "203.0.113.77"}
+	if sl := s.Match(ev, now); sl == nil || sl.CreatedBy != "alice" {
+		t.Fatalf("expected match after reload, got %+v", sl)
+	}
+	if s.Match(ev, now.Add(2*time.Hour)) != nil {
+		t.Fatal("silence should have ended")
+	}
+	if s.Match(&model.Event{SourceIP: "198.51.100.1"}, now) != nil {
+		t.Fatal("IP outside the prefix matched")
+	}
+	if s.Match(&model.Event{SourceIP: "::ffff:203.0.113.5"}, now) == nil {
+		t.Fatal("IPv4-mapped address should match")
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s, _ = Open(path)
+	if l := s.List(time.Time{}); len(l) != 1 || l[0].Matched != 2 {
+		t.Fatalf("match count not saved: %+v", l)
+	}
+}
+
+func TestStore_MatchersAndExpire(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "silences.json"))
+	now := time.Now()
+	sl, err := s.Add(Silence{Users: []string{"deploy-*"}, Hosts: []string{"web*"}, Types: []string{"login_success"}, Start: now, End: now.Add(time.Hour), Comment: "migration"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(Silence{End: now.Add(time.Hour)}); err == nil {
+		t.Fatal("a silence without matchers should be rejected")
+	}
+	ev := &model.Event{Type: "login_success", Username: "deploy-ci", Hostname: "web2"}
+	if s.Match(ev, now) == nil {
+		t.Fatal("expected match")
+	}
+	for _, other := range []model.Event{
+		{Type: "login_failure", Username: "deploy-ci", Hostname: "web2"},
+		{Type: "login_success", Username: "root", Hostname: "web2"},
+		{Type: "login_success", Username: "deploy-ci", Hostname: "db1"},
+	} {
+		if s.Match(&other, now) != nil {
+			t.Fatalf("%+v should not match", other)
+		}
+	}
+	if got := s.List(now)[0]; got.Matched != 1 || got.Label() != sl.ID+" migration" {
+		t.Fatalf("got %+v", got)
+	}
+	if _, err := s.Expire(sl.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if s.Match(ev, now) != nil || len(s.List(now)) != 0 || len(s.List(time.Time{})) != 1 {
+		t.Fatal("expired silence should end but stay listed with all")
+	}
+	if _, err := s.Expire(sl.ID, now); err == nil {
+		t.Fatal("expiring an ended silence should fail")
+	}
+	if _, err := s.Expire("nope", now); err != ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestClient_OverUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(filepath.Join(dir, "silences.json"))
+	ln, err := net.Listen("unix", filepath.Join(dir, "s.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: Handler(s)}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	c := NewClient(filepath.Join(dir, "s.sock"))
+	ctx := context.Background()
+	sl, err := c.Add(ctx, Silence{IP: "203.0.113.0/24", End: time.Now().Add(time.Hour), CreatedBy: "alice"})
+	if err != nil || sl.ID == "" {
+		t.Fatalf("add: %+v %v", sl, err)
+	}
+	if _, err := c.Add(ctx, Silence{IP: "bogus", End: time.Now().Add(time.Hour)}); err == nil || !strings.Contains(err.Error(), "invalid ip") {
+		t.Fatalf("expected the daemon's validation error, got %v", err)
+	}
+	list, err := c.List(ctx, false)
+	if err != nil || len(list) != 1 || list[0].CreatedBy != "alice" {
+		t.Fatalf("list: %+v %v", list, err)
+	}
+	if _, err := c.Expire(ctx, sl.ID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := c.List(ctx, false); len(list) != 0 {
+		t.Fatalf("expired silence still listed: %+v", list)
+	}
+	if _, err := c.Expire(ctx, "nope"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("got %v", err)
+	}
+}
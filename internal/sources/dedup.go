@@ -0,0 +1,78 @@
+package sources
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/parser"
+)
+
+const (
+	defaultDedupWindow = 10 * time.Second
+	// Journal timestamps carry microseconds while syslog files may only
+	// keep seconds or the rsyslog receive time; allow this much skew.
+	dedupSkew = 2 * time.Second
+)
+
+type seenRecord struct {
+	source  int
+	ts      time.Time
+	arrived time.Time
+	matched bool
+}
+
+// recordDeduper drops a record when another source already delivered the
+// same content from the same PID with a timestamp within dedupSkew. Each
+// delivery can absorb only one twin, so identical lines repeated by sshd
+// (several "Failed password" from one connection) are all kept.
+type recordDeduper struct {
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[string][]*seenRecord
+}
+
+func newRecordDeduper(window time.Duration) *recordDeduper {
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	return &recordDeduper{window: window, seen: make(map[string][]*seenRecord)}
+}
+
+// admit reports whether r from source idx should be forwarded.
+func (d *recordDeduper) admit(idx int, r parser.RawRecord, now time.Time) bool {
+	key := fingerprint(r)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, list := range d.seen {
+		kept := list[:0]
+		for _, s := range list {
+			if now.Sub(s.arrived) < d.window {
+				kept = append(kept, s)
+			}
+		}
+		if len(kept) == 0 {
+			delete(d.seen, k)
+		} else {
+			d.seen[k] = kept
+		}
+	}
+	for _, s := range d.seen[key] {
+		if s.source == idx || s.matched {
+			continue
+		}
+		if skew := r.Timestamp.Sub(s.ts); skew <= dedupSkew && skew >= -dedupSkew {
+			s.matched = true
+			return false
+		}
+	}
+	d.seen[key] = append(d.seen[key], &seenRecord{source: idx, ts: r.Timestamp, arrived: now})
+	return true
+}
+
+func fingerprint(r parser.RawRecord) string {
+	if ev := r.Event; ev != nil {
+		return fmt.Sprintf("ev|%s|%s|%s|%d|%s", ev.Type, ev.Username, ev.SourceIP, ev.Port, ev.Method)
+	}
+	return fmt.Sprintf("%d|%s", r.PID, r.Line)
+}
@@ -0,0 +1,46 @@
+package sources
+
+import (
+	"testing"
+	"time"
+
+	"ssh-noty/internal/parser"
+)
+
+func TestRecordDeduper_CrossSource(t *testing.T) {
+	d := newRecordDeduper(10 * time.Second)
+	now := time.Now()
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	line := "Failed password for root from 198.51.100.1 port 22 ssh2"
+	journal := parser.RawRecord{Line: line, PID: 42, Timestamp: ts.Add(123456 * time.Microsecond)}
+	file := parser.RawRecord{Line: line, PID: 42, Timestamp: ts}
+
+	if !d.admit(0, journal, now) {
+		t.Fatal("first copy should pass")
+	}
+	// The same attempt repeated by sshd on the same source is a new event.
+	if !d.admit(0, journal, now) {
+		t.Fatal("repeat from the same source should pass")
+	}
+	if d.admit(1, file, now) {
+		t.Fatal("twin from another source should be dropped")
+	}
+	if d.admit(1, file, now) {
+		t.Fatal("second twin should match the second journal copy")
+	}
+	if !d.admit(1, file, now) {
+		t.Fatal("a third file copy has no journal twin left and should pass")
+	}
+
+	other := parser.RawRecord{Line: line, PID: 43, Timestamp: ts}
+	if !d.admit(1, other, now) {
+		t.Fatal("different PID should pass")
+	}
+	late := parser.RawRecord{Line: line, PID: 42, Timestamp: ts.Add(time.Minute)}
+	if !d.admit(1, late, now) {
+		t.Fatal("timestamp outside skew should pass")
+	}
+	if !d.admit(1, file, now.Add(11*time.Second)) {
+		t.Fatal("records should expire after the window")
+	}
+}
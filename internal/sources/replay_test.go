@@ -0,0 +1,59 @@
+package sources
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const replayLog = `2024-01-01T10:00:00+00:00 web1 sshd[1]: Failed password for root from 198.51.100.1 port 22 ssh2
+2024-01-01T10:00:00+00:00 web1 sudo[2]: alice : TTY=pts/0 ; COMMAND=/bin/true
+2024-01-01T10:00:01+00:00 web1 sshd[3]: Accepted publickey for alice from 203.0.113.5 port 5000 ssh2
+`
+
+func TestReplay_GzipFile(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(replayLog))
+	zw.Close()
+	path := filepath.Join(t.TempDir(), "auth.log.1.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ch, err := (&ReplaySource{Path: path}).Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for rec := range ch {
+		if rec.Hostname != "web1" || rec.Timestamp.IsZero() {
+			t.Fatalf("expected syslog header fields: %+v", rec)
+		}
+		lines = append(lines, rec.Line)
+	}
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "Failed password") || !strings.HasPrefix(lines[1], "Accepted publickey") {
+		t.Fatalf("unexpected replayed lines: %q", lines)
+	}
+}
+
+func TestReplay_StdinPaced(t *testing.T) {
+	src := &ReplaySource{Path: "-", Speed: 20, Stdin: strings.NewReader(replayLog)}
+	start := time.Now()
+	ch, err := src.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	// One second of log time at 20x takes about 50ms.
+	if el := time.Since(start); n != 2 || el < 40*time.Millisecond || el > time.Second {
+		t.Fatalf("got %d records in %v", n, el)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"errors"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"ssh-noty/internal/config"
@@ -19,6 +21,13 @@ type Source interface {
 	Name() string
 }
 
+// Backfiller is implemented by sources that can re-read history, used by
+// batch mode. The returned channel is closed once all history is read.
+type Backfiller interface {
+	Backfill(ctx context.Context, since time.Time) (<-chan parser.RawRecord, error)
+	Name() string
+}
+
 func SelectSource(ctx context.Context, cfg *config.Config) (Source, error) {
 	prefer := cfg.Sources.Prefer
 	hasJournal := false
@@ -32,6 +41,7 @@ func SelectSource(ctx context.Context, cfg *config.Config) (Source, error) {
 		paths = []string{"/var/log/auth.log", "/var/log/secure", "/var/log/messages"}
 	}
 	fileSrc := &FileFollower{Paths: paths}
+	utmpSrc := &UtmpFollower{Paths: cfg.Sources.UtmpPaths}
 
 	switch prefer {
 	case "journald":
@@ -41,30 +51,66 @@ func SelectSource(ctx context.Context, cfg *config.Config) (Source, error) {
 		return &JournalctlFollower{Units: cfg.Sources.SystemdUnits}, nil
 	case "file":
 		return fileSrc, nil
-	case "auto":
-		if hasJournal {
-			// Run both to be safe; Multi will merge
-			return &MultiSource{Sources: []Source{&JournalctlFollower{Units: cfg.Sources.SystemdUnits}, fileSrc}}, nil
-		}
-		return fileSrc, nil
+	case "utmp":
+		return utmpSrc, nil
+	case "audit":
+		return &AuditFollower{Path: cfg.Sources.AuditLog}, nil
 	default:
-		// unknown prefer value, default to auto behavior
+		// auto, and unknown prefer values
 		if hasJournal {
-			return &MultiSource{Sources: []Source{&JournalctlFollower{Units: cfg.Sources.SystemdUnits}, fileSrc}}, nil
+			// Run both to be safe; Multi will merge. Journald-only hosts
+			// have no log file, which is not a failure of the file source.
+			multi := &MultiSource{Sources: []Source{&JournalctlFollower{Units: cfg.Sources.SystemdUnits}}}
+			if anyExists(paths) {
+				multi.Sources = append(multi.Sources, fileSrc)
+			}
+			return multi, nil
+		}
+		if !anyExists(paths) {
+			return utmpSrc, nil
 		}
 		return fileSrc, nil
 	}
 }
 
-// JournalctlFollower streams journal entries for sshd units and emits RawRecord lines from MESSAGE
+// SelectBackfill returns the source batch mode reads history from.
+func SelectBackfill(cfg *config.Config) (Backfiller, error) {
+	if cfg.Sources.Prefer == "audit" {
+		return &AuditFollower{Path: cfg.Sources.AuditLog}, nil
+	}
+	if !anyExists(cfg.Sources.UtmpPaths) {
+		return nil, errors.New("no wtmp/btmp files found")
+	}
+	return &UtmpFollower{Paths: cfg.Sources.UtmpPaths}, nil
+}
+
+func anyExists(paths []string) bool {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// JournalctlFollower streams journal entries for sshd units and emits RawRecord lines from MESSAGE.
+// When restarted it resumes after the last entry it delivered.
 type JournalctlFollower struct {
 	Units []string
+
+	mu     sync.Mutex
+	cursor string
 }
 
 func (j *JournalctlFollower) Name() string { return "journalctl" }
 
 func (j *JournalctlFollower) Start(ctx context.Context) (<-chan parser.RawRecord, error) {
 	args := []string{"-o", "json", "-f"}
+	j.mu.Lock()
+	if j.cursor != "" {
+		args = append(args, "--after-cursor", j.cursor)
+	}
+	j.mu.Unlock()
 	for _, u := range j.Units {
 		args = append(args, "-u", u)
 	}
@@ -95,13 +141,23 @@ func (j *JournalctlFollower) Start(ctx context.Context) (<-chan parser.RawRecord
 			msg, _ := m["MESSAGE"].(string)
 			ts := time.Now()
 			if v, ok := m["__REALTIME_TIMESTAMP"].(string); ok {
-				// not converting microseconds here; keep now
-				_ = v
+				if us, err := strconv.ParseInt(v, 10, 64); err == nil {
+					ts = time.UnixMicro(us)
+				}
+			}
+			pid := 0
+			if v, ok := m["_PID"].(string); ok {
+				pid, _ = strconv.Atoi(v)
+			}
+			if c, ok := m["__CURSOR"].(string); ok {
+				j.mu.Lock()
+				j.cursor = c
+				j.mu.Unlock()
 			}
 			if strings.TrimSpace(msg) == "" {
 				continue
 			}
-			ch <- parser.RawRecord{Line: msg, Timestamp: ts, Hostname: hostname}
+			ch <- parser.RawRecord{Line: msg, Timestamp: ts, Hostname: hostname, PID: pid}
 		}
 	}()
 	return ch, nil
@@ -115,24 +171,24 @@ type FileFollower struct {
 func (f *FileFollower) Name() string { return "file" }
 
 func (f *FileFollower) Start(ctx context.Context) (<-chan parser.RawRecord, error) {
+	// naive: follow only the first existing file
+	var chosen string
+	for _, p := range f.Paths {
+		if _, err := os.Stat(p); err == nil {
+			chosen = p
+			break
+		}
+	}
+	if chosen == "" {
+		return nil, errors.New("no log file found in " + strings.Join(f.Paths, ", "))
+	}
+	file, err := os.Open(chosen)
+	if err != nil {
+		return nil, err
+	}
 	ch := make(chan parser.RawRecord)
 	go func() {
 		defer close(ch)
-		// naive: follow only the first existing file
-		var chosen string
-		for _, p := range f.Paths {
-			if _, err := os.Stat(p); err == nil {
-				chosen = p
-				break
-			}
-		}
-		if chosen == "" {
-			return
-		}
-		file, err := os.Open(chosen)
-		if err != nil {
-			return
-		}
 		defer file.Close()
 		// seek to end
 		file.Seek(0, 2)
@@ -145,7 +201,7 @@ func (f *FileFollower) Start(ctx context.Context) (<-chan parser.RawRecord, erro
 			default:
 				line, err := reader.ReadString('\n')
 				if len(line) > 0 {
-					ch <- parser.RawRecord{Line: strings.TrimRight(line, "\r\n"), Timestamp: time.Now(), Hostname: hostname}
+					ch <- fileRecord(strings.TrimRight(line, "\r\n"), hostname)
 				}
 				if err != nil {
 					time.Sleep(500 * time.Millisecond)
@@ -156,9 +212,24 @@ func (f *FileFollower) Start(ctx context.Context) (<-chan parser.RawRecord, erro
 	return ch, nil
 }
 
+// fileRecord strips the syslog header so the parser sees the bare sshd
+// message, keeping the logged host and PID when present. Live records are
+// stamped with the time they were read; only replays use logged times.
+func fileRecord(line, hostname string) parser.RawRecord {
+	if sl, ok := parser.SplitSyslog(line, time.Now()); ok {
+		return parser.RawRecord{Line: sl.Message, Timestamp: time.Now(), Hostname: sl.Host, PID: sl.PID}
+	}
+	return parser.RawRecord{Line: line, Timestamp: time.Now(), Hostname: hostname}
+}
+
 // MultiSource merges events from multiple sources into a single channel.
+// The same log line seen by more than one source (e.g. journald and the
+// syslog file it feeds) is forwarded once; see recordDeduper.
 type MultiSource struct {
 	Sources []Source
+	// DedupWindow bounds how long a record waits for its twin from another
+	// source. Zero uses defaultDedupWindow.
+	DedupWindow time.Duration
 }
 
 func (m *MultiSource) Name() string {
@@ -171,15 +242,19 @@ func (m *MultiSource) Name() string {
 
 func (m *MultiSource) Start(ctx context.Context) (<-chan parser.RawRecord, error) {
 	out := make(chan parser.RawRecord, 256)
+	dedup := newRecordDeduper(m.DedupWindow)
+	var wg sync.WaitGroup
 	// Start each source and fan-in
-	for _, s := range m.Sources {
+	for i, s := range m.Sources {
 		src := s
 		recs, err := src.Start(ctx)
 		if err != nil {
 			// if one source fails, continue with others
 			continue
 		}
-		go func(c <-chan parser.RawRecord) {
+		wg.Add(1)
+		go func(idx int, c <-chan parser.RawRecord) {
+			defer wg.Done()
 			for {
 				select {
 				case <-ctx.Done():
@@ -188,14 +263,21 @@ func (m *MultiSource) Start(ctx context.Context) (<-chan parser.RawRecord, error
 					if !ok {
 						return
 					}
-					out <- r
+					if !dedup.admit(idx, r, time.Now()) {
+						continue
+					}
+					select {
+					case out <- r:
+					case <-ctx.Done():
+						return
+					}
 				}
 			}
-		}(recs)
+		}(i, recs)
 	}
-	// Close out when context is cancelled
+	// Close out once no forwarder can send on it.
 	go func() {
-		<-ctx.Done()
+		wg.Wait()
 		close(out)
 	}()
 	return out, nil
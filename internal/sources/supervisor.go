@@ -0,0 +1,165 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/parser"
+)
+
+type State string
+
+const (
+	StateStarting   State = "starting"
+	StateRunning    State = "running"
+	StateRestarting State = "restarting"
+	StateFailed     State = "failed"
+)
+
+// Status is a point-in-time view of a supervised source.
+type Status struct {
+	Name       string    `json:"name"`
+	State      State     `json:"state"`
+	Since      time.Time `json:"since"`
+	Restarts   int       `json:"restarts"`
+	LastError  string    `json:"last_error,omitempty"`
+	LastRecord time.Time `json:"last_record,omitempty"`
+}
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = 5 * time.Minute
+	// A source that stays up this long has its backoff reset.
+	stableAfter = time.Minute
+)
+
+// Supervisor keeps a source running: when Start fails the source is marked
+// failed, when its channel closes it is marked restarting, and in both cases
+// it is started again after an exponential backoff. Its own channel stays
+// open until ctx is done.
+type Supervisor struct {
+	Source     Source
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnChange is called on every state transition with the new status.
+	OnChange func(Status)
+
+	mu     sync.Mutex
+	status Status
+}
+
+func (s *Supervisor) Name() string { return s.Source.Name() }
+
+// Status returns the current state of the supervised source.
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.status
+	st.Name = s.Source.Name()
+	return st
+}
+
+func (s *Supervisor) Start(ctx context.Context) (<-chan parser.RawRecord, error) {
+	minB, maxB := s.MinBackoff, s.MaxBackoff
+	if minB <= 0 {
+		minB = defaultMinBackoff
+	}
+	if maxB < minB {
+		maxB = defaultMaxBackoff
+	}
+	s.transition(StateStarting, nil, false)
+	out := make(chan parser.RawRecord, 200)
+	go func() {
+		defer close(out)
+		backoff := minB
+		for attempt := 0; ; attempt++ {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				if backoff > maxB {
+					backoff = maxB
+				}
+			}
+			recs, err := s.Source.Start(ctx)
+			if err != nil {
+				s.transition(StateFailed, err, attempt > 0)
+				continue
+			}
+			started := time.Now()
+			s.transition(StateRunning, nil, attempt > 0)
+			for r := range recs {
+				s.mu.Lock()
+				s.status.LastRecord = time.Now()
+				s.mu.Unlock()
+				select {
+				case out <- r:
+				case <-ctx.Done():
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if time.Since(started) >= stableAfter {
+				backoff = minB
+			}
+			s.transition(StateRestarting, errSourceExited, false)
+		}
+	}()
+	return out, nil
+}
+
+var errSourceExited = errors.New("source exited")
+
+func (s *Supervisor) transition(state State, err error, restart bool) {
+	s.mu.Lock()
+	if restart {
+		s.status.Restarts++
+	}
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	changed := s.status.State != state
+	s.status.State = state
+	if changed {
+		s.status.Since = time.Now()
+	}
+	st := s.status
+	st.Name = s.Source.Name()
+	s.mu.Unlock()
+	if changed && s.OnChange != nil {
+		s.OnChange(st)
+	}
+}
+
+// Supervise wraps src in a Supervisor. For a MultiSource each member is
+// supervised separately so one failing source does not affect the others.
+func Supervise(src Source, onChange func(Status)) Source {
+	if m, ok := src.(*MultiSource); ok {
+		for i, child := range m.Sources {
+			m.Sources[i] = &Supervisor{Source: child, OnChange: onChange}
+		}
+		return m
+	}
+	return &Supervisor{Source: src, OnChange: onChange}
+}
+
+// Health returns the status of every supervised source within src.
+func Health(src Source) []Status {
+	switch s := src.(type) {
+	case *Supervisor:
+		return []Status{s.Status()}
+	case *MultiSource:
+		var out []Status
+		for _, child := range s.Sources {
+			out = append(out, Health(child)...)
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/parser"
+)
+
+// flakySource fails its first Start, then emits one record per run and exits.
+type flakySource struct {
+	mu     sync.Mutex
+	starts int
+}
+
+func (f *flakySource) Name() string { return "flaky" }
+
+func (f *flakySource) Start(ctx context.Context) (<-chan parser.RawRecord, error) {
+	f.mu.Lock()
+	f.starts++
+	n := f.starts
+	f.mu.Unlock()
+	if n == 1 {
+		return nil, errors.New("not yet")
+	}
+	ch := make(chan parser.RawRecord, 1)
+	ch <- parser.RawRecord{Line: "run"}
+	close(ch)
+	return ch, nil
+}
+
+func TestSupervisor_RestartsWithBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var mu sync.Mutex
+	var states []State
+	sup := &Supervisor{
+		Source:     &flakySource{},
+		MinBackoff: 5 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+		OnChange: func(st Status) {
+			mu.Lock()
+			states = append(states, st.State)
+			mu.Unlock()
+		},
+	}
+	out, err := sup.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-out:
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for records from restarted source")
+		}
+	}
+	st := sup.Status()
+	if st.Restarts < 3 || st.LastRecord.IsZero() || st.Name != "flaky" {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []State{StateStarting, StateFailed, StateRunning, StateRestarting, StateRunning}
+	for i, s := range want {
+		if i >= len(states) || states[i] != s {
+			t.Fatalf("transitions = %v, want prefix %v", states, want)
+		}
+	}
+}
+
+// burstSource emits n distinct records, then waits for cancellation.
+type burstSource struct{ n int }
+
+func (b *burstSource) Name() string { return "burst" }
+
+func (b *burstSource) Start(ctx context.Context) (<-chan parser.RawRecord, error) {
+	ch := make(chan parser.RawRecord)
+	go func() {
+		defer close(ch)
+		for i := 0; i < b.n; i++ {
+			select {
+			case ch <- parser.RawRecord{Line: fmt.Sprint("line ", i)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+	return ch, nil
+}
+
+func TestMultiSource_CancelWhileForwarding(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := (&MultiSource{Sources: []Source{&burstSource{n: 300}, &burstSource{n: 300}}}).Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(out) < cap(out) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond) // forwarders are blocked sending
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		for range out {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("out was not closed after cancellation")
+	}
+}
+
+func TestSupervise_MultiSourceMembers(t *testing.T) {
+	m := &MultiSource{Sources: []Source{&FileFollower{Paths: []string{"/nonexistent"}}, &flakySource{}}}
+	src := Supervise(m, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := src.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	hs := Health(src)
+	if len(hs) != 2 || hs[0].Name != "file" || hs[0].State != StateFailed {
+		t.Fatalf("unexpected health: %+v", hs)
+	}
+}
@@ -0,0 +1,75 @@
+package sources
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// tailLines calls fn for each complete line of path. With follow set it
+// starts at the end of the file and polls for appends, reopening after
+// rotation or truncation; idle is called whenever no new data is available.
+// It returns when fn returns false, ctx is done, or (without follow) at EOF.
+func tailLines(ctx context.Context, path string, follow bool, poll time.Duration, fn func(string) bool, idle func()) {
+	if poll <= 0 {
+		poll = 500 * time.Millisecond
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { f.Close() }()
+	var offset int64
+	if follow {
+		offset, _ = f.Seek(0, io.SeekEnd)
+	}
+	reader := bufio.NewReader(f)
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		if err == nil {
+			if !fn(strings.TrimRight(partial+line, "\r\n")) {
+				return
+			}
+			partial = ""
+			continue
+		}
+		partial += line
+		if !follow {
+			if partial != "" {
+				fn(strings.TrimRight(partial, "\r\n"))
+			}
+			return
+		}
+		if idle != nil {
+			idle()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(poll):
+		}
+		cur, _ := f.Stat()
+		st, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if cur != nil && !os.SameFile(cur, st) {
+			nf, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			f.Close()
+			f, offset, partial = nf, 0, ""
+			reader.Reset(f)
+		} else if st.Size() < offset {
+			offset, _ = f.Seek(0, io.SeekStart)
+			partial = ""
+			reader.Reset(f)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/parser"
+)
+
+// Linux utmp(5) layout as written by glibc on 64-bit hosts.
+const (
+	utmpRecordSize = 384
+
+	utLoginProcess = 6
+	utUserProcess  = 7
+	utDeadProcess  = 8
+)
+
+type utmpRecord struct {
+	Type int16
+	PID  int32
+	Line string
+	User string
+	Host string
+	Addr string
+	Time time.Time
+}
+
+func decodeUtmp(b []byte) utmpRecord {
+	le := binary.LittleEndian
+	r := utmpRecord{
+		Type: int16(le.Uint16(b[0:2])),
+		PID:  int32(le.Uint32(b[4:8])),
+		Line: cstring(b[8:40]),
+		User: cstring(b[44:76]),
+		Host: cstring(b[76:332]),
+		Time: time.Unix(int64(int32(le.Uint32(b[340:344]))), int64(int32(le.Uint32(b[344:348])))*1000),
+	}
+	addr := b[348:364]
+	switch {
+	case isZero(addr):
+	case isZero(addr[4:]):
+		r.Addr = net.IP(addr[:4]).String()
+	default:
+		r.Addr = net.IP(addr).String()
+	}
+	return r
+}
+
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// utmpDecoder turns utmp records into events. wtmp yields login_success and
+// session_closed; btmp (failures) yields login_failure. DEAD_PROCESS entries
+// carry no user, so open sessions are remembered by tty line.
+type utmpDecoder struct {
+	failures bool
+	sessions map[string]utmpRecord
+}
+
+func newUtmpDecoder(path string) *utmpDecoder {
+	return &utmpDecoder{
+		failures: strings.Contains(filepath.Base(path), "btmp"),
+		sessions: make(map[string]utmpRecord),
+	}
+}
+
+func (d *utmpDecoder) event(r utmpRecord) (*model.Event, bool) {
+	ip := r.Addr
+	if ip == "" {
+		ip = r.Host
+	}
+	ssh := strings.HasPrefix(r.Line, "ssh") || (strings.HasPrefix(r.Line, "pts/") && r.Host != "")
+	if d.failures {
+		if !ssh || (r.Type != utLoginProcess && r.Type != utUserProcess) {
+			return nil, false
+		}
+		return &model.Event{Type: "login_failure", Username: r.User, SourceIP: ip, Timestamp: r.Time}, true
+	}
+	switch r.Type {
+	case utUserProcess:
+		if !ssh {
+			return nil, false
+		}
+		d.sessions[r.Line] = r
+		return &model.Event{Type: "login_success", Username: r.User, SourceIP: ip, Timestamp: r.Time}, true
+	case utDeadProcess:
+		open, ok := d.sessions[r.Line]
+		if !ok {
+			return nil, false
+		}
+		delete(d.sessions, r.Line)
+		ip = open.Addr
+		if ip == "" {
+			ip = open.Host
+		}
+		return &model.Event{Type: "session_closed", Username: open.User, SourceIP: ip, Timestamp: r.Time}, true
+	}
+	return nil, false
+}
+
+// UtmpFollower reads binary wtmp/btmp files and follows appends. It is useful
+// on hosts that keep no text auth log.
+type UtmpFollower struct {
+	Paths []string
+	Poll  time.Duration
+}
+
+func (u *UtmpFollower) Name() string { return "utmp" }
+
+func (u *UtmpFollower) Start(ctx context.Context) (<-chan parser.RawRecord, error) {
+	return u.run(ctx, true, time.Time{}), nil
+}
+
+// Backfill emits records newer than since from the beginning of each file and
+// closes the channel once every file has been read to its end.
+func (u *UtmpFollower) Backfill(ctx context.Context, since time.Time) (<-chan parser.RawRecord, error) {
+	return u.run(ctx, false, since), nil
+}
+
+func (u *UtmpFollower) run(ctx context.Context, follow bool, since time.Time) <-chan parser.RawRecord {
+	ch := make(chan parser.RawRecord, 64)
+	hostname, _ := os.Hostname()
+	var wg sync.WaitGroup
+	for _, p := range u.Paths {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			dec := newUtmpDecoder(path)
+			emit := func(b []byte) bool {
+				ev, ok := dec.event(decodeUtmp(b))
+				if !ok || ev.Timestamp.Before(since) {
+					return true
+				}
+				ev.Hostname = hostname
+				select {
+				case ch <- parser.RawRecord{Timestamp: ev.Timestamp, Hostname: hostname, Event: ev}:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			}
+			u.read(ctx, path, follow, emit)
+		}(p)
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return ch
+}
+
+// read decodes whole records from path. When follow is set it starts at the
+// end of the file and keeps polling, reopening on truncation or rotation.
+func (u *UtmpFollower) read(ctx context.Context, path string, follow bool, emit func([]byte) bool) {
+	poll := u.Poll
+	if poll <= 0 {
+		poll = time.Second
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { f.Close() }()
+	var offset int64
+	if follow {
+		if end, err := f.Seek(0, io.SeekEnd); err == nil {
+			offset = end - end%utmpRecordSize
+			f.Seek(offset, io.SeekStart)
+		}
+	}
+	buf := make([]byte, utmpRecordSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if err == nil {
+			offset += int64(n)
+			if !emit(buf) {
+				return
+			}
+			continue
+		}
+		// Partial record: rewind so it is re-read once complete.
+		if n > 0 {
+			f.Seek(offset, io.SeekStart)
+		}
+		if !follow {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(poll):
+		}
+		cur, _ := f.Stat()
+		st, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if cur != nil && !os.SameFile(cur, st) {
+			nf, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			f.Close()
+			f, offset = nf, 0
+		} else if st.Size() < offset {
+			offset, _ = f.Seek(0, io.SeekStart)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package sources
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func utmpBytes(typ int16, line, user, host, ip string, ts time.Time) []byte {
+	b := make([]byte, utmpRecordSize)
+	le := binary.LittleEndian
+	le.PutUint16(b[0:2], uint16(typ))
+	le.PutUint32(b[4:8], 4242)
+	copy(b[8:40], line)
+	copy(b[44:76], user)
+	copy(b[76:332], host)
+	le.PutUint32(b[340:344], uint32(ts.Unix()))
+	if v4 := net.ParseIP(ip).To4(); v4 != nil {
+		copy(b[348:352], v4)
+	} else if v6 := net.ParseIP(ip); v6 != nil {
+		copy(b[348:364], v6)
+	}
+	return b
+}
+
+func TestUtmp_BackfillWtmpBtmp(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Unix(1700000000, 0)
+	var wtmp []byte
+	wtmp = append(wtmp, utmpBytes(utUserProcess, "tty1", "console", "", "", ts)...)
+	wtmp = append(wtmp, utmpBytes(utUserProcess, "pts/0", "alice", "203.0.113.5", "203.0.113.5", ts)...)
+	wtmp = append(wtmp, utmpBytes(utDeadProcess, "pts/0", "", "", "", ts.Add(time.Minute))...)
+	btmp := utmpBytes(utLoginProcess, "ssh:notty", "root", "2001:db8::1", "2001:db8::1", ts)
+	if err := os.WriteFile(filepath.Join(dir, "wtmp"), wtmp, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "btmp"), btmp, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	u := &UtmpFollower{Paths: []string{filepath.Join(dir, "wtmp"), filepath.Join(dir, "btmp")}}
+	ch, err := u.Backfill(context.Background(), ts.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	for rec := range ch {
+		ev := rec.Event
+		if ev == nil {
+			t.Fatalf("expected structured event: %+v", rec)
+		}
+		got[ev.Type]++
+		switch ev.Type {
+		case "login_success", "session_closed":
+			if ev.Username != "alice" || ev.SourceIP != "203.0.113.5" {
+				t.Fatalf("unexpected wtmp event: %+v", ev)
+			}
+		case "login_failure":
+			if ev.Username != "root" || ev.SourceIP != "2001:db8::1" {
+				t.Fatalf("unexpected btmp event: %+v", ev)
+			}
+		}
+	}
+	if got["login_success"] != 1 || got["session_closed"] != 1 || got["login_failure"] != 1 {
+		t.Fatalf("unexpected counts: %v", got)
+	}
+}
+
+func TestUtmp_FollowAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wtmp")
+	ts := time.Unix(1700000000, 0)
+	old := utmpBytes(utUserProcess, "pts/1", "old", "198.51.100.1", "198.51.100.1", ts)
+	if err := os.WriteFile(path, old, 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	u := &UtmpFollower{Paths: []string{path}, Poll: 10 * time.Millisecond}
+	ch, _ := u.Start(ctx)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rec := utmpBytes(utUserProcess, "pts/2", "bob", "198.51.100.2", "198.51.100.2", ts)
+	// Write in two halves to exercise partial record handling.
+	f.Write(rec[:100])
+	time.Sleep(30 * time.Millisecond)
+	f.Write(rec[100:])
+
+	select {
+	case r := <-ch:
+		if r.Event == nil || r.Event.Username != "bob" || r.Event.Type != "login_success" {
+			t.Fatalf("unexpected record: %+v", r.Event)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for appended record")
+	}
+}
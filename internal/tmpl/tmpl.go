@@ -0,0 +1,129 @@
+// Package tmpl parses and renders user-supplied Go text/templates for
+// notification messages.
+package tmpl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"ssh-noty/internal/model"
+)
+
+// Funcs are available in every template.
+var Funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"rfc3339": func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	"unix":    func(t time.Time) int64 { return t.Unix() },
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+}
+
+// Parse parses text with Funcs. A missing map key, such as an audit field
+// the event does not carry, renders as the empty string.
+func Parse(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(Funcs).Option("missingkey=zero").Parse(text)
+}
+
+// Execute renders t with data.
+func Execute(t *template.Template, data any) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Sample returns a fully populated event of the given type for previews and
+// validation.
+func Sample(eventType string) *model.Event {
+	if eventType == "" || eventType == Default {
+		eventType = "login_success"
+	}
+	return &model.Event{
+		Type:           eventType,
+		Username:       "alice",
+		SourceIP:       "203.0.113.5",
+		Port:           52341,
+		Method:         "publickey",
+		KeyFingerprint: "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8",
+		Timestamp:      time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		Hostname:       "web1",
+		Fields:         map[string]string{},
+	}
+}
+
+// Default is the Set key used for event types without their own template.
+const Default = "default"
+
+// Set holds a notifier's templates keyed by event type. A template read
+// from a .json file must render valid JSON (e.g. Slack blocks or an
+// Adaptive Card body).
+type Set struct {
+	byType map[string]*template.Template
+	json   map[string]bool
+}
+
+// LoadSet parses the template files and validates each by rendering a
+// sample event of its type.
+func LoadSet(files map[string]string) (*Set, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	s := &Set{byType: make(map[string]*template.Template), json: make(map[string]bool)}
+	for typ, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", typ, err)
+		}
+		t, err := Parse(filepath.Base(path), string(b))
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", typ, err)
+		}
+		s.byType[typ] = t
+		s.json[typ] = strings.EqualFold(filepath.Ext(path), ".json")
+		if _, err := s.Render(Sample(typ)); err != nil {
+			return nil, fmt.Errorf("template %s: %w", typ, err)
+		}
+	}
+	return s, nil
+}
+
+// Output is a rendered template. JSON is set for .json templates, whose
+// Text is then valid JSON.
+type Output struct {
+	Text string
+	JSON bool
+}
+
+// Render renders the template for ev's type, falling back to Default. It
+// returns nil when no template applies, including for a nil Set.
+func (s *Set) Render(ev *model.Event) (*Output, error) {
+	if s == nil {
+		return nil, nil
+	}
+	typ := ev.Type
+	t, ok := s.byType[typ]
+	if !ok {
+		typ = Default
+		if t, ok = s.byType[typ]; !ok {
+			return nil, nil
+		}
+	}
+	text, err := Execute(t, ev)
+	if err != nil {
+		return nil, err
+	}
+	if s.json[typ] && !json.Valid([]byte(text)) {
+		return nil, fmt.Errorf("%s did not render valid JSON", t.Name())
+	}
+	return &Output{Text: text, JSON: s.json[typ]}, nil
+}
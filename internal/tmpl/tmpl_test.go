@@ -0,0 +1,78 @@
+package tmpl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ssh-noty/internal/model"
+)
+
+func writeFile(t *testing.T, name, text string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadSet_RenderByTypeAndDefault(t *testing.T) {
+	set, err := LoadSet(map[string]string{
+		"login_failure": writeFile(t, "fail.txt", "{{upper .Username}} failed from {{.SourceIP}}"),
+		Default:         writeFile(t, "default.json", `{"text":{{json .Type}},"at":{{unix .Timestamp}}}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := Sample("login_failure")
+	out, err := set.Render(ev)
+	if err != nil || out.JSON || out.Text != "ALICE failed from 203.0.113.5" {
+		t.Fatalf("unexpected output %+v %v", out, err)
+	}
+	ev.Type = "invalid_user"
+	out, err = set.Render(ev)
+	if err != nil || !out.JSON || out.Text != `{"text":"invalid_user","at":1704103200}` {
+		t.Fatalf("unexpected default output %+v %v", out, err)
+	}
+
+	var none *Set
+	if out, err := none.Render(ev); out != nil || err != nil {
+		t.Fatalf("nil set should render nothing: %+v %v", out, err)
+	}
+	set, _ = LoadSet(map[string]string{"login_success": writeFile(t, "ok.txt", "ok")})
+	if out, _ := set.Render(&model.Event{Type: "login_failure"}); out != nil {
+		t.Fatalf("types without a template should fall through: %+v", out)
+	}
+}
+
+func TestLoadSet_MissingFields(t *testing.T) {
+	set, err := LoadSet(map[string]string{Default: writeFile(t, "audit.txt", "auid={{.Fields.auid}}")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := Sample("login_success")
+	if out, err := set.Render(ev); err != nil || out.Text != "auid=" {
+		t.Fatalf("unexpected output %+v %v", out, err)
+	}
+	ev.Fields["auid"] = "1000"
+	if out, err := set.Render(ev); err != nil || out.Text != "auid=1000" {
+		t.Fatalf("unexpected output %+v %v", out, err)
+	}
+}
+
+func TestLoadSet_Validation(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"missing file": {Default: filepath.Join(t.TempDir(), "nope.txt")},
+		"parse error":  {Default: writeFile(t, "a.txt", "{{.Username")},
+		"bad field":    {Default: writeFile(t, "b.txt", "{{.Nope}}")},
+		"invalid json": {"login_success": writeFile(t, "c.json", `{"user": {{.Username}}}`)},
+	} {
+		if _, err := LoadSet(files); err == nil {
+			t.Fatalf("%s: expected error", name)
+		} else if !strings.HasPrefix(err.Error(), "template ") {
+			t.Fatalf("%s: error should name the template: %v", name, err)
+		}
+	}
+}
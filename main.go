@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -10,12 +12,12 @@ import (
 	"time"
 
 	"ssh-noty/internal/config"
-	"ssh-noty/internal/enrich"
 	"ssh-noty/internal/logging"
 	"ssh-noty/internal/notify"
-	"ssh-noty/internal/parser"
 	"ssh-noty/internal/rules"
+	"ssh-noty/internal/silence"
 	"ssh-noty/internal/sources"
+	"ssh-noty/internal/tmpl"
 )
 
 var (
@@ -24,9 +26,26 @@ var (
 	flagBatch   = flag.Bool("batch", false, "Run batch summary and exit")
 	flagTest    = flag.Bool("test", false, "Run self-check and send a test message")
 	flagVersion = flag.Bool("version", false, "Print version and exit")
+
+	flagReplay      = flag.String("replay", "", "Replay a saved auth/audit log (\"-\" for stdin, .gz supported) through the pipeline and exit")
+	flagReplaySpeed = flag.Float64("replay-speed", 0, "Replay pacing relative to original timestamps (e.g. 60 = one hour per minute); 0 = as fast as possible")
+	flagReplaySend  = flag.Bool("replay-send", false, "Send notifications during --replay (default: log only)")
+
+	flagRenderTemplate = flag.String("render-template", "", "Render a message template file with a sample event and exit")
+	flagSampleType     = flag.String("sample-type", "login_success", "Event type of the sample used by --render-template")
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "route":
+			runRoute(os.Args[2:])
+			return
+		case "silence":
+			runSilence(os.Args[2:])
+			return
+		}
+	}
 	flag.Parse()
 
 	if *flagVersion {
@@ -34,6 +53,14 @@ func main() {
 		return
 	}
 
+	if *flagRenderTemplate != "" {
+		if err := renderTemplate(*flagRenderTemplate, *flagSampleType); err != nil {
+			fmt.Fprintf(os.Stderr, "render template: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg, err := config.Load(*flagConfig)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
@@ -41,17 +68,20 @@ func main() {
 	}
 
 	logging.Setup(cfg.Telemetry.LogLevel, cfg.Telemetry.LogFile)
-	log := logging.L()
 
 	if *flagTest {
 		testRun(cfg)
 		return
 	}
 
+	if *flagReplay != "" {
+		runReplay(cfg)
+		return
+	}
+
 	if *flagBatch {
-		log.Info("batch mode not yet implemented; printing TODO and exiting")
-		fmt.Println("TODO: batch summary mode is a planned feature.")
-		os.Exit(0)
+		runBatch(cfg)
+		return
 	}
 
 	// Default to daemon unless flags say otherwise
@@ -71,16 +101,39 @@ func runDaemon(cfg *config.Config) {
 		cancel()
 	}()
 
-	enricher := enrich.NewEnricher(cfg)
-	slack := notify.NewSlack(cfg)
-	prs := parser.NewParser()
-	dedup := rules.NewDeduper(cfg.RateLimit.DedupWindowSeconds)
+	pl, err := newPipeline(cfg, true)
+	if err != nil {
+		log.Error("failed to set up notifiers", "error", err)
+		os.Exit(1)
+	}
+	slacks := slackNotifiers(pl.notifier.Sinks())
+	pl.spool(cfg)
+	if err := pl.dispatch(cfg); err != nil {
+		log.Error("failed to set up notifiers", "error", err)
+		os.Exit(1)
+	}
+
+	if store, err := silence.Open(cfg.Silence.Path); err != nil {
+		log.Warn("silences disabled", "error", err)
+	} else {
+		pl.silences = store
+		listenSilences(ctx, cfg.Silence.Socket, store)
+	}
+	if cfg.Interactive.Addr != "" {
+		listenInteractive(ctx, cfg.Interactive, pl.silences, slacks)
+	}
 
 	src, err := sources.SelectSource(ctx, cfg)
 	if err != nil {
 		log.Error("failed to select source", "error", err)
 		os.Exit(1)
 	}
+	health := newHealthMonitor(ctx, pl.alert)
+	src = sources.Supervise(src, health.onChange)
+	health.src, health.sinks = src, pl.notifier
+	if cfg.Telemetry.HealthAddr != "" {
+		health.listen(cfg.Telemetry.HealthAddr)
+	}
 	records, err := src.Start(ctx)
 	if err != nil {
 		log.Error("failed to start source", "error", err)
@@ -95,50 +148,191 @@ func runDaemon(cfg *config.Config) {
 		select {
 		case <-ctx.Done():
 			log.Info("context cancelled; exiting")
+			pl.flush()
 			return
 		case rec, ok := <-records:
 			if !ok {
 				log.Warn("records channel closed; exiting")
+				pl.flush()
 				return
 			}
-			if ev, ok := prs.Parse(rec); ok {
-				enricher.Enrich(&ev)
-				if !dedup.ShouldSend(&ev) {
-					continue
-				}
-				// Always emit a debug summary of the event to aid troubleshooting.
-				log.Debug("event", "type", ev.Type, "user", ev.Username, "ip", ev.SourceIP, "method", ev.Method, "port", ev.Port)
-				if cfg.SlackWebhook == "" {
-					log.Info("event", "type", ev.Type, "user", ev.Username, "ip", ev.SourceIP, "method", ev.Method)
-					continue
-				}
-				if err := slack.SendEvent(ctx, &ev); err != nil {
-					log.Warn("failed to send to slack", "error", err)
-				}
-			}
+			pl.handle(ctx, rec)
 		case <-ticker.C:
 			log.Debug("heartbeat")
+			pl.saveSilences()
 		}
 	}
 }
 
+// runReplay feeds a saved log through the daemon pipeline. Notifications are
+// only logged unless --replay-send is given.
+func runReplay(cfg *config.Config) {
+	log := logging.L()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	src := &sources.ReplaySource{Path: *flagReplay, Speed: *flagReplaySpeed}
+	records, err := src.Start(ctx)
+	if err != nil {
+		log.Error("failed to open replay input", "error", err)
+		os.Exit(1)
+	}
+	pl, err := newPipeline(cfg, *flagReplaySend)
+	if err != nil {
+		log.Error("failed to set up notifiers", "error", err)
+		os.Exit(1)
+	}
+	pl.dedup.EventTime = true
+	n := 0
+	for rec := range records {
+		pl.handle(ctx, rec)
+		n++
+	}
+	pl.flush()
+	log.Info("replay finished", "records", n)
+}
+
+// runBatch reads the last batch window from a backfill source and posts a
+// summary. Quiet windows (no successes, few failures) are skipped.
+func runBatch(cfg *config.Config) {
+	log := logging.L()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	src, err := sources.SelectBackfill(cfg)
+	if err != nil {
+		log.Error("failed to select backfill source", "error", err)
+		os.Exit(1)
+	}
+	end := time.Now()
+	start := end.Add(-time.Duration(cfg.Batch.WindowSeconds) * time.Second)
+	records, err := src.Backfill(ctx, start)
+	if err != nil {
+		log.Error("failed to start backfill", "error", err)
+		os.Exit(1)
+	}
+
+	// The pipeline scores events and checks policies and rules like the
+	// daemon; its notifiers are not used.
+	pl, err := newPipeline(cfg, false)
+	if err != nil {
+		log.Error("failed to set up rules", "error", err)
+		os.Exit(1)
+	}
+	sb := rules.NewSummaryBuilder(start, end)
+	silences, err := silence.Open(cfg.Silence.Path)
+	if err != nil {
+		log.Warn("silenced events not counted", "error", err)
+	}
+	for rec := range records {
+		ev, ok := pl.prs.Parse(rec)
+		if !ok {
+			continue
+		}
+		pl.enricher.Enrich(&ev)
+		pl.scorer.Score(&ev)
+		// As in the daemon, a silenced event is not counted and raises no
+		// violations, but rules still see it.
+		var sl *silence.Silence
+		if silences != nil {
+			sl = silences.Match(&ev, ev.Timestamp)
+		}
+		var violations []string
+		if v := pl.policies.Check(&ev); v != nil {
+			violations = append(violations, "policy:"+v.Policy)
+		}
+		drop := false
+		for _, det := range pl.detector.Apply(&ev) {
+			if det.Alert {
+				violations = append(violations, "rule:"+det.Rule)
+			}
+			drop = drop || det.Drop
+		}
+		if sl != nil {
+			sb.Silenced(&ev, sl.Label())
+			continue
+		}
+		for _, v := range violations {
+			sb.Violation(&ev, v)
+		}
+		if !drop {
+			sb.Add(&ev)
+		}
+	}
+	sum := sb.Summary(5)
+	log.Info("batch summary", "source", src.Name(), "events", sum.Total(), "success", sum.Counts["login_success"], "failure", sum.Counts["login_failure"], "violations", sum.Violations)
+	if sum.Counts["login_success"] == 0 && sum.Counts["login_failure"] < cfg.Batch.MinFailedThreshold && len(sum.Violations) == 0 && len(sum.Silenced) == 0 {
+		log.Info("batch window below threshold; not sending")
+		return
+	}
+	sinks, err := notify.Build(cfg)
+	if err != nil {
+		log.Error("failed to set up notifiers", "error", err)
+		os.Exit(1)
+	}
+	if err := notify.NewFanout(sinks...).SendSummary(ctx, sum); err != nil {
+		log.Error("failed to send summary", "error", err)
+		os.Exit(1)
+	}
+}
+
 func testRun(cfg *config.Config) {
 	logging.Setup(cfg.Telemetry.LogLevel, cfg.Telemetry.LogFile)
 	log := logging.L()
-	slack := notify.NewSlack(cfg)
-	msg := notify.TestMessage()
-	if cfg.SlackWebhook == "" {
-		log.Info("--test: no slack_webhook configured; printing test payload")
-		fmt.Println(msg.Text)
-		return
+	sinks, err := notify.Build(cfg)
+	if err != nil {
+		log.Error("failed to set up notifiers", "error", err)
+		os.Exit(1)
 	}
+	msg := notify.TestMessage()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if err := slack.Send(ctx, msg); err != nil {
-		log.Error("test send failed", "error", err)
+	failed := false
+	for _, n := range sinks {
+		ts, ok := n.(notify.TextSender)
+		if !ok {
+			log.Info("--test: notifier does not support text messages; skipped", "notifier", n.Name())
+			continue
+		}
+		if err := ts.SendText(ctx, msg.Text); err != nil {
+			log.Error("test send failed", "notifier", n.Name(), "error", err)
+			failed = true
+			continue
+		}
+		log.Info("test message sent successfully", "notifier", n.Name())
+	}
+	if failed {
 		os.Exit(1)
 	}
-	log.Info("test message sent successfully")
+}
+
+// renderTemplate previews a message template against a sample event. JSON
+// templates are checked and printed indented.
+func renderTemplate(path, eventType string) error {
+	set, err := tmpl.LoadSet(map[string]string{eventType: path})
+	if err != nil {
+		return err
+	}
+	out, err := set.Render(tmpl.Sample(eventType))
+	if err != nil {
+		return err
+	}
+	if out.JSON {
+		var b bytes.Buffer
+		if err := json.Indent(&b, []byte(out.Text), "", "  "); err != nil {
+			return err
+		}
+		out.Text = b.String() + "\n"
+	}
+	fmt.Print(out.Text)
+	return nil
 }
 
 func versionString() string {
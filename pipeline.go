@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/enrich"
+	"ssh-noty/internal/logging"
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/notify"
+	"ssh-noty/internal/parser"
+	"ssh-noty/internal/queue"
+	"ssh-noty/internal/route"
+	"ssh-noty/internal/rules"
+	"ssh-noty/internal/silence"
+)
+
+// pipeline is the parser → enrich → rules → notify chain shared by the
+// daemon and replay modes.
+type pipeline struct {
+	prs      *parser.Parser
+	enricher *enrich.Enricher
+	scorer   *rules.Scorer
+	policies *rules.Policies
+	detector *rules.Detector
+	dedup    *rules.Deduper
+	notifier *notify.Fanout
+	routes   *route.Table
+	silences *silence.Store
+	// logOnly is set when events are only logged; routes then only name
+	// the notifiers an event would go to.
+	logOnly bool
+	// drain bounds how long flush waits for pending deliveries.
+	drain time.Duration
+}
+
+// newPipeline builds the configured notifiers; with send unset events are
+// only logged.
+func newPipeline(cfg *config.Config, send bool) (*pipeline, error) {
+	p := &pipeline{
+		prs:      parser.NewParser(),
+		enricher: enrich.NewEnricher(cfg),
+		dedup:    rules.NewDeduper(cfg.RateLimit.DedupWindowSeconds),
+		notifier: notify.NewFanout(notify.NewLog()),
+		logOnly:  !send,
+		drain:    30 * time.Second,
+	}
+	var err error
+	if p.scorer, err = rules.NewScorer(cfg.Scoring); err != nil {
+		return nil, fmt.Errorf("scoring: %w", err)
+	}
+	if p.policies, err = rules.NewPolicies(cfg.Policies); err != nil {
+		return nil, fmt.Errorf("policies: %w", err)
+	}
+	if cfg.Rules.File != "" {
+		if p.detector, err = rules.LoadRules(cfg.Rules.File); err != nil {
+			return nil, fmt.Errorf("rules: %w", err)
+		}
+	}
+	if p.routes, err = route.New(cfg.Routes); err != nil {
+		return nil, err
+	}
+	if send {
+		sinks, err := notify.Build(cfg)
+		if err != nil {
+			return nil, err
+		}
+		p.notifier = notify.NewFanout(sinks...)
+	}
+	return p, nil
+}
+
+// spool puts a durable retry queue in front of every notifier. A sink whose
+// spool cannot be opened is used directly.
+func (p *pipeline) spool(cfg *config.Config) {
+	opts := queue.Options{
+		MaxAge:     time.Duration(cfg.Queue.MaxAgeSeconds) * time.Second,
+		MaxBackoff: time.Duration(cfg.Queue.MaxBackoffSeconds) * time.Second,
+	}
+	sinks := p.notifier.Sinks()
+	for i, n := range sinks {
+		s, err := queue.NewSpool(n, filepath.Join(cfg.Queue.Dir, url.PathEscape(n.Name())), opts)
+		if err != nil {
+			logging.L().Warn("spool disabled", "notifier", n.Name(), "error", err)
+			continue
+		}
+		sinks[i] = s
+	}
+	p.notifier = notify.NewFanout(sinks...)
+}
+
+// dispatch gives every notifier its own queue and worker so that a slow
+// destination does not hold up reading records.
+func (p *pipeline) dispatch(cfg *config.Config) error {
+	sinks := p.notifier.Sinks()
+	for i, n := range sinks {
+		a, err := notify.NewAsync(n, cfg.Dispatch.QueueSize, cfg.Dispatch.Overflow)
+		if err != nil {
+			return err
+		}
+		sinks[i] = a
+	}
+	p.notifier = notify.NewFanout(sinks...)
+	p.drain = time.Duration(cfg.Dispatch.DrainTimeoutSeconds) * time.Second
+	return nil
+}
+
+func (p *pipeline) handle(ctx context.Context, rec parser.RawRecord) {
+	log := logging.L()
+	ev, ok := p.prs.Parse(rec)
+	if !ok {
+		return
+	}
+	p.enricher.Enrich(&ev)
+	p.scorer.Score(&ev)
+	// A silenced event raises no alerts; rules still see it so that their
+	// counts stay accurate.
+	var sl *silence.Silence
+	if p.silences != nil {
+		now := ev.Timestamp
+		if now.IsZero() {
+			now = time.Now()
+		}
+		sl = p.silences.Find(&ev, now)
+	}
+	var alerts []*model.Event
+	// Policies tag the event before rules so that rules can match on them.
+	if v := p.policies.Check(&ev); v != nil {
+		log.Debug("login outside schedule", "policy", v.Policy, "user", ev.Username)
+		if v.Alert {
+			alerts = append(alerts, alertEvent(&ev, v.Policy, v.Severity, v.Text(&ev)))
+		}
+	}
+	drop := false
+	for _, det := range p.detector.Apply(&ev) {
+		log.Debug("rule matched", "rule", det.Rule, "type", ev.Type, "ip", ev.SourceIP)
+		if det.Alert {
+			alerts = append(alerts, alertEvent(&ev, det.Rule, det.Severity, det.Text(&ev)))
+		}
+		drop = drop || det.Drop
+	}
+	if sl != nil {
+		// Duplicates dedup would have suppressed are not counted.
+		if p.dedup.ShouldSend(&ev) {
+			log.Debug("event silenced", "type", ev.Type, "ip", ev.SourceIP, "silence", sl.ID)
+			p.silences.Count(sl.ID)
+			p.route(&ev).CountSilenced(&ev, sl.Label())
+		}
+		return
+	}
+	for _, a := range alerts {
+		if err := p.route(a).SendEvent(ctx, a); err != nil {
+			log.Warn("failed to send alert", "error", err)
+		}
+	}
+	if drop {
+		return
+	}
+	// Failures suppressed by dedup still reach the sinks that count them
+	// per source address.
+	dup := !p.dedup.ShouldSend(&ev)
+	if dup && (!notify.IsFailure(&ev) || ev.SourceIP == "") {
+		return
+	}
+	// Always emit a debug summary of the event to aid troubleshooting.
+	log.Debug("event", "type", ev.Type, "user", ev.Username, "ip", ev.SourceIP, "method", ev.Method, "port", ev.Port, "severity", ev.Severity, "tags", ev.Tags)
+	sinks := p.route(&ev)
+	if dup {
+		sinks = sinks.Counting()
+	}
+	if err := sinks.SendEvent(ctx, &ev); err != nil {
+		log.Warn("failed to send event", "error", err)
+	}
+}
+
+// route returns the notifiers ev goes to.
+func (p *pipeline) route(ev *model.Event) *notify.Fanout {
+	d := p.routes.Route(ev)
+	if d.Default {
+		return p.notifier
+	}
+	logging.L().Debug("event routed", "type", ev.Type, "routes", d.Routes, "notifiers", d.Notifiers)
+	if p.logOnly {
+		return p.notifier
+	}
+	return p.notifier.Only(d.Notifiers...)
+}
+
+// alertEvent is the "alert" event raised by a rule or policy for ev. It
+// carries the alert text and rule name in its fields and is routed like
+// any event at severity sev (ev's severity when unset).
+func alertEvent(ev *model.Event, rule string, sev model.Severity, text string) *model.Event {
+	a := *ev
+	a.Type = "alert"
+	a.Severity = ev.Level()
+	if sev != model.SeverityUnset {
+		a.Severity = sev
+	}
+	a.Fields = maps.Clone(ev.Fields)
+	if a.Fields == nil {
+		a.Fields = make(map[string]string)
+	}
+	a.Fields["rule"], a.Fields["alert"] = rule, text
+	a.Tags = slices.Clone(ev.Tags)
+	return &a
+}
+
+// alert sends an operational message (e.g. a source going down).
+func (p *pipeline) alert(ctx context.Context, text string) {
+	if err := p.notifier.SendText(ctx, text); err != nil {
+		logging.L().Warn("failed to send alert", "error", err)
+	}
+}
+
+// saveSilences persists the silence match counts.
+func (p *pipeline) saveSilences() {
+	if p.silences == nil {
+		return
+	}
+	if err := p.silences.Save(); err != nil {
+		logging.L().Warn("failed to save silences", "error", err)
+	}
+}
+
+// flush delivers queued notifications and events held back by batching
+// notifiers before exit.
+func (p *pipeline) flush() {
+	p.saveSilences()
+	ctx, cancel := context.WithTimeout(context.Background(), p.drain)
+	defer cancel()
+	if err := p.notifier.Flush(ctx); err != nil {
+		logging.L().Warn("failed to flush notifiers", "error", err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/parser"
+	"ssh-noty/internal/rules"
+	"ssh-noty/internal/silence"
+)
+
+// pagerDuty records the actions and dedup keys sent to a fake Events API.
+func pagerDuty(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var actions []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Action string `json:"event_action"`
+			Key    string `json:"dedup_key"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		actions = append(actions, body.Action+" "+body.Key)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), actions...)
+	}
+}
+
+func TestPipeline_PagerCountsDeduplicatedFailures(t *testing.T) {
+	srv, got := pagerDuty(t)
+	cfg := &config.Config{Notifiers: []config.Notifier{{Type: "pagerduty", Name: "pd", URL: srv.URL, Token: "RKEY"}}}
+	cfg.RateLimit.DedupWindowSeconds = 600
+	p, err := newPipeline(cfg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 25; i++ {
+		p.handle(ctx, parser.RawRecord{
+			Line:      "Failed password for root from 198.51.100.1 port 50000 ssh2",
+			Timestamp: time.Now(), Hostname: "web1", PID: 77,
+		})
+	}
+	if actions := got(); len(actions) != 1 || actions[0] != "trigger ssh-noti/web1/bruteforce/198.51.100.1" {
+		t.Fatalf("expected one brute-force trigger, got %q", actions)
+	}
+}
+
+func TestPipeline_RuleAlertsAreRoutedAndSilenced(t *testing.T) {
+	srv, got := pagerDuty(t)
+	cfg := &config.Config{
+		Notifiers: []config.Notifier{{Type: "pagerduty", Name: "pd", URL: srv.URL, Token: "RKEY"}, {Type: "log", Name: "log"}},
+		Routes:    []config.Route{{Name: "alerts", Match: config.RouteMatch{Types: []string{"alert"}}, Notifiers: []string{"pd"}}},
+	}
+	p, err := newPipeline(cfg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.detector, err = rules.NewDetector([]rules.Rule{{Name: "deploy", When: `user == "deploy"`, Actions: []string{"alert"}, Severity: "high"}}); err != nil {
+		t.Fatal(err)
+	}
+	if p.silences, err = silence.Open(filepath.Join(t.TempDir(), "silences.json")); err != nil {
+		t.Fatal(err)
+	}
+	login := func(ip string) {
+		p.handle(context.Background(), parser.RawRecord{
+			Line:      "Accepted publickey for deploy from " + ip + " port 50000 ssh2",
+			Timestamp: time.Now(), Hostname: "web1", PID: 77,
+		})
+	}
+	login("203.0.113.5")
+	actions := got()
+	if len(actions) != 2 || !slices.Contains(actions, "trigger ssh-noti/web1/alert/deploy/203.0.113.5") {
+		t.Fatalf("expected the rule alert to page, got %q", actions)
+	}
+	if _, err := p.silences.Add(silence.Silence{IP: "198.51.100.0/24", End: time.Now().Add(time.Hour), CreatedBy: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	login("198.51.100.1")
+	login("198.51.100.1")
+	if n := len(got()); n != 2 {
+		t.Fatalf("silenced login raised %d pages", n-2)
+	}
+	if n := p.silences.List(time.Now())[0].Matched; n != 1 {
+		t.Fatalf("a duplicate should not count against the silence, matched %d", n)
+	}
+}
+
+func TestPipeline_SlackThreadCountsDeduplicatedFailures(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	var last string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		calls = append(calls, r.URL.Path)
+		last = string(b)
+		mu.Unlock()
+		w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1.0"}`))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{Notifiers: []config.Notifier{{Type: "slack", Name: "slack", URL: srv.URL, Token: "xoxb", Channel: "C1"}}}
+	cfg.RateLimit.DedupWindowSeconds = 600
+	p, err := newPipeline(cfg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		p.handle(context.Background(), parser.RawRecord{
+			Line:      "Failed password for root from 198.51.100.1 port 50000 ssh2",
+			Timestamp: time.Now(), Hostname: "web1", PID: 77,
+		})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 5 || calls[0] != "/chat.postMessage" || calls[4] != "/chat.update" {
+		t.Fatalf("expected one post and four updates, got %q", calls)
+	}
+	if !strings.Contains(last, "*5 failed attempts*") {
+		t.Fatalf("counter not updated: %s", last)
+	}
+}
+
+func TestPipeline_LogOnlyReplayIgnoresRoutes(t *testing.T) {
+	cfg := &config.Config{Routes: []config.Route{{Name: "root", Match: config.RouteMatch{Users: []string{"root"}}, Notifiers: []string{"pager"}}}}
+	p, err := newPipeline(cfg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.dedup.EventTime = true
+	p.handle(context.Background(), parser.RawRecord{
+		Line:      "Accepted password for root from 198.51.100.1 port 50000 ssh2",
+		Timestamp: time.Now(), Hostname: "web1", PID: 77,
+	})
+	if h := p.notifier.Sinks()[0].Health(); h.LastSuccess.IsZero() {
+		t.Fatal("routed event was not logged")
+	}
+}
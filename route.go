@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/model"
+	"ssh-noty/internal/route"
+	"ssh-noty/internal/rules"
+)
+
+// routeEvent is the --event input, using the field names of the JSON
+// records written by the file and exec notifiers.
+type routeEvent struct {
+	Type           string    `json:"type"`
+	User           string    `json:"user"`
+	SourceIP       string    `json:"source_ip"`
+	Port           int       `json:"port"`
+	Method         string    `json:"method"`
+	KeyFingerprint string    `json:"key_fingerprint"`
+	Host           string    `json:"host"`
+	Country        string    `json:"country"`
+	Severity       string    `json:"severity"`
+	Tags           []string  `json:"tags"`
+	Time           time.Time `json:"time"`
+}
+
+// runRoute implements "ssh-noti route": a dry run printing the routes an
+// event matches and the notifiers that would receive it.
+func runRoute(args []string) {
+	fs := flag.NewFlagSet("route", flag.ExitOnError)
+	cfgPath := fs.String("config", "/opt/ssh-noti/config.json", "Path to config.json")
+	event := fs.String("event", "", `Event as JSON, e.g. {"type":"login_success","user":"root","source_ip":"203.0.113.5"}`)
+	fs.Parse(args)
+	if *event == "" {
+		fmt.Fprintln(os.Stderr, "route: --event is required")
+		os.Exit(2)
+	}
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := explainRoute(os.Stdout, cfg, *event); err != nil {
+		fmt.Fprintf(os.Stderr, "route: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func explainRoute(w io.Writer, cfg *config.Config, event string) error {
+	var in routeEvent
+	dec := json.NewDecoder(strings.NewReader(event))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		return fmt.Errorf("--event: %w", err)
+	}
+	ev := model.Event{
+		Type: in.Type, Username: in.User, SourceIP: in.SourceIP, Port: in.Port, Method: in.Method,
+		KeyFingerprint: in.KeyFingerprint, Hostname: in.Host, Country: in.Country, Tags: in.Tags, Timestamp: in.Time,
+	}
+	// An event without a severity is scored like the daemon would.
+	if in.Severity != "" {
+		sev, err := model.ParseSeverity(in.Severity)
+		if err != nil {
+			return fmt.Errorf("--event: %w", err)
+		}
+		ev.Severity = sev
+	} else {
+		scorer, err := rules.NewScorer(cfg.Scoring)
+		if err != nil {
+			return fmt.Errorf("scoring: %w", err)
+		}
+		score := scorer.Score(&ev)
+		fmt.Fprintf(w, "score: %d\n", score)
+	}
+	policies, err := rules.NewPolicies(cfg.Policies)
+	if err != nil {
+		return fmt.Errorf("policies: %w", err)
+	}
+	if v := policies.Check(&ev); v != nil {
+		fmt.Fprintf(w, "outside schedule: %s (%s)\n", v.Policy, v.Local.Format("Mon 15:04 MST"))
+	}
+	if cfg.Rules.File != "" {
+		detector, err := rules.LoadRules(cfg.Rules.File)
+		if err != nil {
+			return fmt.Errorf("rules: %w", err)
+		}
+		for _, det := range detector.Apply(&ev) {
+			if det.Drop {
+				fmt.Fprintf(w, "dropped by rule %s\n", det.Rule)
+				return nil
+			}
+			fmt.Fprintf(w, "rule: %s\n", det.Rule)
+		}
+	}
+	table, err := route.New(cfg.Routes)
+	if err != nil {
+		return err
+	}
+	d := table.Route(&ev)
+	fmt.Fprintf(w, "severity: %s\n", ev.Level())
+	if len(ev.Tags) > 0 {
+		fmt.Fprintf(w, "tags: %s\n", strings.Join(ev.Tags, ", "))
+	}
+	if d.Default {
+		var names []string
+		for _, n := range cfg.Sinks() {
+			names = append(names, n.Name)
+		}
+		fmt.Fprintf(w, "no route matched; default: %s\n", strings.Join(names, ", "))
+		return nil
+	}
+	fmt.Fprintf(w, "routes: %s\n", strings.Join(d.Routes, ", "))
+	if len(d.Notifiers) == 0 {
+		fmt.Fprintln(w, "dropped: the matching routes have no notifiers")
+		return nil
+	}
+	fmt.Fprintf(w, "notifiers: %s\n", strings.Join(d.Notifiers, ", "))
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"ssh-noty/internal/config"
+	"ssh-noty/internal/logging"
+	"ssh-noty/internal/silence"
+)
+
+// listenSilences serves the silence API on a unix socket only root (or the
+// daemon's user) can reach.
+func listenSilences(ctx context.Context, socket string, store *silence.Store) {
+	log := logging.L()
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		log.Warn("silence socket disabled", "socket", socket, "error", err)
+		return
+	}
+	os.Remove(socket) // left over from an unclean exit
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		log.Warn("silence socket disabled", "socket", socket, "error", err)
+		return
+	}
+	os.Chmod(socket, 0o600)
+	srv := &http.Server{Handler: silence.Handler(store)}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Warn("silence socket failed", "socket", socket, "error", err)
+		}
+	}()
+}
+
+// stringsFlag collects repeated or comma-separated flag values.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*f = append(*f, s)
+		}
+	}
+	return nil
+}
+
+// runSilence implements "ssh-noti silence add|list|expire", talking to the
+// running daemon.
+func runSilence(args []string) {
+	usage := "usage: ssh-noti silence add|list|expire [flags]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("silence "+args[0], flag.ExitOnError)
+	cfgPath := fs.String("config", "/opt/ssh-noti/config.json", "Path to config.json")
+	socket := fs.String("socket", "", "Daemon socket (default: silence.socket from the config)")
+	var sl silence.Silence
+	var users, hosts, types stringsFlag
+	var start, end, by string
+	var duration time.Duration
+	var all bool
+	switch args[0] {
+	case "add":
+		fs.StringVar(&sl.IP, "ip", "", "Source address or CIDR")
+		fs.Var(&users, "user", "User glob pattern (repeatable)")
+		fs.Var(&hosts, "host", "Host glob pattern (repeatable)")
+		fs.Var(&types, "type", "Event type, e.g. login_failure (repeatable)")
+		fs.StringVar(&start, "start", "", "Start time (RFC 3339, default now)")
+		fs.StringVar(&end, "end", "", "End time (RFC 3339)")
+		fs.DurationVar(&duration, "duration", 0, "Length of the silence, e.g. 4h (instead of --end)")
+		fs.StringVar(&by, "by", "", "Creator (default: the invoking user)")
+		fs.StringVar(&sl.Comment, "comment", "", "Reason, e.g. the pentest or change ticket")
+	case "list":
+		fs.BoolVar(&all, "all", false, "Include ended silences")
+	case "expire":
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	fs.Parse(args[1:])
+	if *socket == "" {
+		cfg, err := config.Load(*cfgPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+		*socket = cfg.Silence.Socket
+	}
+	client := silence.NewClient(*socket)
+	ctx := context.Background()
+
+	var err error
+	switch args[0] {
+	case "add":
+		sl.Users, sl.Hosts, sl.Types = users, hosts, types
+		sl.CreatedBy = by
+		if sl.CreatedBy == "" {
+			sl.CreatedBy = invokingUser()
+		}
+		if err = silenceTimes(&sl, start, end, duration); err == nil {
+			if sl, err = client.Add(ctx, sl); err == nil {
+				fmt.Printf("silence %s added, until %s\n", sl.ID, sl.End.Local().Format(time.RFC3339))
+			}
+		}
+	case "list":
+		var list []silence.Silence
+		if list, err = client.List(ctx, all); err == nil {
+			printSilences(os.Stdout, list, time.Now())
+		}
+	case "expire":
+		if fs.NArg() == 0 {
+			fmt.Fprintln(os.Stderr, "silence expire: at least one ID is required")
+			os.Exit(2)
+		}
+		for _, id := range fs.Args() {
+			var e error
+			if _, e = client.Expire(ctx, id); e == nil {
+				fmt.Printf("silence %s expired\n", id)
+			}
+			err = errors.Join(err, e)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "silence %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+}
+
+// silenceTimes sets the window from --start and --end or --duration.
+func silenceTimes(sl *silence.Silence, start, end string, duration time.Duration) error {
+	sl.Start = time.Now()
+	var err error
+	if start != "" {
+		if sl.Start, err = time.Parse(time.RFC3339, start); err != nil {
+			return fmt.Errorf("--start: %w", err)
+		}
+	}
+	switch {
+	case end != "" && duration != 0:
+		return errors.New("--end and --duration are exclusive")
+	case end != "":
+		if sl.End, err = time.Parse(time.RFC3339, end); err != nil {
+			return fmt.Errorf("--end: %w", err)
+		}
+	case duration > 0:
+		sl.End = sl.Start.Add(duration)
+	default:
+		return errors.New("--end or --duration is required")
+	}
+	return nil
+}
+
+func invokingUser() string {
+	if u := os.Getenv("SUDO_USER"); u != "" {
+		return u
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+func printSilences(w io.Writer, list []silence.Silence, now time.Time) {
+	if len(list) == 0 {
+		fmt.Fprintln(w, "no silences")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATE\tSTART\tEND\tMATCHERS\tMATCHED\tBY\tCOMMENT")
+	for _, sl := range list {
+		state := "active"
+		switch {
+		case now.Before(sl.Start):
+			state = "pending"
+		case !now.Before(sl.End):
+			state = "ended"
+		}
+		var m []string
+		if sl.IP != "" {
+			m = append(m, "ip="+sl.IP)
+		}
+		for _, f := range []struct {
+			name string
+			list []string
+		}{{"user", sl.Users}, {"host", sl.Hosts}, {"type", sl.Types}} {
+			if len(f.list) > 0 {
+				m = append(m, f.name+"="+strings.Join(f.list, ","))
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", sl.ID, state,
+			sl.Start.Local().Format("2006-01-02 15:04"), sl.End.Local().Format("2006-01-02 15:04"),
+			strings.Join(m, " "), sl.Matched, sl.CreatedBy, sl.Comment)
+	}
+	tw.Flush()
+}